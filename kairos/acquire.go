@@ -0,0 +1,81 @@
+package kairos
+
+import "time"
+
+// AcquireTimer returns a [Timer] of clk started with duration d, like one created by NewTimer, but
+// recycled from the timers released by ReleaseTimer if there is one.
+func (clk *Scheduler) AcquireTimer(d time.Duration) *Timer {
+	t, _ := clk.freeTimers.Get().(*Timer)
+	if t == nil {
+		t = clk.NewStoppedTimer()
+	}
+	// Nobody else has t, so its fields need no mutex.
+	t.acquired = clk
+	t.recordCallSite()
+	t.clk.resetTimer(t, clk.now().Add(d))
+	return t
+}
+
+// ReleaseTimer stops t, which must have been returned by AcquireTimer of clk, and puts it back for
+// AcquireTimer to reuse.
+func (clk *Scheduler) ReleaseTimer(t *Timer) {
+	if t.clk == nil {
+		panic("timer: ReleaseTimer called on uninitialized Timer")
+	}
+	t.clk.lock()
+	if t.acquired != clk {
+		t.clk.unlock()
+		panic("timer: ReleaseTimer called on a Timer not acquired from this Scheduler, or released twice")
+	}
+	t.acquired = nil
+	// Stopping t and draining its channel in the same critical section guarantees that the next
+	// owner of t cannot receive a value of this one.
+	onStop := t.takeOnStopLocked(t.clk.delTimerLocked(t))
+	t.drainLocked()
+	t.endLocked()
+	b := t.bound
+	t.bound = nil
+	t.onStop = nil
+	t.subs = nil
+	t.name, t.labels = "", nil
+	t.state = Stopped
+	t.i = -1
+	t.n, t.dur, t.left = 0, 0, 0
+	t.clk.unlock()
+	if b != nil {
+		b.stop()
+	}
+	if onStop != nil {
+		onStop()
+	}
+	clk.freeTimers.Put(t)
+}
+
+// AcquireTimer returns a Timer that is started with duration d, like one
+// created by NewTimer, but taken from a pool of timers released by
+// ReleaseTimer if possible. Programs that use a short-lived timer per request
+// can acquire and release timers to avoid allocating one (and its channel)
+// every time:
+//
+//	t := kairos.AcquireTimer(timeout)
+//	defer kairos.ReleaseTimer(t)
+//	select {
+//	case res := <-results:
+//		...
+//	case <-t.C:
+//		...
+//	}
+func AcquireTimer(d time.Duration) *Timer {
+	return defaultScheduler.AcquireTimer(d)
+}
+
+// ReleaseTimer returns a Timer acquired by AcquireTimer to the pool. The timer
+// is stopped if it is still pending, its channel is drained, and everything
+// set on it (its name, OnStop function, subscribers, and BindContext binding)
+// is cleared, so that the next owner gets it as new. t must not be used in any
+// way after ReleaseTimer, since it may already be in use by someone else.
+// ReleaseTimer panics if t was not returned by AcquireTimer or has already
+// been released.
+func ReleaseTimer(t *Timer) {
+	defaultScheduler.ReleaseTimer(t)
+}
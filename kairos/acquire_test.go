@@ -0,0 +1,90 @@
+package kairos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAcquireTimer(t *testing.T) {
+	clk := NewScheduler()
+	const d = 20 * time.Millisecond
+	start := time.Now()
+	timer := clk.AcquireTimer(d)
+	<-timer.C
+	if got := time.Since(start); got < d || got >= d+margin {
+		t.Errorf("acquired timer fired after %v, want %v", got, d)
+	}
+	clk.ReleaseTimer(timer)
+
+	// Release timers whose value has not been received, and some that are still pending, and check
+	// that the timers acquired next never see them.
+	for i := 0; i < 100; i++ {
+		timer := clk.AcquireTimer(0)
+		timer.SetName("old")
+		timer.OnStop(func() {})
+		if i%2 == 0 {
+			time.Sleep(time.Millisecond)
+			if s := timer.State(); s != Fired {
+				t.Fatalf("state of the acquired timer is %v, want Fired", s)
+			}
+		} else {
+			timer.Reset(time.Hour)
+		}
+		clk.ReleaseTimer(timer)
+		timer = clk.AcquireTimer(time.Hour)
+		if s, name := timer.State(), timer.Name(); s != Scheduled || name != "" {
+			t.Fatalf("recycled timer is %v and named %q", s, name)
+		}
+		select {
+		case <-timer.C:
+			t.Fatalf("recycled timer delivered a stale value")
+		default:
+		}
+		clk.ReleaseTimer(timer)
+	}
+	if n := clk.PendingCount(); n != 0 {
+		t.Errorf("%v timers pending after ReleaseTimer", n)
+	}
+}
+
+func TestReleaseTimerPanic(t *testing.T) {
+	clk := NewScheduler()
+	for _, tc := range []struct {
+		name  string
+		timer func() *Timer
+	}{
+		{"new", func() *Timer { return clk.NewTimer(time.Hour) }},
+		{"released", func() *Timer {
+			t := clk.AcquireTimer(time.Hour)
+			clk.ReleaseTimer(t)
+			return t
+		}},
+		{"other", func() *Timer { return NewScheduler().AcquireTimer(time.Hour) }},
+	} {
+		timer := tc.timer()
+		func() {
+			defer func() {
+				if r := recover(); r != "timer: ReleaseTimer called on a Timer not acquired from this Scheduler, or released twice" {
+					t.Errorf("%v: invalid panic %v", tc.name, r)
+				}
+			}()
+			clk.ReleaseTimer(timer)
+		}()
+		timer.Stop()
+	}
+}
+
+func BenchmarkAcquireTimer(b *testing.B) {
+	b.Run("NewTimer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			NewTimer(time.Hour).Stop()
+		}
+	})
+	b.Run("AcquireTimer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ReleaseTimer(AcquireTimer(time.Hour))
+		}
+	})
+}
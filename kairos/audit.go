@@ -0,0 +1,231 @@
+package kairos
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Compact releases the memory that the heap of clk holds for more timers than it has.
+func (clk *Scheduler) Compact() {
+	for _, s := range clk.shards {
+		s.Compact()
+	}
+	clk.lock()
+	defer clk.unlock()
+	clk.timers.Compact()
+}
+
+// SetCapacityHint preallocates the heap of clk for n pending timers, so that arming up to n timers
+// does not grow it, even under a burst; past n, its capacity keeps doubling from n.  The heap is
+// never shrunk below n either, except by Compact.  It is a no-op if the heap already has room for n
+// timers.  The timers of a sharded Scheduler are expected to spread evenly, so each shard gets room
+// for its part of n.
+func (clk *Scheduler) SetCapacityHint(n int) {
+	if len(clk.shards) > 0 {
+		for _, s := range clk.shards {
+			s.SetCapacityHint((n + len(clk.shards) - 1) / len(clk.shards))
+		}
+		return
+	}
+	clk.lock()
+	defer clk.unlock()
+	clk.capHint = n
+	clk.timers.Reserve(n)
+}
+
+// PendingCount returns the number of timers in the heap of clk.
+func (clk *Scheduler) PendingCount() int {
+	if clk.shards != nil {
+		n := 0
+		for _, s := range clk.shards {
+			n += s.PendingCount()
+		}
+		return n
+	}
+	clk.lock()
+	defer clk.unlock()
+	return clk.timers.Len()
+}
+
+// StopAll stops every pending timer (and ticker) of clk, as Shutdown does with the timers that are
+// left once its context is done, and returns their number.  Paused timers are left alone.  Unlike
+// Shutdown, it leaves clk running, so that a test that leaked timers does not leave them to the
+// next one.
+func (clk *Scheduler) StopAll() int {
+	n := 0
+	for _, s := range clk.shards {
+		n += s.StopAll()
+	}
+	clk.lock()
+	var onStops []func()
+	for clk.timers.Len() > 0 {
+		t := clk.timers.Peek()
+		b := clk.delTimerLocked(t)
+		t.endLocked()
+		if f := t.takeOnStopLocked(b); f != nil {
+			onStops = append(onStops, f)
+		}
+		n++
+	}
+	clk.unlock()
+	for _, f := range onStops {
+		f()
+	}
+	return n
+}
+
+// OverdueTimers returns the timers in the heap of clk whose deadline is more than age in the past.
+func (clk *Scheduler) OverdueTimers(age time.Duration) []*Timer {
+	if clk.shards != nil {
+		var ts []*Timer
+		for _, s := range clk.shards {
+			ts = append(ts, s.OverdueTimers(age)...)
+		}
+		return ts
+	}
+	cutoff := clk.now().Add(-age)
+	clk.lock()
+	defer clk.unlock()
+	var ts []*Timer
+	clk.timers.Walk(func(t *Timer) {
+		if t.when.Before(cutoff) {
+			ts = append(ts, t)
+		}
+	})
+	return ts
+}
+
+// TimerInfo describes a pending timer, as returned by DumpTimers.
+type TimerInfo struct {
+	Name      string        // Set by SetName or WithName.
+	Deadline  time.Time     // When the timer is due.
+	Remaining time.Duration // The time left until Deadline when the snapshot was taken; negative if overdue.
+	Index     int           // The position of the timer in the heap, or -1 with a timing wheel.
+	Callback  bool          // Whether the timer calls a function rather than sending on a channel.
+	// Where the timer was created, as "function file:line", if call sites were being recorded then
+	// (see EnableCallSites).
+	CreatedAt string
+}
+
+// Set by EnableCallSites.
+var callSites atomic.Bool
+
+// The import path of the package, to skip its frames in the call stacks of constructors.
+var pkgPath = reflect.TypeOf(Timer{}).PkgPath()
+
+// Record the call stack of the constructor of t for DumpTimers, if enabled.
+func (t *Timer) recordCallSite() {
+	if callSites.Load() {
+		pcs := make([]uintptr, 16)
+		t.callers = pcs[:runtime.Callers(3, pcs)]
+	}
+}
+
+// Return the first frame of the call stack pcs outside of the package (except for its tests),
+// formatted as "function file:line", or "" if there is none.
+func callSite(pcs []uintptr) string {
+	if len(pcs) == 0 {
+		return ""
+	}
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, pkgPath+".") || strings.HasSuffix(f.File, "_test.go") {
+			return fmt.Sprintf("%s %s:%d", f.Function, f.File, f.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// DumpTimers returns a snapshot of the pending timers of clk (and of its shards), ordered by
+// deadline.
+func (clk *Scheduler) DumpTimers() []TimerInfo {
+	var infos []TimerInfo
+	var callers [][]uintptr // Resolved without the mutex.
+	for _, s := range append([]*Scheduler{clk}, clk.shards...) {
+		s.lock()
+		_, heap := s.timers.(*timerHeap)
+		s.timers.Walk(func(t *Timer) {
+			info := TimerInfo{Name: t.name, Deadline: t.when, Index: -1, Callback: t.f != nil || t.call != nil}
+			if heap {
+				info.Index = t.i
+			}
+			infos = append(infos, info)
+			callers = append(callers, t.callers)
+		})
+		s.unlock()
+	}
+	now := clk.now()
+	for i := range infos {
+		infos[i].Remaining = infos[i].Deadline.Sub(now)
+		infos[i].CreatedAt = callSite(callers[i])
+	}
+	slices.SortStableFunc(infos, func(a, b TimerInfo) int { return a.Deadline.Compare(b.Deadline) })
+	return infos
+}
+
+// PendingCount returns the number of timers that are currently scheduled. A
+// count that keeps growing usually means that timers are created but never
+// stopped: a pending timer is referenced by the package until it fires, so it
+// is never garbage collected even if its owner is.
+func PendingCount() int {
+	return defaultScheduler.PendingCount()
+}
+
+// StopAll stops every timer (and ticker) that is currently scheduled, and
+// returns their number. It is meant for tests that share the default Scheduler,
+// to keep the timers leaked by one of them from firing during the next ones;
+// see the kairostest package.
+func StopAll() int {
+	return defaultScheduler.StopAll()
+}
+
+// OverdueTimers returns the scheduled timers whose deadline is more than age in
+// the past. Expired timers are normally processed within microseconds, so a
+// non-empty result for a generous age (say, a second) means that the timer
+// routine is falling behind or stuck. Use the timers' names (see SetName) to
+// identify their owners.
+func OverdueTimers(age time.Duration) []*Timer {
+	return defaultScheduler.OverdueTimers(age)
+}
+
+// Compact releases the memory that the heap holds for more timers than are
+// pending. The heap gives back memory on its own once it is down to a quarter
+// of its capacity, so Compact is only needed to release the rest, for example
+// when a spike of pending timers has just ended.
+func Compact() {
+	defaultScheduler.Compact()
+}
+
+// SetCapacityHint preallocates the heap of the default Scheduler for n pending
+// timers. Call it at startup, before arming a large number of timers at once,
+// to avoid growing the heap (with the mutex held) repeatedly while they are
+// armed.
+func SetCapacityHint(n int) {
+	defaultScheduler.SetCapacityHint(n)
+}
+
+// DumpTimers returns a description of every pending timer, ordered by
+// deadline, to find out what is holding up a shutdown or leaking timers. The
+// descriptions are copied while the mutex is held, so the snapshot is
+// consistent even while timers fire; it is safe to take it at any time, but it
+// holds up the timers for as long as it takes to copy them. Turn on
+// EnableCallSites to learn where the timers were created.
+func DumpTimers() []TimerInfo {
+	return defaultScheduler.DumpTimers()
+}
+
+// EnableCallSites makes the constructors of timers record their caller, which
+// DumpTimers reports as TimerInfo.CreatedAt. It costs a stack walk and an
+// allocation per timer, so it is meant for hunting leaks, not for production.
+// Only the timers created while it is on have a call site.
+func EnableCallSites(on bool) {
+	callSites.Store(on)
+}
@@ -0,0 +1,210 @@
+package kairos
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPendingCount(t *testing.T) {
+	clk := NewScheduler()
+	if got := clk.PendingCount(); got != 0 {
+		t.Errorf("wrong pending count of new clock; got %v, want 0", got)
+	}
+	timers := []*Timer{clk.NewTimer(time.Hour), clk.NewTimer(time.Hour), clk.NewTimer(0)}
+	time.Sleep(100 * time.Millisecond)
+	if got := clk.PendingCount(); got != 2 {
+		t.Errorf("wrong pending count; got %v, want 2", got)
+	}
+	for _, timer := range timers {
+		timer.Stop()
+	}
+	if got := clk.PendingCount(); got != 0 {
+		t.Errorf("wrong pending count after Stop; got %v, want 0", got)
+	}
+}
+
+func TestStopAll(t *testing.T) {
+	for _, clk := range []*Scheduler{NewScheduler(), NewScheduler(WithShards(2))} {
+		fired := make(chan bool, 1)
+		timer := clk.AfterFunc(margin, func() { fired <- true })
+		ticker := clk.NewTicker(margin)
+		paused := clk.NewTimer(time.Hour)
+		paused.Pause()
+		onStop := make(chan bool, 1)
+		clk.NewTimer(time.Hour).OnStop(func() { onStop <- true })
+		if n := clk.StopAll(); n != 3 {
+			t.Errorf("StopAll stopped %v timers, want 3", n)
+		}
+		if got := clk.PendingCount(); got != 0 {
+			t.Errorf("wrong pending count after StopAll; got %v, want 0", got)
+		}
+		select {
+		case <-onStop:
+		default:
+			t.Error("StopAll did not call the function set by WithOnStop")
+		}
+		select {
+		case <-ticker.Done():
+		default:
+			t.Error("StopAll did not end the ticker")
+		}
+		time.Sleep(2 * margin)
+		select {
+		case <-fired:
+			t.Error("timer fired after StopAll")
+		default:
+		}
+		if paused.State() != Paused {
+			t.Errorf("paused timer is %v after StopAll", paused.State())
+		}
+		// The Scheduler is still running.
+		timer.Reset(0)
+		<-fired
+	}
+}
+
+func TestOverdueTimers(t *testing.T) {
+	// A Scheduler whose timer routine is not running never processes expired timers.  Claiming that
+	// it was started keeps the first timer from starting it.
+	clk := &Scheduler{rescheduleC: make(chan struct{}, 1), timers: &timerHeap{}, started: true}
+	overdue := clk.NewTimerAt(time.Now().Add(-time.Hour), WithName("overdue"))
+	clk.NewTimerAt(time.Now().Add(-time.Millisecond))
+	clk.NewTimer(time.Hour)
+	got := clk.OverdueTimers(time.Minute)
+	if len(got) != 1 || got[0] != overdue {
+		t.Errorf("wrong overdue timers; got %v, want [%v]", got, overdue)
+	}
+}
+
+func TestHeapShrinks(t *testing.T) {
+	s := NewScheduler()
+	heapCap := func() int {
+		s.lock()
+		defer s.unlock()
+		return cap(*s.timers.(*timerHeap))
+	}
+	var timers []*Timer
+	for i := 0; i < 100000; i++ {
+		timers = append(timers, s.NewTimer(time.Hour+time.Duration(i)))
+	}
+	if c := heapCap(); c < 100000 {
+		t.Fatalf("heap capacity of %v for 100000 timers", c)
+	}
+	for _, timer := range timers[300:] {
+		timer.Stop()
+	}
+	if c := heapCap(); c > 4*300 {
+		t.Errorf("heap capacity of %v for 300 timers after Stop", c)
+	}
+	s.Compact()
+	if c := heapCap(); c != 300 {
+		t.Errorf("heap capacity of %v for 300 timers after Compact", c)
+	}
+	// Every timer must still be where its index says.
+	for _, timer := range timers[:300] {
+		if !timer.Stop() {
+			t.Fatalf("stop pending timer after Compact: was active is false")
+		}
+	}
+	if c := heapCap(); c > minHeapCap {
+		t.Errorf("heap capacity of %v when empty", c)
+	}
+}
+
+func TestCapacityHint(t *testing.T) {
+	s := NewScheduler()
+	heapCap := func() int {
+		s.lock()
+		defer s.unlock()
+		return cap(*s.timers.(*timerHeap))
+	}
+	s.SetCapacityHint(1000)
+	if c := heapCap(); c != 1000 {
+		t.Errorf("heap capacity of %v after SetCapacityHint(1000)", c)
+	}
+	var timers []*Timer
+	for i := 0; i < 1000; i++ {
+		timers = append(timers, s.NewTimer(time.Hour))
+	}
+	if c := heapCap(); c != 1000 {
+		t.Errorf("heap capacity of %v for 1000 timers with a hint of 1000", c)
+	}
+	timers = append(timers, s.NewTimer(time.Hour))
+	if c := heapCap(); c != 2000 {
+		t.Errorf("heap capacity of %v for 1001 timers with a hint of 1000, want 2000", c)
+	}
+	s.SetCapacityHint(10)
+	if c := heapCap(); c != 2000 {
+		t.Errorf("heap capacity of %v after a smaller hint, want 2000", c)
+	}
+	s.SetCapacityHint(1000)
+	for _, timer := range timers {
+		timer.Stop()
+	}
+	if c := heapCap(); c != 1000 {
+		t.Errorf("heap capacity of %v after Stop, want the hint of 1000", c)
+	}
+}
+
+// Measure arming n timers on a new Scheduler, with and without a capacity hint.
+func BenchmarkCapacityHint(b *testing.B) {
+	const n = 200000
+	for _, hint := range []bool{false, true} {
+		name := "cold"
+		if hint {
+			name = "hint"
+		}
+		b.Run(name, func(b *testing.B) {
+			timers := make([]*Timer, n)
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				s := NewScheduler()
+				for j := range timers {
+					timers[j] = s.NewStoppedTimer()
+				}
+				b.StartTimer()
+				if hint {
+					s.SetCapacityHint(n)
+				}
+				for _, timer := range timers {
+					timer.Reset(time.Hour)
+				}
+				b.StopTimer()
+				for _, timer := range timers {
+					timer.Stop()
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+func TestDumpTimers(t *testing.T) {
+	for _, shards := range []int{1, 4} {
+		s := NewScheduler(WithShards(shards))
+		if infos := s.DumpTimers(); len(infos) != 0 {
+			t.Errorf("DumpTimers() = %v without timers", infos)
+		}
+		EnableCallSites(true)
+		leak := s.AfterFunc(2*time.Hour, func() {}, WithName("leak"))
+		EnableCallSites(false)
+		other := s.NewTimer(time.Hour)
+		infos := s.DumpTimers()
+		if len(infos) != 2 {
+			t.Fatalf("DumpTimers() = %v, want 2 timers", infos)
+		}
+		first, second := infos[0], infos[1]
+		if first.Name != "" || first.Callback || first.CreatedAt != "" || first.Remaining <= 0 || first.Remaining > time.Hour {
+			t.Errorf("first timer is %+v", first)
+		}
+		if d, _ := leak.Deadline(); second.Name != "leak" || !second.Callback || second.Deadline != d || second.Index < 0 {
+			t.Errorf("second timer is %+v", second)
+		}
+		if site := second.CreatedAt; !strings.Contains(site, "TestDumpTimers") || !strings.Contains(site, "audit_test.go:") {
+			t.Errorf("call site %q, want TestDumpTimers", site)
+		}
+		leak.Stop()
+		other.Stop()
+	}
+}
@@ -0,0 +1,215 @@
+package kairos
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A DSTPolicy selects what a calendar timer (see NewCalendarTimer) does on the days on which its
+// time of day does not exist, because the clocks are set forward over it.
+type DSTPolicy int
+
+const (
+	// ShiftNonexistent fires as much later as the clocks are set forward: at 03:30 for a time of
+	// day of 02:30 if the clocks go from 02:00 straight to 03:00.  This is the default.
+	ShiftNonexistent DSTPolicy = iota
+	// SkipNonexistent does not fire on such days.
+	SkipNonexistent
+)
+
+func (p DSTPolicy) String() string {
+	switch p {
+	case ShiftNonexistent:
+		return "ShiftNonexistent"
+	case SkipNonexistent:
+		return "SkipNonexistent"
+	}
+	return fmt.Sprintf("DSTPolicy(%d)", int(p))
+}
+
+// A calendar is the time of day at which a calendar timer fires.
+type calendar struct {
+	hour, min int
+	loc       *time.Location // Nil for the local time zone, which is loaded again every time.
+}
+
+// Return a calendar for hour:min in loc, or panic on behalf of the constructor called name if the
+// time of day is not valid.
+func newCalendar(hour, min int, loc *time.Location, name string) *calendar {
+	if hour < 0 || hour > 23 || min < 0 || min > 59 {
+		panic("timer: invalid time of day for " + name)
+	}
+	if loc == time.Local {
+		loc = nil
+	}
+	return &calendar{hour: hour, min: min, loc: loc}
+}
+
+// Return the first occurrence of the time of day of c after after.  On the days on which it does not
+// exist, p selects whether it is skipped.
+func (c *calendar) next(after time.Time, p DSTPolicy) time.Time {
+	loc := c.loc
+	if loc == nil {
+		loc = localZone()
+	}
+	y, m, d := after.In(loc).Date()
+	for i := 0; ; i++ {
+		when, ok := occurrence(y, m, d+i, c.hour, c.min, loc)
+		if !ok && p == SkipNonexistent {
+			continue
+		}
+		// On the day the clocks are set back, the first reading of the time is always the one
+		// returned, so having fired at it is enough not to fire at the second one.
+		if when.After(after) {
+			return when
+		}
+	}
+}
+
+// Return the instant at which the clock of loc reads hour:min on the given day, and true.  If the
+// clock reads it twice, because it is set back over it, this is the first time.  If the clock skips
+// it, because it is set forward over it, return the instant that is as far after the skip as the
+// time of day is after its start instead, and false.
+func occurrence(y int, m time.Month, d, hour, min int, loc *time.Location) (time.Time, bool) {
+	when := time.Date(y, m, d, hour, min, 0, 0, loc)
+	wall := time.Date(y, m, d, hour, min, 0, 0, time.UTC)
+	if got := localClock(when); !got.Equal(wall) {
+		// time.Date picked the offset from either side of the skip; read the time of day with
+		// the one from before it.
+		start, end := when.ZoneBounds()
+		skip := start
+		if got.Before(wall) {
+			skip = end
+		}
+		_, offset := skip.Add(-time.Second).Zone()
+		return wall.Add(-time.Duration(offset) * time.Second).In(loc), false
+	}
+	if start, _ := when.ZoneBounds(); !start.IsZero() {
+		_, offset := when.Zone()
+		_, prev := start.Add(-time.Second).Zone()
+		// If the clock was set back at start, the time of day may also have been read before.
+		if earlier := when.Add(-time.Duration(prev-offset) * time.Second); prev > offset && earlier.Before(start) {
+			return earlier, true
+		}
+	}
+	return when, true
+}
+
+// Return the date and the time of day (to the minute) that the clock of the location of t reads at
+// t, as a time in UTC.
+func localClock(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+// Return the local time zone of the system as it is now.  time.Local is loaded once, so it misses
+// changes of the time zone of the system; localZone loads it again the same way, from $TZ or
+// /etc/localtime, and falls back to time.Local where neither can be loaded, as on Windows.
+func localZone() *time.Location {
+	tz, ok := os.LookupEnv("TZ")
+	switch {
+	case ok && tz == "":
+		return time.UTC
+	case ok:
+		tz = strings.TrimPrefix(tz, ":")
+		if !filepath.IsAbs(tz) {
+			if loc, err := time.LoadLocation(tz); err == nil {
+				return loc
+			}
+			return time.Local
+		}
+	default:
+		tz = "/etc/localtime"
+	}
+	if data, err := os.ReadFile(tz); err == nil {
+		if loc, err := time.LoadLocationFromTZData("Local", data); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// NewCalendarTimer creates a new [Timer] that calls f in its own goroutine, with the time of the
+// fire, every day at hour:min in loc.  A nil loc or time.Local stands for the local time zone.
+func (clk *Scheduler) NewCalendarTimer(hour, min int, loc *time.Location, f func(time.Time), opts ...Option) *Timer {
+	c := newCalendar(hour, min, loc, "NewCalendarTimer")
+	t := clk.newTimer(nil, func(e expiry) { f(e.actual) }, opts)
+	t.startCalendar(c, false)
+	return t
+}
+
+// NewCalendarTicker creates a new [Ticker] that ticks every day at hour:min in loc.  A nil loc or
+// time.Local stands for the local time zone.
+func (clk *Scheduler) NewCalendarTicker(hour, min int, loc *time.Location, opts ...Option) *Ticker {
+	c := newCalendar(hour, min, loc, "NewCalendarTicker")
+	t := clk.NewStoppedTimer(opts...)
+	t.initTicks()
+	t.startCalendar(c, true)
+	return newTicker(t)
+}
+
+// Start the new timer t at the next occurrence of c, or now if t is a ticker created with
+// WithImmediateFirstTick.
+func (t *Timer) startCalendar(c *calendar, ticker bool) {
+	t.calendar = c
+	t.period = 24 * time.Hour
+	now := t.clk.now()
+	when := c.next(now, t.dst)
+	if ticker {
+		when = t.firstTick(when, now)
+	}
+	t.clk.resetTimer(t, when)
+}
+
+// Arm the calendar timer t, which fired as described by e, for the next occurrence of its time of
+// day, unless it has been stopped or re-armed since (its generation is no longer gen).  The
+// occurrence is computed outside the mutex, because the time zone may have to be loaded for it.
+func (clk *Scheduler) rearmCalendar(t *Timer, gen uint64, e expiry) {
+	// Start from the occurrence that fired rather than from the time of the fire if it is later,
+	// so that the timer neither fires at it again if the wall clock is set back, nor skips the
+	// next one if the jitter made it fire early.
+	after := e.actual
+	if e.scheduled.After(after) {
+		after = e.scheduled
+	}
+	next := t.calendar.next(after, t.dst)
+	clk.lock()
+	defer clk.unlock()
+	if t.gen != gen {
+		return
+	}
+	if clk.shutdown {
+		t.endLocked()
+		return
+	}
+	t.nominal = next
+	t.when = t.boundedLocked(t.jitteredLocked(next, t.period))
+	t.dur = t.when.Sub(e.actual)
+	clk.addTimerLocked(t)
+}
+
+// NewCalendarTimer creates a new Timer that calls f in its own goroutine, with
+// the time of the fire, every day at hour:min local time in loc (the local
+// time zone of the system if loc is nil or time.Local). The next occurrence is
+// computed with the time zone database after every fire, so a change of the
+// time zone of the system is picked up then. If the clocks are set forward
+// over hour:min, the timer fires as much later, or, with
+// WithDSTPolicy(SkipNonexistent), not on that day; if they are set back over
+// it, the timer fires only at the first time the clock reads hour:min. Stop
+// stops the timer for good, and Reset moves only its next fire. Like other
+// timers, it does not notice steps of the system clock between two fires
+// unless it is created with WithWallClock. NewCalendarTimer panics if hour is
+// not in [0, 23] or min is not in [0, 59].
+func NewCalendarTimer(hour, min int, loc *time.Location, f func(time.Time), opts ...Option) *Timer {
+	return defaultScheduler.NewCalendarTimer(hour, min, loc, f, opts...)
+}
+
+// NewCalendarTicker is like NewCalendarTimer, but delivers the time of every
+// occurrence on the channel of the returned Ticker, which drops ticks for slow
+// receivers like any other Ticker.
+func NewCalendarTicker(hour, min int, loc *time.Location, opts ...Option) *Ticker {
+	return defaultScheduler.NewCalendarTicker(hour, min, loc, opts...)
+}
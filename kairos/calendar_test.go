@@ -0,0 +1,131 @@
+package kairos
+
+import (
+	"context"
+	"testing"
+	"time"
+	_ "time/tzdata" // For the tests not to depend on the time zone database of the system.
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestCalendarNext(t *testing.T) {
+	ny := mustLoadLocation(t, "America/New_York")
+	at := func(s string) time.Time {
+		when, err := time.ParseInLocation("2006-01-02 15:04 MST", s, ny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return when
+	}
+	for _, tt := range []struct {
+		hour, min int
+		p         DSTPolicy
+		after     string
+		want      string
+	}{
+		{2, 30, ShiftNonexistent, "2026-03-06 12:00 EST", "2026-03-07 02:30 EST"},
+		{2, 30, ShiftNonexistent, "2026-03-07 02:30 EST", "2026-03-08 03:30 EDT"},
+		{2, 30, SkipNonexistent, "2026-03-07 02:30 EST", "2026-03-09 02:30 EDT"},
+		{2, 30, ShiftNonexistent, "2026-03-08 03:30 EDT", "2026-03-09 02:30 EDT"},
+		{1, 30, ShiftNonexistent, "2026-10-31 01:30 EDT", "2026-11-01 01:30 EDT"},
+		// 01:30 EST is the second time the clock reads 01:30 on that day.
+		{1, 30, ShiftNonexistent, "2026-11-01 01:30 EDT", "2026-11-02 01:30 EST"},
+		{1, 30, ShiftNonexistent, "2026-11-01 01:00 EST", "2026-11-02 01:30 EST"},
+		{0, 0, ShiftNonexistent, "2026-12-31 23:59 EST", "2027-01-01 00:00 EST"},
+	} {
+		c := newCalendar(tt.hour, tt.min, ny, "test")
+		if got := c.next(at(tt.after), tt.p); !got.Equal(at(tt.want)) {
+			t.Errorf("next %02d:%02d after %s with %v = %v, want %s", tt.hour, tt.min, tt.after, tt.p, got, tt.want)
+		}
+	}
+
+	// Samoa skipped December 30, 2011 altogether.
+	apia := mustLoadLocation(t, "Pacific/Apia")
+	c := newCalendar(2, 30, apia, "test")
+	after := time.Date(2011, 12, 29, 12, 0, 0, 0, apia)
+	if got, want := c.next(after, ShiftNonexistent), time.Date(2011, 12, 31, 2, 30, 0, 0, apia); !got.Equal(want) {
+		t.Errorf("next 02:30 after %v = %v, want %v", after, got, want)
+	}
+}
+
+func TestCalendarLocalZone(t *testing.T) {
+	c := newCalendar(12, 0, time.Local, "test")
+	after := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		tz   string
+		want time.Time
+	}{
+		{"Asia/Tokyo", time.Date(2026, 6, 1, 3, 0, 0, 0, time.UTC)},
+		{":Europe/Paris", time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC)},
+		{"", time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)},
+	} {
+		t.Setenv("TZ", tt.tz)
+		if got := c.next(after, ShiftNonexistent); !got.Equal(tt.want) {
+			t.Errorf("next 12:00 with TZ=%q = %v, want %v", tt.tz, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarTimer(t *testing.T) {
+	ny := mustLoadLocation(t, "America/New_York")
+	start := time.Date(2026, 3, 6, 12, 0, 0, 0, ny)
+	clk := NewScheduler(WithVirtualTimeAt(start))
+	defer clk.Shutdown(context.Background())
+
+	fires := make(chan time.Time, 3)
+	clk.NewCalendarTimer(2, 30, ny, func(now time.Time) { fires <- now }, WithMaxTicks(3))
+	want := []time.Time{
+		time.Date(2026, 3, 7, 2, 30, 0, 0, ny),
+		// The clocks are set forward from 02:00 EST to 03:00 EDT on March 8.
+		time.Date(2026, 3, 8, 3, 30, 0, 0, ny),
+		time.Date(2026, 3, 9, 2, 30, 0, 0, ny),
+	}
+	for i := range want {
+		if got := <-fires; !got.Equal(want[i]) {
+			t.Errorf("fire %d at %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestCalendarTicker(t *testing.T) {
+	ny := mustLoadLocation(t, "America/New_York")
+	start := time.Date(2026, 10, 31, 12, 0, 0, 0, ny)
+	clk := NewScheduler(WithVirtualTimeAt(start))
+	defer clk.Shutdown(context.Background())
+
+	// The virtual time does not wait for the receiver, so the ticks are buffered.
+	tk := clk.NewCalendarTicker(1, 30, ny, WithMaxTicks(2), WithChannelBuffer(2))
+	<-tk.Done()
+	got := []time.Time{<-tk.C, <-tk.C}
+	// The clocks are set back from 02:00 EDT to 01:00 EST on November 1.
+	want := []time.Time{
+		time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC),
+		time.Date(2026, 11, 2, 6, 30, 0, 0, time.UTC),
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("tick %d at %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCalendarPanics(t *testing.T) {
+	for _, tt := range []struct{ hour, min int }{{24, 0}, {-1, 0}, {0, 60}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewCalendarTimer(%d, %d) did not panic", tt.hour, tt.min)
+				}
+			}()
+			NewCalendarTimer(tt.hour, tt.min, time.UTC, func(time.Time) {})
+		}()
+	}
+}
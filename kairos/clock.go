@@ -1,123 +1,329 @@
 package kairos
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var realClock = newClock()
+// A Clock tells the time and makes timers.  Code that takes a Clock rather than calling the
+// package-level functions can be tested with a FakeClock instead of real sleeps.  RealClock, a
+// *Scheduler, and a *FakeClock are Clocks.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration, opts ...Option) *Timer
+	AfterFunc(d time.Duration, f func(), opts ...Option) *Timer
+	NewTicker(d time.Duration, opts ...Option) *Ticker
+	Sleep(d time.Duration)
+}
+
+// RealClock is the Clock of the package-level functions, which use the default Scheduler.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time { return defaultScheduler.Now() }
 
-type clock struct {
-	rescheduleC chan struct{}
-	mutex       sync.Mutex // protects:
-	timers      *timerHeap
+func (RealClock) NewTimer(d time.Duration, opts ...Option) *Timer { return NewTimer(d, opts...) }
+
+func (RealClock) AfterFunc(d time.Duration, f func(), opts ...Option) *Timer {
+	return AfterFunc(d, f, opts...)
 }
 
-func newClock() *clock {
-	clk := &clock{rescheduleC: make(chan struct{}, 1), timers: &timerHeap{}}
-	go clk.timerRoutine()
-	return clk
+func (RealClock) NewTicker(d time.Duration, opts ...Option) *Ticker { return NewTicker(d, opts...) }
+
+func (RealClock) Sleep(d time.Duration) { Sleep(d) }
+
+// Now returns the current time of clk, which is time.Now unless clk belongs to a FakeClock, or has
+// a source of the time of its own (see WithNowFunc and WithVirtualTime), or SetNowFunc was called.
+func (clk *Scheduler) Now() time.Time {
+	return clk.now()
 }
 
-// NewTimer creates a new [Timer] and starts it with duration d.
-func (clk *clock) NewTimer(d time.Duration) *Timer {
-	t := clk.NewStoppedTimer()
-	clk.resetTimer(t, d)
-	return t
+// Return the current time of clk.  Every reading of the time goes through here.
+func (clk *Scheduler) now() time.Time {
+	if clk.nowFunc != nil {
+		return clk.nowFunc()
+	}
+	return now()
 }
 
-// NewStoppedTimer creates a new stopped [Timer].  Call [Timer.Reset] to start it.
-func (clk *clock) NewStoppedTimer() *Timer {
-	c := make(chan time.Time, 1)
-	return &Timer{C: c, c: c}
+// The function set by SetNowFunc, if any.
+var nowHook atomic.Pointer[func() time.Time]
+
+// Return the current time by the function set by SetNowFunc, or time.Now.
+func now() time.Time {
+	if f := nowHook.Load(); f != nil {
+		return (*f)()
+	}
+	return time.Now()
 }
 
-// Delete timer t from the heap.
-// It returns true if t was removed, false if t wasn't even there.
-// Do not need to update the timer routine: if it wakes up early, no big deal.
-func (clk *clock) delTimer(t *Timer) bool {
-	clk.mutex.Lock()
-	defer clk.mutex.Unlock()
-	return clk.timers.Remove(t)
+// Incremented by every call of SetNowFunc, so that a timer routine can tell that the time it went
+// to sleep by was told by another function.
+var nowGen atomic.Uint64
+
+// The Schedulers whose timer routine is running, for SetNowFunc to wake them up.
+var routines struct {
+	sync.Mutex
+	m map[*Scheduler]struct{}
 }
 
-// Reset the timer to the new timeout duration.
-// This clears the channel.
-func (clk *clock) resetTimer(t *Timer, d time.Duration) (b bool) {
-	clk.mutex.Lock()
-	b = clk.timers.Remove(t)
-	// The channel must be drained while the mutex is locked, otherwise a notification generated by a
-	// concurrent t.Reset(0) call might be erroneously consumed.
-	select {
-	case <-t.C:
-	default:
+// Add clk to the Schedulers that SetNowFunc wakes up, until the returned function is called.
+func registerRoutine(clk *Scheduler) (unregister func()) {
+	routines.Lock()
+	defer routines.Unlock()
+	if routines.m == nil {
+		routines.m = make(map[*Scheduler]struct{})
 	}
-	t.when = time.Now().Add(d)
-	clk.timers.Insert(t)
-	// Reschedule if this is the next timer in the heap.
-	if clk.timers.Peek() == t {
-		// Do not block if there is already a pending reschedule request.
-		select {
-		case clk.rescheduleC <- struct{}{}:
-		default:
-		}
+	routines.m[clk] = struct{}{}
+	return func() {
+		routines.Lock()
+		defer routines.Unlock()
+		delete(routines.m, clk)
+	}
+}
+
+// SetNowFunc makes the package tell the time with f instead of time.Now, for every Scheduler
+// (including the default one) that has no source of the time of its own: the deadlines of new
+// timers, Remaining, the lateness of expiries, and the deadline check of the timer routine all use
+// f, so a test that freezes f sees the same values on every run.  SetNowFunc(nil) restores
+// time.Now.  It is safe to call while timers are pending, from any goroutine: it wakes up every
+// timer routine, so that none keeps sleeping for as long as the previous function made it.  f must
+// be safe for concurrent use.
+func SetNowFunc(f func() time.Time) {
+	if f == nil {
+		nowHook.Store(nil)
+	} else {
+		nowHook.Store(&f)
+	}
+	nowGen.Add(1)
+	routines.Lock()
+	defer routines.Unlock()
+	for clk := range routines.m {
+		clk.wake()
 	}
-	clk.mutex.Unlock()
-	return
 }
 
-func (clk *clock) timerRoutine() {
-	var now time.Time
+// A FakeClock is a Clock whose time only moves when it is told to, by Advance or SetTime.  It is a
+// Scheduler of its own, whose timers are due by its time instead of the real one, so it has every
+// method of a Scheduler.  A FakeClock must be created with NewFakeClock.  It is safe for concurrent
+// use.
+type FakeClock struct {
+	*Scheduler
 
-	sleepTimer := time.NewTimer(0)
-	<-sleepTimer.C
-	sleepTimerActive := false
+	advancing sync.Mutex  // Held by Advance and SetTime.
+	auto      atomic.Bool // Set by AutoAdvance.
+	maxLeaps  int         // The number of leaps after which Wait gives up.
 
-Loop:
+	mu      sync.Mutex // protects:
+	current time.Time
+}
+
+// The time at which a FakeClock starts.
+var fakeEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// NewFakeClock creates a FakeClock that starts at midnight UTC on January 1, 2000.
+func NewFakeClock() *FakeClock {
+	fc := &FakeClock{current: fakeEpoch, maxLeaps: defaultMaxLeaps}
+	fc.Scheduler = NewScheduler(func(clk *Scheduler) {
+		clk.nowFunc = fc.time
+		clk.manualTime = true
+	})
+	return fc
+}
+
+func (fc *FakeClock) time() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.current
+}
+
+// Advance moves the time of fc forward by d, firing the timers that are due by then on the way, in
+// deadline order; timers with the same deadline fire in the order in which they were started (or
+// reset).  The time of fc is the deadline of each timer while it fires, and tickers tick as many
+// times as their period fits in d.  After each expiry, Advance waits for the callbacks to return,
+// so that the timers they start are fired too if they are due by the end of the advance, and it
+// returns once every callback has returned: their effects are visible when Advance returns, but a
+// callback must not wait for the time of fc to move.  Concurrent calls of Advance (and SetTime)
+// take turns.  Advance panics if d is negative.
+func (fc *FakeClock) Advance(d time.Duration) {
+	if d < 0 {
+		panic("timer: negative duration for Advance")
+	}
+	fc.advancing.Lock()
+	defer fc.advancing.Unlock()
+	fc.advanceLocked(fc.time().Add(d))
+}
+
+// SetTime sets the time of fc to t.  Moving it forward is the same as Advance.  Moving it backward
+// fires no timer: the pending ones keep their deadlines, which are just further away, and the
+// timers started afterward are due relative to t.
+func (fc *FakeClock) SetTime(t time.Time) {
+	fc.advancing.Lock()
+	defer fc.advancing.Unlock()
+	if t.After(fc.time()) {
+		fc.advanceLocked(t)
+		return
+	}
+	fc.set(t)
+}
+
+// Move the time of fc forward to end, like Advance.  The caller must hold advancing.
+func (fc *FakeClock) advanceLocked(end time.Time) {
+	clk := fc.Scheduler
 	for {
-		select {
-		case <-sleepTimer.C:
-
-		case <-clk.rescheduleC:
-			// If not yet received a value from sleepTimer.C, the timer must be
-			// stopped and—if Stop reports that the timer expired before being
-			// stopped—the channel explicitly drained.
-			if !sleepTimer.Stop() && sleepTimerActive {
-				<-sleepTimer.C
-			}
+		clk.lock()
+		for clk.callbacks > 0 {
+			clk.funcDone.Wait()
+		}
+		next, ok := clk.timers.Next()
+		if !ok || next.After(end) {
+			fc.set(end)
+			clk.unlock()
+			return
+		}
+		if next.After(fc.time()) {
+			fc.set(next)
 		}
-		sleepTimerActive = false
+		clk.expireDueLocked(fc.time())
+		clk.unlock()
+	}
+}
+
+func (fc *FakeClock) set(t time.Time) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.current = t
+}
 
-	Reschedule:
-		now = time.Now()
+// BlockUntilTimers waits until at least n timers (or tickers) of fc are pending, so that a test
+// does not advance the clock before the code under test has started the timers that it expects to
+// fire.  Paused timers do not count.
+func (fc *FakeClock) BlockUntilTimers(n int) {
+	fc.BlockUntilTimersContext(context.Background(), n)
+}
 
-		clk.mutex.Lock()
-		if clk.timers.Len() == 0 {
-			clk.mutex.Unlock()
-			continue Loop
+// BlockUntilTimersContext is like BlockUntilTimers, but gives up and returns ctx.Err() once ctx is
+// done.
+func (fc *FakeClock) BlockUntilTimersContext(ctx context.Context, n int) error {
+	clk := fc.Scheduler
+	for {
+		clk.lock()
+		if clk.timers.Len() >= n {
+			clk.unlock()
+			return nil
+		}
+		if clk.countC == nil {
+			clk.countC = make(chan struct{})
+		}
+		c := clk.countC
+		clk.unlock()
+		select {
+		case <-c:
+		case <-ctx.Done():
+			return ctx.Err()
 		}
+	}
+}
 
-		t := clk.timers.Peek()
-		delta := t.when.Sub(now)
+// The number of leaps after which a Wait of a FakeClock gives up.
+const defaultMaxLeaps = 1 << 20
 
-		// Sleep if not expired.
-		if delta > 0 {
-			clk.mutex.Unlock()
-			sleepTimer.Reset(delta)
-			sleepTimerActive = true
-			continue Loop
-		}
+// AutoAdvance turns on (or off) the auto-advancing mode of fc, in which the goroutines that wait for
+// the time of fc to move, with Sleep or Wait, move it themselves: instead of waiting, they leap to
+// the earliest pending deadline, like Advance, and on from there until their wait is over.  This
+// suits tests whose goroutines do nothing but wait for the clock between steps, since a goroutine
+// that is busy when another one leaps may miss the time at which it would have started a timer.
+// The timers started by the test itself fire on the way like any others, and Advance and SetTime
+// are still available.  In case no wait is ever over (a Wait for a channel that no timer sends on,
+// while a ticker keeps the clock going), a single Wait gives up after a million leaps.
+func (fc *FakeClock) AutoAdvance(on bool) {
+	fc.auto.Store(on)
+}
+
+// Sleep pauses the current goroutine until the time of fc is d later, which it moves there itself
+// in the auto-advancing mode (see AutoAdvance).
+func (fc *FakeClock) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	t := fc.NewTimer(d)
+	if _, ok := fc.Wait(t.C); !ok {
+		<-t.C
+	}
+}
 
-		// Timer expired.
+// Wait receives a value from c, normally the channel of a timer or ticker of fc, and returns it
+// and true.  In the auto-advancing mode (see AutoAdvance), it moves the time of fc to the next
+// deadline until it has a value, and it returns false if it gives up, or if no timer is pending
+// anymore.
+func (fc *FakeClock) Wait(c <-chan time.Time) (time.Time, bool) {
+	for n := 0; fc.auto.Load(); n++ {
 		select {
-		case t.c <- now:
+		case v := <-c:
+			return v, true
 		default:
 		}
-		clk.timers.Remove(t)
+		if n == fc.maxLeaps || !fc.leap() {
+			return time.Time{}, false
+		}
+	}
+	return <-c, true
+}
+
+// Move the time of fc to the earliest pending deadline, if it is not already past it, and process
+// the timers that are due then; report whether there was one.
+func (fc *FakeClock) leap() bool {
+	fc.advancing.Lock()
+	defer fc.advancing.Unlock()
+	fc.lock()
+	next, ok := fc.timers.Next()
+	fc.unlock()
+	if !ok {
+		return false
+	}
+	if now := fc.time(); next.Before(now) {
+		next = now
+	}
+	fc.advanceLocked(next)
+	return true
+}
 
-		clk.mutex.Unlock()
+// Wake up the callers of BlockUntilTimers, since timers have been added or removed.  The caller
+// must hold the mutex.
+func (clk *Scheduler) countChangedLocked() {
+	if clk.countC != nil {
+		close(clk.countC)
+		clk.countC = nil
+	}
+}
+
+// WithNowFunc makes the Scheduler tell the time with now instead of time.Now, in order to test how
+// code behaves when the clock it sees is skewed, runs fast or slow, or steps: the deadlines of its
+// timers are relative to now, and they are due once now reaches them.  Its timer routine sleeps as
+// long as the deadline is away by now, as if now ran at the rate of real time, unless WithWaitFunc
+// tells it how long to sleep instead; it checks now again when it wakes up, and sleeps again if
+// the next deadline is not reached yet.  now must be safe for concurrent use.
+func WithNowFunc(now func() time.Time) SchedulerOption {
+	return func(clk *Scheduler) {
+		clk.nowFunc = now
+	}
+}
+
+// WithWaitFunc makes the timer routine of the Scheduler sleep as long as wait returns, in real
+// time, when the next deadline is until by the time of the Scheduler; see WithNowFunc.  For a
+// clock that runs twice as fast as real time, for example, wait returns half of the time left
+// until until.  wait must be safe for concurrent use.
+func WithWaitFunc(wait func(until time.Time) time.Duration) SchedulerOption {
+	return func(clk *Scheduler) {
+		clk.waitFunc = wait
+	}
+}
 
-		// Reschedule immediately.
-		goto Reschedule
+// Return how long the timer routine of clk must sleep, at now, until the deadline until.
+func (clk *Scheduler) sleepFor(until, now time.Time) time.Duration {
+	if clk.waitFunc != nil {
+		return clk.waitFunc(until)
 	}
+	return until.Sub(now)
 }
@@ -0,0 +1,453 @@
+package kairos
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	_ Clock = RealClock{}
+	_ Clock = (*Scheduler)(nil)
+	_ Clock = (*FakeClock)(nil)
+)
+
+func TestFakeClock(t *testing.T) {
+	fc := NewFakeClock()
+	start := fc.Now()
+	if !start.Equal(fakeEpoch) {
+		t.Errorf("fake clock starts at %v, want %v", start, fakeEpoch)
+	}
+	timer := fc.NewTimer(time.Hour)
+	ticker := fc.NewTicker(time.Minute)
+	defer ticker.Stop()
+	slept := make(chan struct{})
+	go func() {
+		fc.Sleep(30 * time.Minute)
+		close(slept)
+	}()
+
+	// Real time passes, but not the time of the clock.
+	time.Sleep(margin)
+	select {
+	case <-timer.C:
+		t.Fatal("timer fired before the clock moved")
+	case <-ticker.C:
+		t.Fatal("ticker ticked before the clock moved")
+	case <-slept:
+		t.Fatal("Sleep returned before the clock moved")
+	default:
+	}
+
+	fc.Advance(time.Minute)
+	if got := <-ticker.C; !got.Equal(start.Add(time.Minute)) {
+		t.Errorf("ticker ticked at %v, want %v", got, start.Add(time.Minute))
+	}
+	fc.Advance(29 * time.Minute)
+	<-slept
+	fc.Advance(30 * time.Minute)
+	if got := <-timer.C; !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("timer fired at %v, want %v", got, start.Add(time.Hour))
+	}
+	if got := fc.Now(); !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("Now is %v after advancing by an hour", got)
+	}
+}
+
+// An Observer that records the order of the expiries.
+type fireRecorder struct {
+	mu    sync.Mutex
+	fired []*Timer
+}
+
+func (r *fireRecorder) OnSchedule(t *Timer, when time.Time) {}
+
+func (r *fireRecorder) OnFire(t *Timer, scheduled, actual time.Time) {
+	r.mu.Lock()
+	r.fired = append(r.fired, t)
+	r.mu.Unlock()
+}
+
+func (r *fireRecorder) OnStop(t *Timer) {}
+
+func TestFakeClockOrder(t *testing.T) {
+	fc := NewFakeClock()
+	var r fireRecorder
+	fc.RegisterObserver(&r)
+	var timers []*Timer
+	for i := 0; i < 100; i++ {
+		timers = append(timers, fc.NewTimer(time.Second))
+	}
+	// Resetting a timer to the same deadline puts it behind the others.
+	timers[0].Reset(time.Second)
+	timers = append(timers[1:], timers[0])
+	last := fc.NewTimer(time.Second)
+	fc.Advance(time.Second)
+	<-last.C
+	// The observers are notified once the expiries have been processed.
+	time.Sleep(margin)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.fired) != len(timers)+1 {
+		t.Fatalf("%d timers fired, want %d", len(r.fired), len(timers)+1)
+	}
+	for i, timer := range timers {
+		if r.fired[i] != timer {
+			t.Fatalf("timer %d fired at position %d", i, slices.Index(r.fired, timer))
+		}
+	}
+}
+
+func TestFakeClockConcurrent(t *testing.T) {
+	fc := NewFakeClock()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				fc.Sleep(time.Duration(i%10+1) * time.Millisecond)
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			fc.Advance(time.Millisecond)
+			time.Sleep(10 * time.Microsecond)
+		}
+	}
+}
+
+func TestRealClock(t *testing.T) {
+	var clk Clock = RealClock{}
+	const d = 20 * time.Millisecond
+	start := clk.Now()
+	clk.Sleep(d)
+	<-clk.NewTimer(d).C
+	if got := time.Since(start); got < 2*d || got >= 2*d+margin {
+		t.Errorf("Sleep and NewTimer took %v, want %v", got, 2*d)
+	}
+}
+
+func TestFakeClockAdvance(t *testing.T) {
+	fc := NewFakeClock()
+	start := fc.Now()
+	var mu sync.Mutex
+	var log []string
+	record := func(what string) {
+		mu.Lock()
+		log = append(log, fmt.Sprintf("%v %s", fc.Now().Sub(start), what))
+		mu.Unlock()
+	}
+	ticks := 0
+	ticker := fc.TickerFunc(time.Second, func(time.Time) { ticks++ })
+	defer ticker.Stop()
+	fc.AfterFunc(2500*time.Millisecond, func() {
+		record("a")
+		// Due within the advance, so it fires before Advance returns.
+		fc.AfterFunc(time.Second, func() { record("c") })
+		// Due after it.
+		fc.AfterFunc(time.Hour, func() { record("d") })
+	})
+	fc.AfterFunc(3*time.Second, func() { record("b") })
+
+	fc.Advance(10 * time.Second)
+	mu.Lock()
+	got := strings.Join(log, ", ")
+	mu.Unlock()
+	if want := "2.5s a, 3s b, 3.5s c"; got != want {
+		t.Errorf("callbacks ran as %q, want %q", got, want)
+	}
+	if ticks != 10 {
+		t.Errorf("ticker ticked %d times in 10 periods", ticks)
+	}
+	if got := fc.Now().Sub(start); got != 10*time.Second {
+		t.Errorf("clock advanced by %v, want 10s", got)
+	}
+
+	// Advancing by zero fires the timers that are due already, and only them.
+	zero := false
+	fc.AfterFunc(0, func() { zero = true })
+	fc.Advance(0)
+	if !zero {
+		t.Error("Advance(0) did not fire a timer with duration 0")
+	}
+	if ticks != 10 {
+		t.Errorf("Advance(0) ticked the ticker")
+	}
+}
+
+func TestFakeClockSetTime(t *testing.T) {
+	fc := NewFakeClock()
+	newYear := time.Date(2024, time.December, 31, 23, 59, 59, 0, time.UTC)
+	before := fc.NewTimerAt(newYear.Add(-time.Hour))
+	at := fc.NewTimerAt(newYear)
+	after := fc.NewTimerAt(newYear.Add(time.Second))
+	fc.SetTime(newYear)
+	for _, timer := range []*Timer{before, at} {
+		if s := timer.State(); s != Fired {
+			t.Errorf("timer due by the new time is %v", s)
+		}
+	}
+	if s := after.State(); s != Scheduled {
+		t.Errorf("timer due after the new time is %v", s)
+	}
+	if got := fc.Now(); !got.Equal(newYear) {
+		t.Errorf("Now is %v after SetTime(%v)", got, newYear)
+	}
+
+	// Backward, the pending timers keep their deadlines, and the new ones are due relative to the
+	// new time.
+	fc.SetTime(newYear.Add(-time.Hour))
+	fresh := fc.NewTimer(30 * time.Minute)
+	if s := after.State(); s != Scheduled {
+		t.Errorf("timer is %v after the clock moved back", s)
+	}
+	if d := after.Remaining(); d != time.Hour+time.Second {
+		t.Errorf("timer has %v left after the clock moved back an hour, want 1h0m1s", d)
+	}
+	fc.Advance(30 * time.Minute)
+	if s, s2 := fresh.State(), after.State(); s != Fired || s2 != Scheduled {
+		t.Errorf("after 30 minutes, the new timer is %v and the old one %v", s, s2)
+	}
+	fc.Advance(30*time.Minute + time.Second)
+	if s := after.State(); s != Fired {
+		t.Errorf("timer is %v once its deadline is reached again", s)
+	}
+}
+
+// Reset timers while the clock is being set, from callbacks that run during SetTime and from
+// another goroutine.
+func TestFakeClockSetTimeReset(t *testing.T) {
+	fc := NewFakeClock()
+	start := fc.Now()
+	target := fc.NewTimer(time.Hour)
+	// Pushed past the new time by a callback: it must not fire.
+	fc.AfterFunc(time.Minute, func() { target.Reset(2 * time.Hour) })
+	fc.SetTime(start.Add(90 * time.Minute))
+	if s := target.State(); s != Scheduled {
+		t.Fatalf("timer reset past the new time during SetTime is %v", s)
+	}
+	if d, _ := target.Deadline(); !d.Equal(start.Add(time.Minute + 2*time.Hour)) {
+		t.Errorf("timer reset by a callback is due at %v, want %v", d, start.Add(time.Minute+2*time.Hour))
+	}
+
+	timers := make([]*Timer, 100)
+	for i := range timers {
+		timers[i] = fc.NewTimer(time.Duration(i) * time.Second)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, timer := range timers {
+			timer.Reset(time.Hour)
+		}
+	}()
+	fc.SetTime(fc.Now().Add(time.Minute))
+	fc.SetTime(start)
+	<-done
+	// Every Reset made the timer due an hour after the time of the clock then: before SetTime, on the
+	// way forward, or after the clock moved back.  None is ever due within the minute of the advance,
+	// so all are pending.
+	t0 := start.Add(90 * time.Minute)
+	for i, timer := range timers {
+		d, _ := timer.Deadline()
+		back := d.Equal(start.Add(time.Hour))
+		forward := !d.Before(t0.Add(time.Hour)) && !d.After(t0.Add(time.Hour+time.Minute))
+		if s := timer.State(); s != Scheduled || !back && !forward {
+			t.Errorf("timer %d is %v, due at %v", i, s, d)
+		}
+	}
+}
+
+func TestBlockUntilTimers(t *testing.T) {
+	fc := NewFakeClock()
+	fc.BlockUntilTimers(0)
+
+	fired := make(chan struct{}, 3)
+	go func() {
+		// The code under test starts its timers some time after the test starts it.
+		time.Sleep(margin / 2)
+		for i := 0; i < 3; i++ {
+			fc.AfterFunc(time.Duration(i+1)*time.Second, func() { fired <- struct{}{} })
+		}
+	}()
+	fc.BlockUntilTimers(3)
+	fc.Advance(3 * time.Second)
+	if n := len(fired); n != 3 {
+		t.Errorf("%d timers fired after BlockUntilTimers(3) and Advance", n)
+	}
+	// The timers are gone now, and a waiter is woken up by removals as well as additions.
+	timers := []*Timer{fc.NewTimer(time.Hour), fc.NewTimer(time.Hour)}
+	stopped := make(chan struct{})
+	go func() {
+		fc.BlockUntilTimers(3)
+		close(stopped)
+	}()
+	time.Sleep(margin / 2)
+	timers[0].Stop()
+	fc.NewTimer(time.Hour)
+	fc.NewTimer(time.Hour)
+	select {
+	case <-stopped:
+	case <-time.After(margin):
+		t.Fatal("BlockUntilTimers(3) blocked with 3 timers pending")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), margin/2)
+	defer cancel()
+	if err := fc.BlockUntilTimersContext(ctx, 10); err != context.DeadlineExceeded {
+		t.Errorf("BlockUntilTimersContext with too few timers returned %v", err)
+	}
+}
+
+func TestAutoAdvance(t *testing.T) {
+	fc := NewFakeClock()
+	fc.AutoAdvance(true)
+	start := fc.Now()
+	realStart := time.Now()
+
+	// A day of hourly work runs at once, with the timers of the test firing on the way.
+	var fired []time.Duration
+	fc.AfterFunc(90*time.Minute, func() { fired = append(fired, fc.Now().Sub(start)) })
+	for i := 0; i < 24; i++ {
+		fc.Sleep(time.Hour)
+	}
+	if got := fc.Now().Sub(start); got != 24*time.Hour {
+		t.Errorf("24 sleeps of an hour moved the clock by %v", got)
+	}
+	if len(fired) != 1 || fired[0] != 90*time.Minute {
+		t.Errorf("timer of the test fired at %v, want once at 1h30m", fired)
+	}
+
+	ticker := fc.NewTicker(time.Minute)
+	for i := 1; i <= 3; i++ {
+		if tick, ok := fc.Wait(ticker.C); !ok || tick.Sub(start) != 24*time.Hour+time.Duration(i)*time.Minute {
+			t.Errorf("tick %d at %v, %v", i, tick.Sub(start), ok)
+		}
+	}
+
+	// Nothing ever sends on c, but the ticker keeps the clock going, until Wait gives up.
+	fc.maxLeaps = 1000
+	c := make(chan time.Time)
+	if _, ok := fc.Wait(c); ok {
+		t.Error("Wait on a channel that nothing sends on succeeded")
+	}
+	ticker.Stop()
+	if _, ok := fc.Wait(c); ok {
+		t.Error("Wait succeeded with no timer pending")
+	}
+	if d := time.Since(realStart); d >= margin {
+		t.Errorf("auto-advanced test took %v of real time", d)
+	}
+}
+
+func TestWithNowFunc(t *testing.T) {
+	// A clock that runs twice as fast as real time.
+	start := time.Now()
+	fast := func() time.Time { return start.Add(2 * time.Since(start)) }
+	clk := NewScheduler(WithNowFunc(fast), WithWaitFunc(func(until time.Time) time.Duration {
+		return until.Sub(fast()) / 2
+	}))
+	const d = 100 * time.Millisecond
+	timer := clk.NewTimer(d)
+	armed, _ := timer.Deadline()
+	<-timer.C
+	if got := time.Since(start); got < d/2 || got >= d/2+margin {
+		t.Errorf("timer of %v on a clock twice as fast fired after %v of real time", d, got)
+	}
+	if late := clk.Now().Sub(armed); late < 0 || late >= 2*margin {
+		t.Errorf("timer fired %v after its deadline by the fast clock", late)
+	}
+
+	// A clock that steps back while timers are pending: they fire later, but in deadline order.
+	var back atomic.Int64
+	stepping := NewScheduler(WithNowFunc(func() time.Time { return time.Now().Add(-time.Duration(back.Load())) }))
+	var r fireRecorder
+	stepping.RegisterObserver(&r)
+	var timers []*Timer
+	for i := 3; i > 0; i-- {
+		timers = append(timers, stepping.NewTimer(time.Duration(i)*10*time.Millisecond))
+	}
+	back.Store(int64(20 * time.Millisecond))
+	stepped := time.Now()
+	<-timers[0].C
+	if got := time.Since(stepped); got < 40*time.Millisecond {
+		t.Errorf("last timer fired %v after the clock stepped back by 20ms, want at least 40ms", got)
+	}
+	time.Sleep(margin / 2)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.fired) != 3 || r.fired[0] != timers[2] || r.fired[1] != timers[1] || r.fired[2] != timers[0] {
+		t.Errorf("timers fired in the order %v", r.fired)
+	}
+}
+
+func TestSetNowFunc(t *testing.T) {
+	frozen := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+	SetNowFunc(func() time.Time { return frozen })
+	defer SetNowFunc(nil)
+	clk := NewScheduler()
+	defer clk.Shutdown(context.Background())
+	if now := clk.Now(); !now.Equal(frozen) {
+		t.Errorf("Now returned %v, want %v", now, frozen)
+	}
+	timer := clk.NewTimer(time.Hour)
+	for i := 0; i < 3; i++ {
+		if left := timer.Remaining(); left != time.Hour {
+			t.Errorf("Remaining returned %v on a frozen clock, want 1h", left)
+		}
+		time.Sleep(margin / 10)
+	}
+	if deadline, _ := timer.Deadline(); !deadline.Equal(frozen.Add(time.Hour)) {
+		t.Errorf("timer armed for %v, want %v", deadline, frozen.Add(time.Hour))
+	}
+
+	// Swapping the function while the timer is pending makes it due by the new one.
+	SetNowFunc(func() time.Time { return frozen.Add(2 * time.Hour) })
+	timer.Reset(time.Hour)
+	if left := timer.Remaining(); left != time.Hour {
+		t.Errorf("Remaining returned %v after the clock moved, want 1h", left)
+	}
+	timer.Stop()
+
+	// Restoring time.Now leaves every Scheduler, the default one included, firing timers.
+	SetNowFunc(nil)
+	select {
+	case <-NewTimer(margin / 10).C:
+	case <-time.After(20 * margin):
+		t.Fatal("timer of the default Scheduler did not fire after SetNowFunc(nil)")
+	}
+}
+
+// A timer routine that went to sleep by a function that SetNowFunc has replaced since must not
+// keep sleeping by it.
+func TestSetNowFuncWakesRoutine(t *testing.T) {
+	clk := NewScheduler()
+	defer clk.Shutdown(context.Background())
+	pending := clk.NewTimer(margin / 2)
+	defer pending.Stop()
+	SetNowFunc(func() time.Time { return time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC) })
+	clk.NewTimer(time.Hour).Stop()
+	// Let the routine go back to sleep by the function.
+	time.Sleep(margin / 10)
+	SetNowFunc(nil)
+	select {
+	case <-clk.NewTimer(margin).C:
+	case <-time.After(20 * margin):
+		t.Fatal("timer did not fire after SetNowFunc(nil)")
+	}
+}
@@ -0,0 +1,233 @@
+package kairos
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A timerCtx is a context that is canceled by a kairos timer when its deadline passes.  The
+// embedded context is canceled along with it, with the same cause, and serves Value and
+// context.Cause.  Done and Err are its own, so that the contexts derived from a timerCtx by the
+// standard library do not copy the error of the embedded context, which is always
+// context.Canceled: they register with AfterFunc instead, which costs no goroutine either.
+type timerCtx struct {
+	context.Context
+	cancel   context.CancelCauseFunc // Cancels the embedded context.
+	deadline time.Time
+	timer    *Timer
+	done     chan struct{}
+
+	mu    sync.Mutex       // protects:
+	err   error            // Set, with done closed, by the first cancellation.
+	after map[*func()]bool // Registered by AfterFunc and not yet called or stopped.
+}
+
+func (c *timerCtx) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *timerCtx) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *timerCtx) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// AfterFunc arranges for f to be called in its own goroutine once c is done, like
+// context.AfterFunc, which calls it for c.
+func (c *timerCtx) AfterFunc(f func()) func() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		go f()
+		return func() bool { return false }
+	}
+	key := &f
+	c.after[key] = true
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		stopped := c.after[key]
+		delete(c.after, key)
+		return stopped
+	}
+}
+
+// Cancel c with err, and the embedded context with cause, unless c is already canceled.
+func (c *timerCtx) end(err, cause error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	// Before Done is closed, so that the cause is known by then.
+	c.cancel(cause)
+	c.err = err
+	close(c.done)
+	after := c.after
+	c.after = nil
+	c.mu.Unlock()
+	for f := range after {
+		go (*f)()
+	}
+}
+
+// Called by the timer when the deadline passes.
+func (c *timerCtx) expire() {
+	c.end(context.DeadlineExceeded, context.DeadlineExceeded)
+}
+
+// ContextWithTimeout is like context.WithTimeout, but the deadline is enforced by a timer of clk.
+func (clk *Scheduler) ContextWithTimeout(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	return clk.ContextWithDeadline(parent, clk.now().Add(d))
+}
+
+// ContextWithDeadline is like context.WithDeadline, but the deadline is enforced by a timer of clk.
+func (clk *Scheduler) ContextWithDeadline(parent context.Context, deadline time.Time) (context.Context, context.CancelFunc) {
+	if cur, ok := parent.Deadline(); ok && cur.Before(deadline) {
+		// The parent is canceled sooner, so no timer is needed.
+		return context.WithCancel(parent)
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+	c := &timerCtx{Context: ctx, cancel: cancel, deadline: deadline, done: make(chan struct{}), after: make(map[*func()]bool)}
+	if !clk.now().Before(deadline) {
+		c.expire()
+		return c, func() {}
+	}
+	if err := ctx.Err(); err != nil {
+		c.end(err, context.Cause(ctx))
+		return c, func() {}
+	}
+	c.timer = clk.AfterFunc(deadline.Sub(clk.now()), c.expire)
+	if parent.Done() != nil {
+		// Pass the cancellation of parent on to c, and take the timer out of the heap as soon as
+		// parent is canceled.  Otherwise only cancel and the timer itself can cancel c, and both
+		// take care of the timer.
+		context.AfterFunc(ctx, func() {
+			c.end(ctx.Err(), context.Cause(ctx))
+			c.timer.Stop()
+		})
+	}
+	return c, func() {
+		c.end(context.Canceled, context.Canceled)
+		// Stop the timer right away instead of waiting for the function registered above.
+		c.timer.Stop()
+	}
+}
+
+// ContextWithDeadline returns a copy of parent that is canceled when the
+// returned cancel function is called, when parent is canceled, or when the
+// deadline passes, whichever happens first, like context.WithDeadline. The
+// difference is that the deadline is enforced by a kairos timer on the shared
+// heap instead of a runtime timer. If the parent's deadline is already earlier,
+// the returned context has the parent's deadline and needs no timer at all.
+// Calling cancel removes the timer from the heap, and so does the cancellation
+// of parent; cancel may be called more than once.
+func ContextWithDeadline(parent context.Context, deadline time.Time) (context.Context, context.CancelFunc) {
+	return defaultScheduler.ContextWithDeadline(parent, deadline)
+}
+
+// ContextWithTimeout returns ContextWithDeadline(parent, time.Now().Add(d)),
+// like context.WithTimeout. As with context.WithTimeout, cancel should be
+// called as soon as the operation is done, to remove the timer from the heap.
+func ContextWithTimeout(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	return defaultScheduler.ContextWithTimeout(parent, d)
+}
+
+// AfterFuncCtx is like AfterFunc, but the timer is stopped when ctx is done, and f receives ctx.
+func (clk *Scheduler) AfterFuncCtx(ctx context.Context, d time.Duration, f func(context.Context)) *Timer {
+	if ctx.Done() == nil {
+		// ctx is never canceled, so there is nothing to watch.
+		return clk.AfterFunc(d, func() { f(ctx) })
+	}
+	var stop func() bool
+	var mu sync.Mutex // Guards stop, which is set when the timer is started.
+	t := clk.NewStoppedFunc(func() {
+		mu.Lock()
+		stop()
+		mu.Unlock()
+		// The timer may have fired while ctx was being canceled.
+		if ctx.Err() != nil {
+			return
+		}
+		f(ctx)
+	})
+	if ctx.Err() != nil {
+		return t
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	stop = context.AfterFunc(ctx, func() { t.Stop() })
+	t.Reset(d)
+	return t
+}
+
+// AfterFuncCtx waits for the duration to elapse and then calls f(ctx) in its
+// own goroutine, unless ctx is done first, in which case the timer is removed
+// from the heap and f is never called. It returns the Timer, which can be
+// stopped like one returned by AfterFunc. ctx is only watched until the timer
+// fires; this costs no goroutine if ctx is a context of the standard library
+// (or context.Background, which is never canceled), and no watcher is left
+// behind after the timer fires.
+func AfterFuncCtx(ctx context.Context, d time.Duration, f func(context.Context)) *Timer {
+	return defaultScheduler.AfterFuncCtx(ctx, d, f)
+}
+
+// A binding of a Timer to a context, made by BindContext.
+type binding struct {
+	ctx  context.Context // Recorded in the event log.
+	stop func() bool     // Unregisters the function that stops the timer when the context is done.
+}
+
+// Bind the lifetime of t to ctx, replacing its previous binding.
+func (clk *Scheduler) bindContext(t *Timer, ctx context.Context) {
+	var b *binding
+	if ctx != nil && ctx.Done() != nil && ctx.Err() == nil {
+		b = &binding{ctx: ctx}
+		b.stop = context.AfterFunc(ctx, func() {
+			clk.lock()
+			current := t.bound == b
+			clk.unlock()
+			// A replaced binding may have been canceled before it was unregistered.
+			if current {
+				clk.stopAndDrainTimer(t)
+			}
+		})
+	}
+	clk.lock()
+	old := t.bound
+	t.bound = b
+	clk.unlock()
+	if old != nil {
+		old.stop()
+	}
+	if ctx != nil && ctx.Err() != nil {
+		clk.stopAndDrainTimer(t)
+	}
+}
+
+// RunWithTimeout runs f with a context that a timer of clk cancels after d.
+func (clk *Scheduler) RunWithTimeout(d time.Duration, f func(ctx context.Context) error) error {
+	ctx, cancel := clk.ContextWithTimeout(context.Background(), d)
+	err := f(ctx)
+	// Stops the timer if f returned before the timeout.
+	cancel()
+	if ctx.Err() == context.DeadlineExceeded {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// RunWithTimeout calls f in the calling goroutine with a context that is
+// canceled after d by a kairos timer, and returns the error returned by f, or
+// context.DeadlineExceeded if the timeout fired before f returned. f should
+// return promptly once the context is done; RunWithTimeout does not return
+// before f does. The timer is removed from the heap as soon as f returns, so
+// calling RunWithTimeout in a loop does not make the heap grow.
+func RunWithTimeout(d time.Duration, f func(ctx context.Context) error) error {
+	return defaultScheduler.RunWithTimeout(d, f)
+}
@@ -0,0 +1,338 @@
+package kairos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Wait until clk has no pending timers, which may take a moment when they are stopped by a function
+// registered with context.AfterFunc.
+func waitNoPending(t *testing.T, clk *Scheduler) {
+	t.Helper()
+	for start := time.Now(); clk.PendingCount() != 0; time.Sleep(time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("%v timers left in the heap", clk.PendingCount())
+		}
+	}
+}
+
+func TestContextWithTimeout(t *testing.T) {
+	clk := NewScheduler()
+	const d = 100 * time.Millisecond
+	start := time.Now()
+	ctx, cancel := clk.ContextWithTimeout(context.Background(), d)
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || deadline.Before(start.Add(d)) || deadline.After(time.Now().Add(d)) {
+		t.Errorf("wrong deadline; got %v, %v after start, want %v", deadline.Sub(start), ok, d)
+	}
+	if err := ctx.Err(); err != nil {
+		t.Errorf("Err() = %v before the deadline", err)
+	}
+	if n := clk.PendingCount(); n != 1 {
+		t.Errorf("%v timers pending, want 1", n)
+	}
+	child, cancelChild := context.WithCancel(ctx)
+	defer cancelChild()
+	select {
+	case <-child.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("context not canceled")
+	}
+	if got := time.Since(start); got < d || got >= d+margin {
+		t.Errorf("canceled after %v, want %v", got, d)
+	}
+	if err := ctx.Err(); err != context.DeadlineExceeded {
+		t.Errorf("Err() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := context.Cause(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Cause() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := clk.PendingCount(); n != 0 {
+		t.Errorf("%v timers pending after the deadline", n)
+	}
+}
+
+func TestContextWithTimeoutChild(t *testing.T) {
+	clk := NewScheduler()
+	ctx, cancel := clk.ContextWithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	child, cancelChild := context.WithCancel(ctx)
+	defer cancelChild()
+	grandchild, cancelGrandchild := context.WithTimeout(child, time.Hour)
+	defer cancelGrandchild()
+	stopped := context.AfterFunc(ctx, func() {})
+	if !stopped() {
+		t.Errorf("function registered with context.AfterFunc did not stop")
+	}
+	called := make(chan struct{})
+	context.AfterFunc(ctx, func() { close(called) })
+	select {
+	case <-grandchild.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("derived context not canceled")
+	}
+	<-called
+	for _, c := range []context.Context{child, grandchild} {
+		if err := c.Err(); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Err() of derived context = %v, want %v", err, context.DeadlineExceeded)
+		}
+		if err := context.Cause(c); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Cause() of derived context = %v, want %v", err, context.DeadlineExceeded)
+		}
+	}
+}
+
+func TestContextWithTimeoutCancel(t *testing.T) {
+	clk := NewScheduler()
+	ctx, cancel := clk.ContextWithTimeout(context.Background(), time.Hour)
+	cancel()
+	if n := clk.PendingCount(); n != 0 {
+		t.Errorf("%v timers pending after cancel", n)
+	}
+	<-ctx.Done()
+	if err := ctx.Err(); err != context.Canceled {
+		t.Errorf("Err() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestContextWithTimeoutParent(t *testing.T) {
+	clk := NewScheduler()
+	errParent := errors.New("parent canceled")
+	parent, cancelParent := context.WithCancelCause(context.Background())
+	ctx, cancel := clk.ContextWithTimeout(parent, time.Hour)
+	defer cancel()
+	cancelParent(errParent)
+	<-ctx.Done()
+	if err := ctx.Err(); err != context.Canceled {
+		t.Errorf("Err() = %v, want %v", err, context.Canceled)
+	}
+	if err := context.Cause(ctx); err != errParent {
+		t.Errorf("Cause() = %v, want %v", err, errParent)
+	}
+	waitNoPending(t, clk)
+}
+
+func TestContextWithTimeoutExpired(t *testing.T) {
+	clk := NewScheduler()
+	ctx, cancel := clk.ContextWithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	select {
+	case <-ctx.Done():
+	default:
+		t.Errorf("context with expired deadline not done")
+	}
+	if err := ctx.Err(); err != context.DeadlineExceeded {
+		t.Errorf("Err() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := clk.PendingCount(); n != 0 {
+		t.Errorf("%v timers pending", n)
+	}
+}
+
+func TestContextWithDeadlineOrderings(t *testing.T) {
+	const d = 50 * time.Millisecond
+	for _, tc := range []struct {
+		name    string
+		do      func(cancelParent, cancel context.CancelFunc)
+		wantErr error
+	}{
+		{"ParentFirst", func(cancelParent, cancel context.CancelFunc) { cancelParent() }, context.Canceled},
+		{"DeadlineFirst", func(cancelParent, cancel context.CancelFunc) { time.Sleep(2 * d) }, context.DeadlineExceeded},
+		{"CancelFirst", func(cancelParent, cancel context.CancelFunc) { cancel() }, context.Canceled},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clk := NewScheduler()
+			parent, cancelParent := context.WithCancel(context.Background())
+			ctx, cancel := clk.ContextWithDeadline(parent, time.Now().Add(d))
+			tc.do(cancelParent, cancel)
+			<-ctx.Done()
+			if err := ctx.Err(); err != tc.wantErr {
+				t.Errorf("Err() = %v, want %v", err, tc.wantErr)
+			}
+			// The others must not change the outcome, and calling cancel again is safe.
+			cancel()
+			cancelParent()
+			cancel()
+			if err := ctx.Err(); err != tc.wantErr {
+				t.Errorf("Err() = %v after all cancellations, want %v", err, tc.wantErr)
+			}
+			waitNoPending(t, clk)
+		})
+	}
+}
+
+func TestContextWithDeadlineParentSooner(t *testing.T) {
+	clk := NewScheduler()
+	want := time.Now().Add(time.Minute)
+	parent, cancelParent := context.WithDeadline(context.Background(), want)
+	defer cancelParent()
+	ctx, cancel := clk.ContextWithDeadline(parent, want.Add(time.Hour))
+	defer cancel()
+	if got, ok := ctx.Deadline(); !ok || !got.Equal(want) {
+		t.Errorf("Deadline() = %v, %v; want the parent's %v", got, ok, want)
+	}
+	if n := clk.PendingCount(); n != 0 {
+		t.Errorf("%v timers pending, want none", n)
+	}
+}
+
+func TestAfterFuncCtx(t *testing.T) {
+	type key struct{}
+	for _, tc := range []struct {
+		name   string
+		parent context.Context
+	}{
+		{"Background", context.Background()},
+		{"Cancelable", func() context.Context {
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			return ctx
+		}()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clk := NewScheduler()
+			ctx := context.WithValue(tc.parent, key{}, 42)
+			called := make(chan context.Context, 1)
+			clk.AfterFuncCtx(ctx, 10*time.Millisecond, func(ctx context.Context) { called <- ctx })
+			select {
+			case got := <-called:
+				if got.Value(key{}) != 42 {
+					t.Errorf("f received the wrong context")
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("f not called")
+			}
+			if n := clk.PendingCount(); n != 0 {
+				t.Errorf("%v timers pending after the fire", n)
+			}
+		})
+	}
+}
+
+func TestAfterFuncCtxCancel(t *testing.T) {
+	clk := NewScheduler()
+	ctx, cancel := context.WithCancel(context.Background())
+	called := make(chan struct{}, 1)
+	clk.AfterFuncCtx(ctx, 20*time.Millisecond, func(context.Context) { called <- struct{}{} })
+	cancel()
+	waitNoPending(t, clk)
+	select {
+	case <-called:
+		t.Errorf("f called after ctx was canceled")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A context that is already done never starts the timer.
+	clk.AfterFuncCtx(ctx, 0, func(context.Context) { called <- struct{}{} })
+	if n := clk.PendingCount(); n != 0 {
+		t.Errorf("%v timers pending for a done context", n)
+	}
+}
+
+func TestBindContext(t *testing.T) {
+	clk := NewScheduler()
+	timer := clk.NewTimer(0)
+	time.Sleep(10 * time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	timer.BindContext(ctx)
+	timer.ResetKeepPending(time.Hour)
+	cancel()
+	waitNoPending(t, clk)
+	if n := len(timer.C); n != 0 {
+		t.Errorf("%v values left on the channel", n)
+	}
+	if got := timer.State(); got != Stopped {
+		t.Errorf("state is %v after cancel, want %v", got, Stopped)
+	}
+}
+
+func TestBindContextReplace(t *testing.T) {
+	clk := NewScheduler()
+	timer := clk.NewTimer(time.Hour)
+	defer timer.Stop()
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	timer.BindContext(ctx1)
+	timer.BindContext(ctx2)
+	cancel1()
+	time.Sleep(10 * time.Millisecond)
+	if got := timer.State(); got != Scheduled {
+		t.Errorf("replaced context stopped the timer")
+	}
+	// Binding a done context stops the timer synchronously.
+	timer.BindContext(ctx1)
+	if got := timer.State(); got != Stopped {
+		t.Errorf("state is %v after binding a done context, want %v", got, Stopped)
+	}
+}
+
+func TestNewTimerFuncCtx(t *testing.T) {
+	ctxs := make(chan context.Context, 1)
+	timer := NewTimerFuncCtx(10*time.Millisecond, func(ctx context.Context, fired time.Time) {
+		ctxs <- ctx
+	})
+	ctx1 := <-ctxs
+	time.Sleep(10 * time.Millisecond)
+	if err := ctx1.Err(); err != nil {
+		t.Errorf("context canceled by the fire: %v", err)
+	}
+	timer.Reset(10 * time.Millisecond)
+	if ctx1.Err() == nil {
+		t.Errorf("Reset did not cancel the old context")
+	}
+	ctx2 := <-ctxs
+	if ctx2 == ctx1 || ctx2.Err() != nil {
+		t.Errorf("Reset did not create a new context")
+	}
+	timer.Stop()
+	if ctx2.Err() == nil {
+		t.Errorf("Stop did not cancel the context")
+	}
+}
+
+func TestNewTimerFuncCtxInterrupt(t *testing.T) {
+	started := make(chan struct{})
+	timer := NewTimerFuncCtx(0, func(ctx context.Context, fired time.Time) {
+		close(started)
+		<-ctx.Done()
+	})
+	<-started
+	timer.StopWait()
+}
+
+func TestRunWithTimeout(t *testing.T) {
+	clk := NewScheduler()
+	errTest := errors.New("test")
+	if err := clk.RunWithTimeout(time.Hour, func(ctx context.Context) error { return errTest }); err != errTest {
+		t.Errorf("RunWithTimeout() = %v, want %v", err, errTest)
+	}
+	if n := clk.PendingCount(); n != 0 {
+		t.Errorf("%v timers pending after early return", n)
+	}
+	err := clk.RunWithTimeout(10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("RunWithTimeout() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func BenchmarkRunWithTimeout(b *testing.B) {
+	f := func(ctx context.Context) error { return nil }
+	b.Run("kairos", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			RunWithTimeout(time.Hour, f)
+		}
+	})
+	b.Run("context.WithTimeout", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+			f(ctx)
+			cancel()
+		}
+	})
+}
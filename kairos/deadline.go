@@ -0,0 +1,85 @@
+package kairos
+
+import "time"
+
+// NextDeadline returns the earliest deadline of the pending timers of clk (or of its shards), and
+// false if none is pending.
+func (clk *Scheduler) NextDeadline() (time.Time, bool) {
+	if len(clk.shards) > 0 {
+		var next time.Time
+		found := false
+		for _, s := range clk.shards {
+			if when, ok := s.NextDeadline(); ok && (!found || when.Before(next)) {
+				next, found = when, true
+			}
+		}
+		return next, found
+	}
+	clk.lock()
+	defer clk.unlock()
+	return clk.earliestLocked()
+}
+
+// NextDeadlineChanged returns a channel that receives a value after the earliest deadline of the
+// pending timers of clk (or of one of its shards) has changed; see the package function.
+func (clk *Scheduler) NextDeadlineChanged() <-chan struct{} {
+	clk.lock()
+	c := clk.deadlineC
+	if c == nil {
+		c = make(chan struct{}, 1)
+		clk.deadlineC = c
+		clk.lastDeadline, _ = clk.earliestLocked()
+	}
+	clk.unlock()
+	for _, s := range clk.shards {
+		s.lock()
+		if s.deadlineC == nil {
+			s.deadlineC = c
+			s.lastDeadline, _ = s.earliestLocked()
+		}
+		s.unlock()
+	}
+	return c
+}
+
+// Return the earliest deadline of the timers of clk, and false (with the zero time) if there is
+// none.  The caller must hold the mutex.
+func (clk *Scheduler) earliestLocked() (time.Time, bool) {
+	if t := clk.timers.Earliest(); t != nil {
+		return t.when, true
+	}
+	return time.Time{}, false
+}
+
+// Signal deadlineC if the earliest deadline has changed since the last call.  The caller must hold
+// the mutex, and be about to release it.
+func (clk *Scheduler) checkDeadlineLocked() {
+	next, _ := clk.earliestLocked()
+	if next.Equal(clk.lastDeadline) {
+		return
+	}
+	clk.lastDeadline = next
+	select {
+	case clk.deadlineC <- struct{}{}:
+	default:
+	}
+}
+
+// NextDeadline returns the earliest deadline of the pending timers of the
+// default Scheduler, that is, when the next timer will fire (unless it is
+// stopped or reset, or an earlier one is started, in the meantime), and false
+// if no timer is pending. Resets that have returned are taken into account.
+func NextDeadline() (time.Time, bool) {
+	return defaultScheduler.NextDeadline()
+}
+
+// NextDeadlineChanged returns a channel that receives a value whenever the
+// earliest deadline of the pending timers of the default Scheduler has changed,
+// so that an event loop that waits by other means (epoll, say) can call
+// NextDeadline again and adjust its own timeout. Changes in quick succession
+// may be signaled once, and the channel is the same for every call, so there
+// should be a single receiver. Until the first call, no changes are tracked,
+// which costs nothing.
+func NextDeadlineChanged() <-chan struct{} {
+	return defaultScheduler.NextDeadlineChanged()
+}
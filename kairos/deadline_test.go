@@ -0,0 +1,54 @@
+package kairos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextDeadline(t *testing.T) {
+	for _, opts := range [][]SchedulerOption{nil, {WithShards(4)}, {WithTimingWheel(time.Millisecond, 64)}} {
+		s := NewScheduler(opts...)
+		// A change of the deadline of any shard is signaled.
+		sharded := len(s.shards) > 0
+		if when, ok := s.NextDeadline(); ok {
+			t.Errorf("NextDeadline() = %v without timers", when)
+		}
+		changed := s.NextDeadlineChanged()
+		check := func(want *Timer, signaled bool) {
+			t.Helper()
+			select {
+			case <-changed:
+				if !signaled && !sharded {
+					t.Errorf("change of the next deadline signaled, but it did not change")
+				}
+			case <-time.After(10 * time.Millisecond):
+				if signaled {
+					t.Errorf("change of the next deadline not signaled")
+				}
+			}
+			when, ok := s.NextDeadline()
+			if want == nil {
+				if ok {
+					t.Errorf("NextDeadline() = %v without pending timers", when)
+				}
+				return
+			}
+			if d, _ := want.Deadline(); !ok || !when.Equal(d) {
+				t.Errorf("NextDeadline() = %v, %v; want %v", when, ok, d)
+			}
+		}
+		// Far apart, so that they are on different levels of the wheel.
+		late := s.NewTimer(time.Hour)
+		check(late, true)
+		early := s.NewTimer(time.Minute + 10*time.Second)
+		check(early, true)
+		s.NewTimer(2 * time.Hour).Stop()
+		check(early, false)
+		early.Reset(3 * time.Hour)
+		check(late, true)
+		late.Stop()
+		check(early, true)
+		early.Stop()
+		check(nil, true)
+	}
+}
@@ -0,0 +1,107 @@
+package kairos
+
+import (
+	"time"
+	"unsafe"
+)
+
+// An Event is sent on the channel of an EventTimer when it expires.
+type Event struct {
+	Scheduled time.Time     // The deadline the timer was armed with.
+	Actual    time.Time     // The time at which the timer routine processed the expiry.
+	Late      time.Duration // With WithLateness, Actual.Sub(Scheduled): the scheduling delay, excluding consumer delay.
+}
+
+// An EventTimer is like a Timer, but the value sent on C when it expires carries
+// the scheduled deadline in addition to the actual fire time.
+// An EventTimer must be created with NewEventTimer.
+type EventTimer struct {
+	C <-chan Event
+	c chan Event // Same channel as C.
+
+	t Timer
+}
+
+func newEventTimer(clk *Scheduler) *EventTimer {
+	c := make(chan Event, 1)
+	et := &EventTimer{C: c, c: c}
+	et.t.clk = clk.shard(unsafe.Pointer(et))
+	et.t.i = -1
+	et.t.send = et.send
+	et.t.drain = et.drain
+	return et
+}
+
+// NewEventTimer creates a new [EventTimer] of clk that sends an [Event] after duration d.
+func (clk *Scheduler) NewEventTimer(d time.Duration, opts ...Option) *EventTimer {
+	et := newEventTimer(clk)
+	for _, opt := range opts {
+		opt(&et.t)
+	}
+	et.t.clk.resetTimer(&et.t, et.t.clk.now().Add(d))
+	return et
+}
+
+// NewEventTimer creates a new EventTimer that will send an Event on its channel
+// after at least duration d. Its Events only carry their lateness if it is
+// created with WithLateness.
+func NewEventTimer(d time.Duration, opts ...Option) *EventTimer {
+	return defaultScheduler.NewEventTimer(d, opts...)
+}
+
+// Stop prevents the EventTimer from firing. It behaves like Timer.Stop.
+func (et *EventTimer) Stop() bool {
+	if et.c == nil {
+		panic("timer: Stop called on uninitialized EventTimer")
+	}
+	return et.t.clk.delTimer(&et.t)
+}
+
+// Reset changes the timer to expire after duration d. It behaves like
+// Timer.Reset: the channel et.C is cleared.
+func (et *EventTimer) Reset(d time.Duration) bool {
+	if et.c == nil {
+		panic("timer: Reset called on uninitialized EventTimer")
+	}
+	return et.t.clk.resetTimer(&et.t, et.t.clk.now().Add(d))
+}
+
+// MaxLateness returns the worst lateness observed so far, that is, the longest
+// delay between a timer's deadline and the time at which the timer routine
+// processed its expiry. It does not take a lock, so it is cheap enough for
+// frequent monitoring.
+func MaxLateness() time.Duration {
+	return defaultScheduler.MaxLateness()
+}
+
+// MaxLateness returns the worst lateness of the timers of clk observed so far.
+func (clk *Scheduler) MaxLateness() time.Duration {
+	if clk.shards != nil {
+		var late time.Duration
+		for _, s := range clk.shards {
+			late = max(late, s.MaxLateness())
+		}
+		return late
+	}
+	return time.Duration(clk.maxLate.Load())
+}
+
+// Called by the timer routine with the clock mutex held.
+func (et *EventTimer) send(e expiry) {
+	ev := Event{Scheduled: e.scheduled, Actual: e.actual}
+	if et.t.late {
+		ev.Late = e.actual.Sub(e.scheduled)
+	}
+	select {
+	case et.c <- ev:
+	default:
+	}
+}
+
+// Called with the clock mutex held.
+func (et *EventTimer) drain() {
+	select {
+	case <-et.c:
+	default:
+	}
+}
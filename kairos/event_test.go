@@ -0,0 +1,74 @@
+package kairos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventTimer(t *testing.T) {
+	const want = 100 * time.Millisecond
+	start := time.Now()
+	et := NewEventTimer(want, WithLateness())
+	select {
+	case ev := <-et.C:
+		if got := ev.Scheduled.Sub(start); got < want || got >= want+margin {
+			t.Errorf("wrong scheduled time; got duration %v, want %v", got, want)
+		}
+		if ev.Actual.Before(ev.Scheduled) {
+			t.Errorf("timer fired early; actual %v is before scheduled %v", ev.Actual, ev.Scheduled)
+		}
+		if got, want := ev.Late, ev.Actual.Sub(ev.Scheduled); got != want {
+			t.Errorf("wrong lateness; got %v, want %v", got, want)
+		}
+		if ev.Late >= margin {
+			t.Errorf("timer fired too late; got lateness %v", ev.Late)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timer did not fire")
+	}
+	if et.Reset(time.Hour) {
+		t.Errorf("reset timer: was active is true")
+	}
+	if !et.Stop() {
+		t.Errorf("stop timer: was active is false")
+	}
+}
+
+func TestEventTimerWithoutLateness(t *testing.T) {
+	// Overdue, so that the lateness would not be zero.
+	ev := <-NewEventTimer(-time.Hour).C
+	if ev.Late != 0 {
+		t.Errorf("lateness without WithLateness = %v, want 0", ev.Late)
+	}
+	if late := ev.Actual.Sub(ev.Scheduled); late < time.Hour {
+		t.Errorf("timer an hour overdue fired %v late", late)
+	}
+}
+
+func TestAfterFuncScheduled(t *testing.T) {
+	const want = 100 * time.Millisecond
+	type times struct{ scheduled, actual time.Time }
+	done := make(chan times, 1)
+	start := time.Now()
+	AfterFuncScheduled(want, func(scheduled, actual time.Time) { done <- times{scheduled, actual} })
+	select {
+	case got := <-done:
+		if d := got.scheduled.Sub(start); d < want || d >= want+margin {
+			t.Errorf("wrong scheduled time; got duration %v, want %v", d, want)
+		}
+		if got.actual.Before(got.scheduled) {
+			t.Errorf("timer fired early; actual %v is before scheduled %v", got.actual, got.scheduled)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("callback was not called")
+	}
+}
+
+func TestMaxLateness(t *testing.T) {
+	// Overdue deadlines are late by at least how far in the past they are.
+	const late = time.Hour
+	<-NewTimerAt(time.Now().Add(-late)).C
+	if got := MaxLateness(); got < late {
+		t.Errorf("wrong max lateness; got %v, want >= %v", got, late)
+	}
+}
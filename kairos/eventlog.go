@@ -0,0 +1,172 @@
+package kairos
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// The operations recorded in the event log.
+type EventOp uint8
+
+const (
+	EventAdd   EventOp = iota + 1 // The timer was armed.
+	EventReset                    // The timer was re-armed while pending, by Reset or ResetAt.
+	EventStop                     // The timer was stopped before it fired.
+	EventFire                     // The timer expired.
+)
+
+func (op EventOp) String() string {
+	switch op {
+	case EventAdd:
+		return "add"
+	case EventReset:
+		return "reset"
+	case EventStop:
+		return "stop"
+	case EventFire:
+		return "fire"
+	}
+	return "EventOp(" + strconv.Itoa(int(op)) + ")"
+}
+
+// A RecordedEvent is an entry of the event log, as returned by RecentEvents.
+type RecordedEvent struct {
+	Op        EventOp
+	Timer     uintptr   // The address of the timer, which identifies it as long as it is referenced.
+	Name      string    // Set by SetName or WithName.
+	Scheduled time.Time // The deadline of the timer, or the one it had for EventStop.
+	Actual    time.Time // When the operation happened, or when the expiry was processed for EventFire.
+	// The context the timer is bound to by BindContext, if any, to tell which request or operation
+	// the timer belonged to.
+	Context context.Context
+}
+
+func (e RecordedEvent) String() string {
+	s := fmt.Sprintf("%v %v %#x", e.Actual.Format(time.RFC3339Nano), e.Op, e.Timer)
+	if e.Name != "" {
+		s += " " + strconv.Quote(e.Name)
+	}
+	return s + " due " + e.Scheduled.Format(time.RFC3339Nano)
+}
+
+// An eventRing holds the last events, overwriting the oldest one once it is full.  Each writer
+// claims the next slot with the cursor, and only locks that slot, so that writers only ever wait for
+// one another when they wrap around to the same slot at once.
+type eventRing struct {
+	cursor atomic.Uint64 // The number of events recorded so far.
+	slots  []eventSlot
+}
+
+type eventSlot struct {
+	busy atomic.Bool
+	seq  uint64 // The value of the cursor after ev was recorded, or 0 if the slot is empty.
+	ev   RecordedEvent
+}
+
+func (s *eventSlot) lock() {
+	for !s.busy.CompareAndSwap(false, true) {
+		runtime.Gosched()
+	}
+}
+
+func (s *eventSlot) unlock() {
+	s.busy.Store(false)
+}
+
+// Set by EnableEventLog.
+var eventLog atomic.Pointer[eventRing]
+
+// EnableEventLog makes every Scheduler keep its last n timer events (across
+// all Schedulers) in memory, for RecentEvents, or turns the log off if n is
+// 0. The events of the previous log, if any, are discarded. The events (a
+// timer being armed, reset, stopped, or firing) are recorded without a lock
+// shared by the goroutines that record them, and without allocating memory, so
+// that the log can stay on in production, to find out after the fact why a
+// timeout fired early, late, or not at all. Off, it costs a single atomic load
+// per event.
+func EnableEventLog(n int) {
+	if n <= 0 {
+		eventLog.Store(nil)
+		return
+	}
+	eventLog.Store(&eventRing{slots: make([]eventSlot, n)})
+}
+
+// Record op of t in the event log, if it is enabled.  now is the time of the operation, if the
+// caller knows it already.  The caller must hold the mutex, or own t if it is new.
+func (t *Timer) recordLocked(op EventOp, now time.Time) {
+	r := eventLog.Load()
+	if r == nil {
+		return
+	}
+	if now.IsZero() {
+		now = t.clk.now()
+	}
+	ev := RecordedEvent{Op: op, Timer: uintptr(unsafe.Pointer(t)), Name: t.name, Scheduled: t.when, Actual: now}
+	if t.bound != nil {
+		ev.Context = t.bound.ctx
+	}
+	seq := r.cursor.Add(1)
+	s := &r.slots[(seq-1)%uint64(len(r.slots))]
+	s.lock()
+	// A writer that has wrapped around since must not be overwritten by an older event.
+	if s.seq < seq {
+		s.seq, s.ev = seq, ev
+	}
+	s.unlock()
+}
+
+// RecentEvents returns the events in the log enabled by EnableEventLog, oldest
+// first, or nil if it is off. Events that are being recorded while
+// RecentEvents runs may be missing.
+func RecentEvents() []RecordedEvent {
+	r := eventLog.Load()
+	if r == nil {
+		return nil
+	}
+	end := r.cursor.Load()
+	start := uint64(1)
+	if n := uint64(len(r.slots)); end > n {
+		start = end - n + 1
+	}
+	var evs []RecordedEvent
+	for seq := start; seq <= end; seq++ {
+		s := &r.slots[(seq-1)%uint64(len(r.slots))]
+		s.lock()
+		if s.seq == seq {
+			evs = append(evs, s.ev)
+		}
+		s.unlock()
+	}
+	return evs
+}
+
+// DumpEventsOnQuit makes a SIGQUIT print the event log (see EnableEventLog) to
+// standard error before the usual goroutine dump of the runtime, which is
+// then produced as if the signal had not been caught. Call it once, at the
+// start of the program.
+func DumpEventsOnQuit() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGQUIT)
+	go func() {
+		<-c
+		evs := RecentEvents()
+		fmt.Fprintf(os.Stderr, "kairos: last %d timer events:\n", len(evs))
+		for _, e := range evs {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		// Let the runtime handle the signal again.
+		signal.Reset(syscall.SIGQUIT)
+		if p, err := os.FindProcess(os.Getpid()); err == nil {
+			p.Signal(syscall.SIGQUIT)
+		}
+	}()
+}
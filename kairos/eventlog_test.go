@@ -0,0 +1,115 @@
+package kairos
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// Return the events of timer in evs.
+func eventsOf(evs []RecordedEvent, timer *Timer) []RecordedEvent {
+	var mine []RecordedEvent
+	for _, e := range evs {
+		if e.Timer == uintptr(unsafe.Pointer(timer)) {
+			mine = append(mine, e)
+		}
+	}
+	return mine
+}
+
+func TestEventLog(t *testing.T) {
+	EnableEventLog(100)
+	defer EnableEventLog(0)
+	clk := NewScheduler()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	timer := clk.NewTimer(time.Hour, WithName("lease"))
+	timer.BindContext(ctx)
+	timer.Reset(10 * time.Millisecond)
+	<-timer.C
+	timer.Reset(time.Hour)
+	timer.Stop()
+
+	evs := eventsOf(RecentEvents(), timer)
+	want := []EventOp{EventAdd, EventReset, EventFire, EventAdd, EventStop}
+	if len(evs) != len(want) {
+		t.Fatalf("got events %v, want ops %v", evs, want)
+	}
+	for i, e := range evs {
+		if e.Op != want[i] || e.Name != "lease" {
+			t.Errorf("event %d is %v, want %v of the timer", i, e, want[i])
+		}
+		if i > 0 && e.Context != ctx {
+			t.Errorf("event %v has context %v, want the bound one", e, e.Context)
+		}
+	}
+	if fire := evs[2]; fire.Actual.Before(fire.Scheduled) || fire.Actual.Sub(fire.Scheduled) >= margin {
+		t.Errorf("fire event processed at %v for the deadline %v", fire.Actual, fire.Scheduled)
+	}
+}
+
+func TestEventLogTicker(t *testing.T) {
+	EnableEventLog(100)
+	defer EnableEventLog(0)
+	clk := NewScheduler()
+	tk := clk.NewTicker(time.Hour)
+	tk.Reset(2 * time.Hour)
+	tk.Stop()
+	evs := eventsOf(RecentEvents(), tk.t)
+	want := []EventOp{EventAdd, EventReset, EventStop}
+	if len(evs) != len(want) {
+		t.Fatalf("got events %v, want ops %v", evs, want)
+	}
+	for i, e := range evs {
+		if e.Op != want[i] {
+			t.Errorf("event %d is %v, want %v of the ticker", i, e, want[i])
+		}
+	}
+}
+
+func TestEventLogWraps(t *testing.T) {
+	EnableEventLog(4)
+	defer EnableEventLog(0)
+	clk := NewScheduler()
+	timer := clk.NewTimer(time.Hour)
+	for i := 0; i < 10; i++ {
+		timer.Reset(time.Duration(i) * time.Hour)
+	}
+	timer.Stop()
+	evs := RecentEvents()
+	if len(evs) != 4 {
+		t.Fatalf("got %d events, want 4", len(evs))
+	}
+	for i, e := range evs[:3] {
+		if e.Op != EventReset || e.Scheduled.Before(evs[max(i-1, 0)].Scheduled) {
+			t.Errorf("event %d is %v, want the latest resets in order", i, e)
+		}
+	}
+	if e := evs[3]; e.Op != EventStop {
+		t.Errorf("last event is %v, want the stop", e)
+	}
+	EnableEventLog(0)
+	if evs := RecentEvents(); evs != nil {
+		t.Errorf("got events %v with the log off", evs)
+	}
+}
+
+func BenchmarkEventLog(b *testing.B) {
+	clk := NewScheduler()
+	timer := clk.NewTimer(time.Hour)
+	defer timer.Stop()
+	for _, n := range []int{0, 1024} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			EnableEventLog(n)
+			defer EnableEventLog(0)
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					timer.Reset(time.Hour)
+				}
+			})
+		})
+	}
+}
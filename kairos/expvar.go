@@ -0,0 +1,76 @@
+package kairos
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The Schedulers published by PublishExpvar, by prefix.  The variables of a prefix read the
+// Scheduler through the pointer, so that publishing the prefix again only needs to replace it.
+var (
+	expvarMu         sync.Mutex
+	expvarSchedulers = map[string]*atomic.Pointer[Scheduler]{}
+)
+
+// PublishExpvar publishes the statistics of clk as expvar variables named after prefix; see the
+// package function PublishExpvar.
+func (clk *Scheduler) PublishExpvar(prefix string) {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	if p := expvarSchedulers[prefix]; p != nil {
+		p.Store(clk)
+		return
+	}
+	p := new(atomic.Pointer[Scheduler])
+	p.Store(clk)
+	expvarSchedulers[prefix] = p
+	stat := func(name string, f func(SchedulerStats) any) {
+		expvar.Publish(prefix+"."+name, expvar.Func(func() any { return f(p.Load().Stats()) }))
+	}
+	stat("pending", func(s SchedulerStats) any { return s.PendingTimers })
+	stat("max_pending", func(s SchedulerStats) any { return s.MaxPendingHighWater })
+	stat("fired", func(s SchedulerStats) any { return s.TotalFired })
+	stat("stopped", func(s SchedulerStats) any { return s.TotalStopped })
+	stat("resets", func(s SchedulerStats) any { return s.TotalResets })
+	expvar.Publish(prefix+".next_deadline", expvar.Func(func() any {
+		if when, ok := p.Load().NextDeadline(); ok {
+			return when.Format(time.RFC3339Nano)
+		}
+		return ""
+	}))
+	expvar.Publish(prefix+".lateness", expvar.Func(func() any {
+		h := p.Load().LatencyHistogram()
+		m := make(map[string]uint64, len(h.Counts))
+		for i, n := range h.Counts {
+			if i < len(h.Bounds) {
+				m["<"+h.Bounds[i].String()] = n
+			} else {
+				m[">="+h.Bounds[i-1].String()] = n
+			}
+		}
+		return m
+	}))
+}
+
+// PublishExpvar publishes the statistics of the default Scheduler as expvar
+// variables (see the expvar package), which are served on /debug/vars along
+// with the others:
+//
+//	prefix.pending        the number of pending timers
+//	prefix.max_pending    the most timers that have been pending at once
+//	prefix.fired          the number of expiries
+//	prefix.stopped        the number of timers stopped before they fired
+//	prefix.resets         the number of pending timers that were reset
+//	prefix.next_deadline  the earliest deadline of the pending timers, or ""
+//	prefix.lateness       the lateness histogram, by bucket ("<1ms": n, ...)
+//
+// The counters are those of Stats and LatencyHistogram. The variables are
+// computed when they are read, so they cost nothing in between; only
+// next_deadline takes the mutex, just long enough to look at the earliest
+// timer. Publishing the same prefix again, for another Scheduler for example,
+// makes the variables report that Scheduler instead.
+func PublishExpvar(prefix string) {
+	defaultScheduler.PublishExpvar(prefix)
+}
@@ -0,0 +1,53 @@
+package kairos
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	s := NewScheduler()
+	s.PublishExpvar("kairostest")
+	get := func(name string) any {
+		v := expvar.Get("kairostest." + name)
+		if v == nil {
+			t.Fatalf("kairostest.%v not published", name)
+		}
+		var x any
+		if err := json.Unmarshal([]byte(v.String()), &x); err != nil {
+			t.Fatalf("kairostest.%v = %v: %v", name, v, err)
+		}
+		return x
+	}
+	if next := get("next_deadline"); next != "" {
+		t.Errorf("next_deadline = %v without timers", next)
+	}
+	timer := s.NewTimer(time.Hour)
+	<-s.NewTimer(0).C
+	for name, want := range map[string]float64{"pending": 1, "max_pending": 2, "fired": 1, "stopped": 0, "resets": 0} {
+		if got := get(name); got != want {
+			t.Errorf("%v = %v, want %v", name, got, want)
+		}
+	}
+	when, _ := timer.Deadline()
+	if next := get("next_deadline"); next != when.Format(time.RFC3339Nano) {
+		t.Errorf("next_deadline = %v, want %v", next, when)
+	}
+	lateness := get("lateness").(map[string]any)
+	var n float64
+	for _, b := range []string{"<1ms", "<10ms", "<100ms", "<1s", ">=1s"} {
+		n += lateness[b].(float64)
+	}
+	if len(lateness) != 5 || n != 1 {
+		t.Errorf("lateness = %v, want one expiry in 5 buckets", lateness)
+	}
+
+	// Publishing the prefix again replaces the Scheduler.
+	NewScheduler().PublishExpvar("kairostest")
+	if got := get("pending"); got != 0.0 {
+		t.Errorf("pending = %v after publishing a new Scheduler", got)
+	}
+	timer.Stop()
+}
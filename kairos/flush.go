@@ -0,0 +1,108 @@
+package kairos
+
+import (
+	"context"
+	"slices"
+)
+
+// FlushTimers fires every timer that is pending in clk (or its shards), in deadline order, as
+// FireNow would, and returns their number.
+func (clk *Scheduler) FlushTimers() int {
+	if clk.shards != nil {
+		n := 0
+		for _, s := range clk.shards {
+			n += s.FlushTimers()
+		}
+		return n
+	}
+	clk.lock()
+	defer clk.unlock()
+	// The timers that are pending now, so that a ticker, which is back in the heap once it has
+	// fired, fires only once.
+	var ts []*Timer
+	clk.timers.Walk(func(t *Timer) { ts = append(ts, t) })
+	slices.SortFunc(ts, func(a, b *Timer) int {
+		if a.before(b) {
+			return -1
+		}
+		return 1
+	})
+	now := clk.now()
+	for _, t := range ts {
+		clk.expireLocked(t, now)
+		clk.checkLocked("flush")
+	}
+	if len(ts) > 0 {
+		clk.removedLocked()
+	}
+	return len(ts)
+}
+
+// WaitIdle waits until clk (and each of its shards) has no pending timer and no callback running, or
+// until ctx is done, in which case it returns ctx.Err().
+func (clk *Scheduler) WaitIdle(ctx context.Context) error {
+	if clk.shards != nil {
+		// The callbacks of a shard may start timers of another one, so wait until a pass over
+		// the shards finds them all idle.
+		for {
+			busy := false
+			for _, s := range clk.shards {
+				s.lock()
+				busy = busy || !s.idleLocked()
+				s.unlock()
+				if err := s.WaitIdle(ctx); err != nil {
+					return err
+				}
+			}
+			if !busy {
+				return nil
+			}
+		}
+	}
+	clk.lock()
+	defer clk.unlock()
+	stop := context.AfterFunc(ctx, func() {
+		clk.lock()
+		clk.funcDone.Broadcast()
+		clk.unlock()
+	})
+	defer stop()
+	for !clk.idleLocked() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		clk.funcDone.Wait()
+	}
+	return nil
+}
+
+// Report whether clk has no pending timer and no callback running.  The caller must hold the mutex.
+func (clk *Scheduler) idleLocked() bool {
+	return clk.timers.Len() == 0 && clk.callbacks == 0
+}
+
+// FlushTimers fires every pending timer of the default Scheduler right away,
+// in deadline order, exactly as if its deadline had arrived (see FireNow), and
+// returns the number of timers fired. It is meant for the teardown of a test
+// or for a shutdown, to run the cleanup that timeouts do and leave no timer
+// behind. The timers are fired in a single critical section, so none of them
+// can also fire on its own, and none is started or stopped in the meantime;
+// the callbacks, as always, run in their own goroutines, and may have started
+// but not finished when FlushTimers returns. A ticker fires once, and is left
+// pending with its next tick; so is a timer whose callback restarts it. Call
+// FlushTimers again, or stop them, to get rid of them. Paused timers are not
+// fired.
+func FlushTimers() int {
+	return defaultScheduler.FlushTimers()
+}
+
+// WaitIdle waits until the default Scheduler has nothing left to do: no timer
+// is pending, and no AfterFunc callback (or TickerFunc function) is running or
+// queued for a callback worker. It returns nil then, or ctx.Err() if ctx is
+// done first. Paused timers do not count as pending. Together with
+// FlushTimers, it lets a test wait for all the scheduled work to complete
+// without sleeping; the timers started after WaitIdle returns are of course
+// not waited for.
+func WaitIdle(ctx context.Context) error {
+	return defaultScheduler.WaitIdle(ctx)
+}
@@ -0,0 +1,115 @@
+package kairos
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlushTimers(t *testing.T) {
+	clk := NewScheduler()
+	var r fireRecorder
+	clk.RegisterObserver(&r)
+	late := clk.NewTimer(2 * time.Hour)
+	var ran sync.WaitGroup
+	ran.Add(1)
+	early := clk.AfterFunc(time.Hour, ran.Done)
+	ticker := clk.NewTicker(time.Minute)
+	defer ticker.Stop()
+	paused := clk.NewTimer(time.Hour)
+	paused.Pause()
+
+	if n := clk.FlushTimers(); n != 3 {
+		t.Errorf("FlushTimers fired %d timers, want 3", n)
+	}
+	ran.Wait()
+	select {
+	case <-late.C:
+	default:
+		t.Error("flushed timer did not send on its channel")
+	}
+	<-ticker.C
+	if s := ticker.t.State(); s != Scheduled {
+		t.Errorf("flushed ticker is %v, want it pending with its next tick", s)
+	}
+	if s := paused.State(); s != Paused {
+		t.Errorf("flushed paused timer is %v", s)
+	}
+	r.mu.Lock()
+	if len(r.fired) != 3 || r.fired[0] != ticker.t || r.fired[1] != early || r.fired[2] != late {
+		t.Errorf("timers fired in the order %v", r.fired)
+	}
+	r.mu.Unlock()
+	if n := clk.FlushTimers(); n != 1 {
+		t.Errorf("second FlushTimers fired %d timers, want only the ticker", n)
+	}
+}
+
+// Flush the timers while they come due on their own: each fires exactly once.
+func TestFlushTimersRace(t *testing.T) {
+	clk := NewScheduler()
+	var fired atomic.Int64
+	const n = 1000
+	for i := 0; i < n; i++ {
+		clk.AfterFunc(time.Duration(i)*time.Microsecond, func() { fired.Add(1) })
+	}
+	flushed := clk.FlushTimers()
+	time.Sleep(margin)
+	if got := fired.Load(); got != n {
+		t.Errorf("%d callbacks ran, want %d (%d flushed)", got, n, flushed)
+	}
+	if p := clk.PendingCount(); p != 0 {
+		t.Errorf("%d timers pending after the flush", p)
+	}
+}
+
+func TestWaitIdle(t *testing.T) {
+	clk := NewScheduler()
+	if err := clk.WaitIdle(context.Background()); err != nil {
+		t.Fatalf("WaitIdle of an idle Scheduler returned %v", err)
+	}
+	var done atomic.Bool
+	start := time.Now()
+	clk.AfterFunc(10*time.Millisecond, func() {
+		// A callback that starts another timer, and keeps running after the heap is empty.
+		clk.AfterFunc(10*time.Millisecond, func() {
+			time.Sleep(20 * time.Millisecond)
+			done.Store(true)
+		})
+	})
+	if err := clk.WaitIdle(context.Background()); err != nil {
+		t.Fatalf("WaitIdle returned %v", err)
+	}
+	if !done.Load() {
+		t.Error("WaitIdle returned while a callback was running")
+	}
+	if d := time.Since(start); d < 40*time.Millisecond || d >= 40*time.Millisecond+margin {
+		t.Errorf("WaitIdle returned after %v, want 40ms", d)
+	}
+
+	timer := clk.NewTimer(time.Hour)
+	defer timer.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := clk.WaitIdle(ctx); err != context.DeadlineExceeded {
+		t.Errorf("WaitIdle with a pending timer returned %v", err)
+	}
+}
+
+func TestWaitIdleSharded(t *testing.T) {
+	clk := NewScheduler(WithShards(4))
+	var n atomic.Int64
+	for i := 0; i < 100; i++ {
+		clk.AfterFunc(time.Duration(i%10)*time.Millisecond, func() {
+			clk.AfterFunc(time.Millisecond, func() { n.Add(1) })
+		})
+	}
+	if err := clk.WaitIdle(context.Background()); err != nil {
+		t.Fatalf("WaitIdle returned %v", err)
+	}
+	if got := n.Load(); got != 100 {
+		t.Errorf("%d of the 100 timers started by callbacks ran before WaitIdle returned", got)
+	}
+}
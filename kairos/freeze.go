@@ -0,0 +1,97 @@
+package kairos
+
+import (
+	"fmt"
+	"time"
+)
+
+// A ThawPolicy selects what Thaw does about the timers that became due while a Scheduler was frozen.
+type ThawPolicy int
+
+const (
+	// FireBacklog fires the timers that became due while the Scheduler was frozen right away, in
+	// deadline order.
+	FireBacklog ThawPolicy = iota
+	// ShiftDeadlines moves the deadline of every pending timer later by the time the Scheduler was
+	// frozen, as if the time had stopped with it, so that relative timeouts keep the time they had
+	// left instead of firing in a burst.
+	ShiftDeadlines
+)
+
+func (p ThawPolicy) String() string {
+	switch p {
+	case FireBacklog:
+		return "FireBacklog"
+	case ShiftDeadlines:
+		return "ShiftDeadlines"
+	}
+	return fmt.Sprintf("ThawPolicy(%d)", int(p))
+}
+
+// Freeze stops the timer routine of clk (and of its shards) from firing timers until Thaw is
+// called: the timers that become due in the meantime stay pending.  Timers can still be started,
+// reset, and stopped, and the callbacks that are already running are unaffected.  The stall handler
+// (see SetStallHandler) is not called while clk is frozen.  Freeze does nothing if clk is already
+// frozen.
+func (clk *Scheduler) Freeze() {
+	for _, s := range clk.shards {
+		s.Freeze()
+	}
+	clk.lock()
+	defer clk.unlock()
+	if clk.frozen.Load() {
+		return
+	}
+	clk.frozen.Store(true)
+	clk.frozenAt = clk.now()
+}
+
+// Thaw undoes Freeze, and has the timers that became due while clk was frozen fire, or not, as
+// selected by p.  Thaw does nothing if clk is not frozen.
+func (clk *Scheduler) Thaw(p ThawPolicy) {
+	for _, s := range clk.shards {
+		s.Thaw(p)
+	}
+	clk.lock()
+	defer clk.unlock()
+	if !clk.frozen.Load() {
+		return
+	}
+	clk.frozen.Store(false)
+	if d := clk.now().Sub(clk.frozenAt); p == ShiftDeadlines && d > 0 {
+		clk.shiftLocked(d)
+	}
+	clk.wake()
+}
+
+// Move the deadline of every timer in the heap later by d.  They all move by the same amount, so
+// their order, and the order in which they were started, is unchanged.  The caller must hold the
+// mutex.
+func (clk *Scheduler) shiftLocked(d time.Duration) {
+	var ts []*Timer
+	clk.timers.Walk(func(t *Timer) { ts = append(ts, t) })
+	for _, t := range ts {
+		t.when = t.when.Add(d)
+		t.nominal = t.nominal.Add(d)
+		clk.refLocked(t)
+		clk.timers.Fix(t)
+	}
+	clk.checkLocked("shift")
+}
+
+// Freeze stops the default Scheduler from firing timers until Thaw is called,
+// for example while a debugger is attached or a consistent snapshot of the
+// state of the process is taken. The timers that become due in the meantime
+// are held back, and new timers can still be started, reset, and stopped.
+func Freeze() {
+	defaultScheduler.Freeze()
+}
+
+// Thaw lets the default Scheduler fire timers again after Freeze. With
+// FireBacklog, the timers that became due while it was frozen fire right away,
+// in deadline order; with ShiftDeadlines, every pending deadline is moved later
+// by the time it was frozen, so that no timeout expires just because of the
+// freeze.
+func Thaw(p ThawPolicy) {
+	defaultScheduler.Thaw(p)
+}
@@ -0,0 +1,79 @@
+package kairos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFreeze(t *testing.T) {
+	for _, clk := range []*Scheduler{NewScheduler(), NewScheduler(WithShards(2))} {
+		var r fireRecorder
+		clk.RegisterObserver(&r)
+		a := clk.NewTimer(margin / 2)
+		b := clk.NewTimer(margin)
+		stopped := clk.NewTimer(margin)
+		clk.Freeze()
+		clk.Freeze()
+		// Due before a and b.
+		c := clk.NewTimer(0)
+		if !stopped.Stop() {
+			t.Error("Stop returned false for a pending timer of a frozen Scheduler")
+		}
+		time.Sleep(2 * margin)
+		r.mu.Lock()
+		if len(r.fired) != 0 {
+			t.Errorf("%d timers fired while frozen", len(r.fired))
+		}
+		r.mu.Unlock()
+		if n := clk.PendingCount(); n != 3 {
+			t.Errorf("%d timers pending while frozen, want 3", n)
+		}
+
+		start := time.Now()
+		clk.Thaw(FireBacklog)
+		clk.Thaw(FireBacklog)
+		for _, timer := range []*Timer{c, a, b} {
+			<-timer.C
+		}
+		if got := time.Since(start); got >= margin {
+			t.Errorf("backlog fired %v after Thaw", got)
+		}
+		time.Sleep(margin / 10)
+		r.mu.Lock()
+		// The shards fire their timers independently.
+		if len(r.fired) != 3 || clk.shards == nil && (r.fired[0] != c || r.fired[1] != a || r.fired[2] != b) {
+			t.Errorf("backlog fired in the order %v, want [%v %v %v]", r.fired, c, a, b)
+		}
+		r.mu.Unlock()
+	}
+}
+
+func TestThawShiftDeadlines(t *testing.T) {
+	clk := NewScheduler()
+	timer := clk.NewTimer(2 * margin)
+	paused := clk.NewTimer(time.Hour)
+	pausedLeft, _ := paused.Pause()
+	clk.Freeze()
+	time.Sleep(3 * margin)
+	clk.Thaw(ShiftDeadlines)
+	if left := timer.Remaining(); left < margin || left > 2*margin {
+		t.Errorf("%v left after ShiftDeadlines, want about %v", left, 2*margin)
+	}
+	if left := paused.Remaining(); left != pausedLeft {
+		t.Errorf("paused timer has %v left after ShiftDeadlines, want %v", left, pausedLeft)
+	}
+	select {
+	case <-timer.C:
+		t.Error("timer fired right after ShiftDeadlines")
+	case <-time.After(margin / 2):
+	}
+	<-timer.C
+}
+
+func TestThawPolicyString(t *testing.T) {
+	for p, want := range map[ThawPolicy]string{FireBacklog: "FireBacklog", ShiftDeadlines: "ShiftDeadlines", 7: "ThawPolicy(7)"} {
+		if got := p.String(); got != want {
+			t.Errorf("ThawPolicy(%d).String() = %q, want %q", int(p), got, want)
+		}
+	}
+}
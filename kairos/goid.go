@@ -0,0 +1,50 @@
+package kairos
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// goid returns the ID of the calling goroutine.  Go deliberately does not expose goroutine IDs; this
+// parses the header line of the goroutine's stack trace ("goroutine 123 [running]:"), which is slow.
+// So that StopWait can tell a call from the callback itself apart from the others, runFunc calls it
+// for the calls of the callbacks of the timers on which StopWait has been called; the workers of
+// the callback pool call it only once.  See BenchmarkAfterFuncThroughput for what it costs.
+func goid() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		panic("timer: cannot determine goroutine ID: " + err.Error())
+	}
+	return id
+}
+
+// Report whether the calling goroutine is running the callback of a timer.
+func inCallback() bool {
+	pcs := make([]uintptr, 64)
+	for {
+		n := runtime.Callers(2, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, 2*len(pcs))
+	}
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if strings.HasSuffix(frame.Function, ".(*Timer).runFuncIn") {
+			return true
+		}
+		if !more {
+			return false
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package kairos
+
+import (
+	"testing"
+)
+
+func TestGoid(t *testing.T) {
+	self := goid()
+	if self == 0 {
+		t.Errorf("goid returned 0")
+	}
+	if got := goid(); got != self {
+		t.Errorf("goid not stable; got %v, want %v", got, self)
+	}
+	other := make(chan uint64)
+	go func() { other <- goid() }()
+	if got := <-other; got == self {
+		t.Errorf("goid of another goroutine equals %v", self)
+	}
+}
+
+func BenchmarkGoid(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		goid()
+	}
+}
@@ -0,0 +1,41 @@
+package kairos
+
+import (
+	"runtime"
+	"time"
+)
+
+// How close the next deadline must be for the timer routine of a Scheduler created with
+// WithHighResolution to poll the clock instead of sleeping.
+const spinThreshold = 2 * time.Millisecond
+
+// WithHighResolution makes the timers of the Scheduler fire within microseconds of their deadlines
+// instead of up to a millisecond or two late (about 15 on some versions of Windows): the timer
+// routine sleeps until the next deadline is 2ms away, and then polls the clock, yielding the
+// processor with runtime.Gosched in between, until it is reached.  On Windows, it also raises the
+// resolution of the system timer to 1ms while timers are pending.  This costs a processor that
+// is busy for 2ms before every deadline (or all the time, with timers due less than 2ms apart),
+// and battery life with a raised timer resolution.
+func WithHighResolution() SchedulerOption {
+	return func(clk *Scheduler) {
+		clk.highRes = true
+	}
+}
+
+// Poll the clock of clk until next, and report whether the timer routine is to go on: false if quit
+// was closed in the meantime.  A reschedule request cuts it short, since an earlier deadline may
+// have been added.
+func (clk *Scheduler) spinUntil(next time.Time, quit <-chan struct{}) bool {
+	for clk.now().Before(next) {
+		select {
+		case <-quit:
+			return false
+		case <-clk.rescheduleC:
+			clk.stats.wakeups.Add(1)
+			return true
+		default:
+		}
+		runtime.Gosched()
+	}
+	return true
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package kairos
+
+// The resolution of the system timer only needs raising on Windows.
+func raiseTimerResolution() {}
+
+func restoreTimerResolution() {}
@@ -0,0 +1,71 @@
+package kairos
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Return the median of how late n timers of 100µs of clk are received, one after the other.
+func medianLateness(clk *Scheduler, n int) time.Duration {
+	const d = 100 * time.Microsecond
+	timer := clk.NewStoppedTimer()
+	late := make([]time.Duration, n)
+	for i := range late {
+		start := time.Now()
+		timer.Reset(d)
+		<-timer.C
+		late[i] = time.Since(start) - d
+	}
+	slices.Sort(late)
+	return late[n/2]
+}
+
+func TestHighResolution(t *testing.T) {
+	clk := NewScheduler(WithHighResolution())
+	defer clk.Shutdown(context.Background())
+	normal := NewScheduler()
+	defer normal.Shutdown(context.Background())
+	medianLateness(clk, 10) // Start the routine.
+	high, low := medianLateness(clk, 200), medianLateness(normal, 200)
+	t.Logf("median lateness of 100µs timers: %v with WithHighResolution, %v without", high, low)
+	if high >= 100*time.Microsecond {
+		t.Errorf("timers of 100µs fired %v late with WithHighResolution", high)
+	}
+
+	// A timer that is far away is slept for, and an earlier one added in the meantime is not
+	// held up by the polling.
+	start := time.Now()
+	far := clk.NewTimer(spinThreshold + margin)
+	<-clk.NewTimer(margin / 2).C
+	if got := time.Since(start); got >= margin/2+margin/10 {
+		t.Errorf("timer of %v fired after %v", margin/2, got)
+	}
+	<-far.C
+	if got := time.Since(start); got < spinThreshold+margin || got >= spinThreshold+margin+margin/10 {
+		t.Errorf("timer of %v fired after %v", spinThreshold+margin, got)
+	}
+}
+
+func BenchmarkLateness(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		opts []SchedulerOption
+	}{
+		{"Default", nil},
+		{"HighResolution", []SchedulerOption{WithHighResolution()}},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			clk := NewScheduler(bb.opts...)
+			defer clk.Shutdown(context.Background())
+			medianLateness(clk, 10)
+			b.ResetTimer()
+			var total time.Duration
+			for i := 0; i < b.N; i++ {
+				total += medianLateness(clk, 1)
+			}
+			b.ReportMetric(float64(total.Nanoseconds())/float64(b.N), "ns-late/op")
+		})
+	}
+}
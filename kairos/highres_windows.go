@@ -0,0 +1,24 @@
+package kairos
+
+import "syscall"
+
+var (
+	winmm           = syscall.NewLazyDLL("winmm.dll")
+	timeBeginPeriod = winmm.NewProc("timeBeginPeriod")
+	timeEndPeriod   = winmm.NewProc("timeEndPeriod")
+)
+
+// Raise the resolution of the system timer to 1ms, for the runtime timers to be precise enough for
+// WithHighResolution to sleep until 2ms before the deadlines.
+func raiseTimerResolution() {
+	if timeBeginPeriod.Find() == nil {
+		timeBeginPeriod.Call(1)
+	}
+}
+
+// Undo raiseTimerResolution.
+func restoreTimerResolution() {
+	if timeEndPeriod.Find() == nil {
+		timeEndPeriod.Call(1)
+	}
+}
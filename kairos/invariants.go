@@ -0,0 +1,102 @@
+package kairos
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Set by EnableInvariantChecks.
+var invariantChecks atomic.Bool
+
+// EnableInvariantChecks makes every Scheduler verify the structure of its
+// heap (or timing wheel) after every change, and panic with a dump of it if
+// it is corrupt. Each check takes time proportional to the number of pending
+// timers, so that every operation does, too: this is a debugging aid, to find
+// out whether late or lost expiries come from a bug of the package, and must
+// not be left on in production.
+func EnableInvariantChecks(on bool) {
+	invariantChecks.Store(on)
+}
+
+// Report a violation of the invariants of the pending timers of clk, after op, if the checks are
+// enabled.  The caller must hold the mutex.
+func (clk *Scheduler) checkLocked(op string) {
+	if !invariantChecks.Load() {
+		return
+	}
+	err := clk.timers.verify()
+	clk.timers.Walk(func(t *Timer) {
+		if err == nil && (t.state != Scheduled || t.clk != clk) {
+			err = fmt.Errorf("timer %p (%q) is %v and belongs to %p, but is in the queue of %p", t, t.name, t.state, t.clk, clk)
+		}
+	})
+	if err == nil {
+		return
+	}
+	var dump strings.Builder
+	n := 0
+	clk.timers.Walk(func(t *Timer) {
+		if n < 100 {
+			fmt.Fprintf(&dump, "%p index %d deadline %v name %q\n", t, t.i, t.when, t.name)
+		}
+		n++
+	})
+	if n > 100 {
+		fmt.Fprintf(&dump, "... %d more\n", n-100)
+	}
+	panic(fmt.Sprintf("timer: invariant violated after %s: %v\n%s", op, err, dump.String()))
+}
+
+func (h timerHeap) verify() error {
+	for i, t := range h {
+		if t.i != i {
+			return fmt.Errorf("timer at position %d of the heap has index %d", i, t.i)
+		}
+		if p := (i - 1) / 4; i > 0 && t.before(h[p]) {
+			return fmt.Errorf("timer at position %d (due %v) is before its parent at %d (due %v)", i, t.when, p, h[p].when)
+		}
+	}
+	return nil
+}
+
+func (w *timingWheel) verify() error {
+	if err := w.ready.verify(); err != nil {
+		return fmt.Errorf("ready timers: %w", err)
+	}
+	for i, t := range w.ready {
+		if k := w.tickOf(t); k > w.cur {
+			return fmt.Errorf("ready timer at %d is due at tick %d, after the current tick %d", i, k, w.cur)
+		}
+	}
+	n := len(w.ready)
+	for l := range w.levels {
+		shift := uint(l) * w.shift
+		for pos := range w.levels[l] {
+			s := &w.levels[l][pos]
+			used := w.used[l][pos/64]&(1<<(pos%64)) != 0
+			if used != (s.head != nil) {
+				return fmt.Errorf("slot %d of level %d is marked used %v, but has head %p", pos, l, used, s.head)
+			}
+			var prev *Timer
+			for t := s.head; t != nil; prev, t = t, t.wnext {
+				n++
+				k := w.tickOf(t)
+				switch {
+				case t.wslot != s:
+					return fmt.Errorf("timer %p in slot %d of level %d points to another slot", t, pos, l)
+				case t.wprev != prev:
+					return fmt.Errorf("timer %p in slot %d of level %d has a wrong previous link", t, pos, l)
+				case k <= w.cur:
+					return fmt.Errorf("timer %p in slot %d of level %d is due at tick %d, by the current tick %d", t, pos, l, k, w.cur)
+				case (k>>shift)&w.mask != int64(pos):
+					return fmt.Errorf("timer %p due at tick %d is in slot %d of level %d", t, k, pos, l)
+				}
+			}
+		}
+	}
+	if n != w.n {
+		return fmt.Errorf("%d timers in the wheel, which counts %d", n, w.n)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package kairos
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Start, reset, pause, and stop timers from many goroutines at once with the checks on.
+func TestInvariantsStress(t *testing.T) {
+	EnableInvariantChecks(true)
+	defer EnableInvariantChecks(false)
+	for _, opts := range [][]SchedulerOption{nil, {WithTimingWheel(100*time.Microsecond, 64)}} {
+		s := NewScheduler(opts...)
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(seed int64) {
+				defer wg.Done()
+				rnd := rand.New(rand.NewSource(seed))
+				d := func() time.Duration { return time.Duration(rnd.Int63n(int64(10 * time.Millisecond))) }
+				var timers []*Timer
+				for i := 0; i < 2000; i++ {
+					switch op := rnd.Intn(6); {
+					case op == 0 || len(timers) == 0:
+						timers = append(timers, s.NewTimer(d()))
+					case op == 1:
+						s.AfterFunc(d(), func() {})
+					case op == 2:
+						timers[rnd.Intn(len(timers))].Reset(d())
+					case op == 3:
+						timer := timers[rnd.Intn(len(timers))]
+						if rnd.Intn(2) == 0 {
+							timer.Pause()
+						} else {
+							timer.Resume()
+						}
+					default:
+						timers[rnd.Intn(len(timers))].Stop()
+					}
+				}
+				for _, timer := range timers {
+					timer.Stop()
+				}
+			}(int64(g))
+		}
+		wg.Wait()
+	}
+}
+
+func TestInvariantViolation(t *testing.T) {
+	EnableInvariantChecks(true)
+	defer EnableInvariantChecks(false)
+	s := NewScheduler()
+	early, late := s.NewTimer(time.Hour), s.NewTimer(2*time.Hour)
+	s.lock()
+	defer s.unlock()
+	// Swap the deadlines behind the back of the heap.
+	early.when, late.when = late.when, early.when
+	defer func() {
+		r, _ := recover().(string)
+		if !strings.HasPrefix(r, "timer: invariant violated after insert: timer at position 1 (due ") {
+			t.Errorf("invalid panic %v", r)
+		}
+		// Leave the heap in order for the unlock.
+		early.when, late.when = late.when, early.when
+	}()
+	timer := s.NewStoppedTimer()
+	timer.when = time.Now().Add(3 * time.Hour)
+	s.insertLocked(timer)
+}
@@ -0,0 +1,25 @@
+package kairos
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// The profiler labels of the timer routine, and of the callbacks of timers without a name.  They
+// are created once, so that setting them costs no allocation.
+var (
+	schedulerLabels = pprof.WithLabels(context.Background(), pprof.Labels("kairos", "scheduler"))
+	callbackLabels  = pprof.WithLabels(context.Background(), pprof.Labels("kairos", "callback"))
+)
+
+// Return the profiler labels for the callbacks of t: those of every callback, plus the name of t
+// if it has one.  The caller must hold the mutex.
+func (t *Timer) labelsLocked() context.Context {
+	if t.name == "" {
+		return callbackLabels
+	}
+	if t.labels == nil {
+		t.labels = pprof.WithLabels(callbackLabels, pprof.Labels("kairos.timer", t.name))
+	}
+	return t.labels
+}
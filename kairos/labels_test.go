@@ -0,0 +1,59 @@
+package kairos
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"strings"
+	"testing"
+)
+
+func TestProfilerLabels(t *testing.T) {
+	s := NewScheduler()
+	running, unblock := make(chan struct{}, 2), make(chan struct{})
+	f := func() {
+		running <- struct{}{}
+		<-unblock
+	}
+	s.AfterFunc(0, f, WithName("flush"))
+	s.AfterFunc(0, f)
+	<-running
+	<-running
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 1)
+	close(unblock)
+	profile := buf.String()
+	for _, labels := range []string{
+		`labels: {"kairos":"scheduler"}`,
+		`labels: {"kairos":"callback"}`,
+		`labels: {"kairos":"callback", "kairos.timer":"flush"}`,
+	} {
+		if !strings.Contains(profile, labels) {
+			t.Errorf("no goroutine with %s in the profile", labels)
+		}
+	}
+}
+
+func TestProfilerLabelsManualDispatch(t *testing.T) {
+	s := NewScheduler(WithManualDispatch())
+	ran := false
+	s.AfterFunc(0, func() { ran = true })
+	dispatched, unblock := make(chan struct{}), make(chan struct{})
+	go func() {
+		pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels("sim", "driver")))
+		s.RunNext()
+		close(dispatched)
+		<-unblock
+	}()
+	<-dispatched
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 1)
+	close(unblock)
+	if !ran {
+		t.Errorf("RunNext did not run the callback")
+	}
+	// The callback ran in the goroutine that called RunNext, which must keep its labels.
+	if labels := `labels: {"sim":"driver"}`; !strings.Contains(buf.String(), labels) {
+		t.Errorf("no goroutine with %s in the profile after RunNext", labels)
+	}
+}
@@ -0,0 +1,100 @@
+package kairos
+
+import (
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// A LatencySnapshot counts the expiries of timers by how late they were processed, that is, by how
+// long after its deadline the timer routine delivered each notification.  Counts[i] is the number
+// of expiries that were late by less than Bounds[i] (and by at least Bounds[i-1], if i > 0); the
+// last element of Counts, Counts[len(Bounds)], is the number of those that were later still.
+type LatencySnapshot struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Sum    time.Duration // The total lateness of the expiries.
+}
+
+// The bucket boundaries used until SetLatencyBuckets is called.
+var defaultLatencyBounds = []time.Duration{time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond, time.Second}
+
+// The buckets of a lateness histogram that is being recorded.
+type latencyHistogram struct {
+	bounds []time.Duration // Immutable.
+	counts []atomic.Uint64
+	sum    atomic.Int64
+}
+
+func newLatencyHistogram(bounds []time.Duration) *latencyHistogram {
+	return &latencyHistogram{bounds: bounds, counts: make([]atomic.Uint64, len(bounds)+1)}
+}
+
+// Count an expiry that was late by late.  The bounds are few, so a linear search is fastest.
+func (h *latencyHistogram) record(late time.Duration) {
+	// A timer fired early by Fire is not late.
+	late = max(late, 0)
+	h.sum.Add(int64(late))
+	i := 0
+	for i < len(h.bounds) && late >= h.bounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+}
+
+// Add the counts of h to s, which has the same bounds.
+func (h *latencyHistogram) addTo(s *LatencySnapshot) {
+	if s.Bounds == nil {
+		s.Bounds = slices.Clone(h.bounds)
+		s.Counts = make([]uint64, len(h.counts))
+	}
+	for i := range h.counts {
+		s.Counts[i] += h.counts[i].Load()
+	}
+	s.Sum += time.Duration(h.sum.Load())
+}
+
+// LatencyHistogram returns the counts of the expiries of the timers of clk (or of its shards, added
+// up) by lateness since clk was created, or since ResetStats or SetLatencyBuckets was last called.
+func (clk *Scheduler) LatencyHistogram() LatencySnapshot {
+	var s LatencySnapshot
+	for _, sh := range clk.shards {
+		sh.latency.Load().addTo(&s)
+	}
+	if len(clk.shards) == 0 {
+		clk.latency.Load().addTo(&s)
+	}
+	return s
+}
+
+// SetLatencyBuckets makes the histogram of clk use buckets with the upper bounds bounds (which are
+// sorted and deduplicated), and clears it.  It panics if no bound is given.
+func (clk *Scheduler) SetLatencyBuckets(bounds ...time.Duration) {
+	if len(bounds) == 0 {
+		panic("timer: no bounds for SetLatencyBuckets")
+	}
+	bounds = slices.Clone(bounds)
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+	for _, s := range clk.shards {
+		s.SetLatencyBuckets(bounds...)
+	}
+	clk.latency.Store(newLatencyHistogram(bounds))
+}
+
+// LatencyHistogram returns the lateness of the expiries processed by the
+// default Scheduler so far, as the number of expiries in each of a few
+// buckets of lateness: by default, less than 1ms, 1ms to 10ms, 10ms to 100ms,
+// 100ms to 1s, and more than 1s. Unlike MaxLateness, it tells whether a bad
+// lateness is common or a one-off. Recording an expiry costs a single atomic
+// increment, so the histogram is always on; ResetStats clears it.
+func LatencyHistogram() LatencySnapshot {
+	return defaultScheduler.LatencyHistogram()
+}
+
+// SetLatencyBuckets replaces the buckets of the lateness histogram of the
+// default Scheduler by buckets with the upper bounds bounds, plus a last one
+// for the expiries that are later than all of them, and clears it.
+func SetLatencyBuckets(bounds ...time.Duration) {
+	defaultScheduler.SetLatencyBuckets(bounds...)
+}
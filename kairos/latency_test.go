@@ -0,0 +1,52 @@
+package kairos
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	for _, shards := range []int{1, 4} {
+		s := NewScheduler(WithShards(shards))
+		if h := s.LatencyHistogram(); !slices.Equal(h.Bounds, defaultLatencyBounds) || !slices.Equal(h.Counts, make([]uint64, 5)) {
+			t.Errorf("LatencyHistogram() of a new Scheduler = %v", h)
+		}
+		s.SetLatencyBuckets(50*time.Millisecond, 5*time.Millisecond, 50*time.Millisecond)
+		// Hold up the timer routine for 100ms in the send hook of a timer, so that a timer due 10ms
+		// later fires 90ms late, in the last bucket.
+		fired := make(chan struct{}, 2)
+		slow, late := s.NewStoppedTimer(), s.NewStoppedTimer()
+		slow.send = func(expiry) {
+			time.Sleep(100 * time.Millisecond)
+			fired <- struct{}{}
+		}
+		// Both on the same shard, so that they are handled by the same routine.
+		for late.clk != slow.clk {
+			late = s.NewStoppedTimer()
+		}
+		late.send = func(expiry) { fired <- struct{}{} }
+		now := time.Now()
+		slow.ResetAt(now)
+		late.ResetAt(now.Add(10 * time.Millisecond))
+		<-fired
+		<-fired
+		want := LatencySnapshot{Bounds: []time.Duration{5 * time.Millisecond, 50 * time.Millisecond}, Counts: []uint64{1, 0, 1}}
+		if h := s.LatencyHistogram(); !slices.Equal(h.Bounds, want.Bounds) || !slices.Equal(h.Counts, want.Counts) {
+			t.Errorf("%v shards: LatencyHistogram() = %v, want %v", shards, h, want)
+		}
+		s.ResetStats()
+		if h := s.LatencyHistogram(); !slices.Equal(h.Bounds, want.Bounds) || !slices.Equal(h.Counts, make([]uint64, 3)) {
+			t.Errorf("%v shards: LatencyHistogram() after ResetStats = %v", shards, h)
+		}
+	}
+}
+
+func TestSetLatencyBucketsPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "timer: no bounds for SetLatencyBuckets" {
+			t.Errorf("invalid panic %v", r)
+		}
+	}()
+	NewScheduler().SetLatencyBuckets()
+}
@@ -0,0 +1,69 @@
+package kairos
+
+import "time"
+
+// WithManualDispatch makes the Scheduler fire its timers only when RunNext or RunUntil is called:
+// it never starts a goroutine of its own, and the callbacks of its timers are run by the
+// goroutine that calls RunNext or RunUntil instead of goroutines of their own (or callback
+// workers).  Timers can still be started, stopped, and reset from any goroutine.  Since the
+// timers fire in deadline order, with ties broken by the order in which they were started, and
+// one at a time, a simulation driven by RunUntil runs the same way whatever GOMAXPROCS is.
+// WithManualDispatch cannot be combined with WithShards.
+func WithManualDispatch() SchedulerOption {
+	return func(clk *Scheduler) {
+		clk.manualDispatch = true
+	}
+}
+
+// Call t.runFunc(f, call, e) in a goroutine of its own, or queue the call for the goroutine that
+// dispatches the timers of a Scheduler with manual dispatch.  The caller must hold the mutex.
+func (clk *Scheduler) goLocked(t *Timer, f func(), call func(expiry), e expiry) {
+	if clk.manualDispatch {
+		clk.calls = append(clk.calls, func() { t.runFunc(f, call, e) })
+		return
+	}
+	go t.runFunc(f, call, e)
+}
+
+// RunNext fires the earliest timer of clk, which must have been created with WithManualDispatch,
+// if it is due, and runs its callback, if it has one, before returning.  It reports whether there
+// was a timer to fire.  The time of the expiry is the deadline of the timer rather than the time
+// at which RunNext fires it, so that a simulation sees the same times on every run.
+func (clk *Scheduler) RunNext() bool {
+	return clk.runNext(clk.now())
+}
+
+// RunUntil fires the timers of clk, which must have been created with WithManualDispatch, that
+// are due by end, in deadline order, like repeated calls of RunNext, and returns their number.
+// This includes the ticks of tickers and the timers started by the callbacks, if they are due by
+// end.
+func (clk *Scheduler) RunUntil(end time.Time) int {
+	n := 0
+	for clk.runNext(end) {
+		n++
+	}
+	return n
+}
+
+// Fire the earliest timer of clk if it is due by end, run the callbacks queued in the meantime,
+// and report whether there was one.
+func (clk *Scheduler) runNext(end time.Time) bool {
+	if !clk.manualDispatch {
+		panic("timer: RunNext or RunUntil called on a Scheduler without WithManualDispatch")
+	}
+	clk.lock()
+	t := clk.timers.Earliest()
+	fired := t != nil && !t.when.After(end)
+	if fired {
+		clk.expireLocked(t, t.when)
+		clk.checkLocked("expiry")
+		clk.removedLocked()
+	}
+	calls := clk.calls
+	clk.calls = nil
+	clk.unlock()
+	for _, f := range calls {
+		f()
+	}
+	return fired
+}
@@ -0,0 +1,102 @@
+package kairos
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManualDispatch(t *testing.T) {
+	clk := NewScheduler(WithManualDispatch())
+	start := clk.Now()
+	id := goid()
+	var log []string
+	record := func(name string) func() {
+		return func() {
+			if goid() != id {
+				t.Errorf("callback %s ran in another goroutine", name)
+			}
+			log = append(log, name)
+		}
+	}
+	clk.AfterFunc(3*time.Minute, record("c"))
+	clk.AfterFunc(time.Minute, record("a"))
+	clk.AfterFunc(time.Minute, record("b"))
+	clk.AfterFunc(2*time.Minute, func() {
+		record("x")()
+		clk.AfterFunc(30*time.Second, record("y"))
+	})
+	ticks := 0
+	ticker := clk.TickerFunc(time.Minute, func(time.Time) { ticks++ })
+	defer ticker.Stop()
+	channel := clk.NewTimer(90 * time.Second)
+	deadline, _ := channel.Deadline()
+
+	time.Sleep(margin)
+	if len(log) != 0 || ticks != 0 || clk.RunNext() {
+		t.Fatal("timers fired without RunNext or RunUntil")
+	}
+	if n := clk.RunUntil(start.Add(3*time.Minute + time.Second)); n != 9 {
+		t.Errorf("RunUntil fired %d timers, want 9", n)
+	}
+	if got := strings.Join(log, " "); got != "a b x y c" {
+		t.Errorf("callbacks ran in the order %q", got)
+	}
+	if ticks != 3 {
+		t.Errorf("ticker ticked %d times in 3 minutes", ticks)
+	}
+	select {
+	case v := <-channel.C:
+		if !v.Equal(deadline) {
+			t.Errorf("timer fired at %v, want its deadline %v", v, deadline)
+		}
+	default:
+		t.Error("channel timer did not fire")
+	}
+
+	// A timer with duration 0 is due right away.
+	fired := false
+	clk.AfterFunc(0, func() { fired = true })
+	if !clk.RunNext() || !fired {
+		t.Error("RunNext did not fire a due timer")
+	}
+}
+
+// The same simulation fires its timers in the same order on every run.
+func TestManualDispatchDeterministic(t *testing.T) {
+	run := func() string {
+		clk := NewScheduler(WithManualDispatch())
+		start := clk.Now()
+		var b strings.Builder
+		for i := 0; i < 50; i++ {
+			i := i
+			at := start.Add(time.Duration(i%7) * time.Second)
+			clk.AfterFunc(at.Sub(start), func() { fmt.Fprintf(&b, "%d ", i) })
+		}
+		clk.RunUntil(start.Add(time.Minute))
+		return b.String()
+	}
+	first := run()
+	for i := 0; i < 10; i++ {
+		if got := run(); got != first {
+			t.Fatalf("run %d fired %q, the first one %q", i, got, first)
+		}
+	}
+}
+
+func TestManualDispatchPanics(t *testing.T) {
+	for name, f := range map[string]func(){
+		"RunNext":    func() { NewScheduler().RunNext() },
+		"WithShards": func() { NewScheduler(WithManualDispatch(), WithShards(2)) },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("%s did not panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,89 @@
+package kairos
+
+import (
+	"slices"
+	"time"
+)
+
+// An Observer is notified of the life cycle of the timers of the Schedulers it is registered with
+// by RegisterObserver.
+type Observer interface {
+	// OnSchedule is called when t is armed (or re-armed by Reset, or for the next tick of a
+	// ticker) with the deadline when.
+	OnSchedule(t *Timer, when time.Time)
+	// OnFire is called when t expires, with its deadline and the time at which the expiry was
+	// processed.
+	OnFire(t *Timer, scheduled, actual time.Time)
+	// OnStop is called when t is stopped before it fires.
+	OnStop(t *Timer)
+}
+
+// The kinds of observation.
+const (
+	observeSchedule = iota
+	observeFire
+	observeStop
+)
+
+// A life-cycle event waiting to be passed to the observers, once the mutex is released.
+type observation struct {
+	t      *Timer
+	kind   int
+	when   time.Time
+	actual time.Time // The time of the expiry, for observeFire.
+}
+
+// RegisterObserver adds o to the observers of the timers of clk (and of its shards).
+func (clk *Scheduler) RegisterObserver(o Observer) {
+	for _, s := range clk.shards {
+		s.RegisterObserver(o)
+	}
+	clk.lock()
+	defer clk.unlock()
+	// Copied on write, so that the pending observations can be passed on without the mutex.
+	var observers []Observer
+	if p := clk.observers.Load(); p != nil {
+		observers = *p
+	}
+	observers = append(slices.Clip(observers), o)
+	clk.observers.Store(&observers)
+}
+
+// Queue an observation for the observers, if there are any.  The caller must hold the mutex.
+func (clk *Scheduler) observeLocked(t *Timer, kind int, when, actual time.Time) {
+	if clk.observers.Load() != nil {
+		clk.observed = append(clk.observed, observation{t: t, kind: kind, when: when, actual: actual})
+	}
+}
+
+// Pass the observations of a critical section that has ended to the observers, in order.
+func (clk *Scheduler) notify(obs []observation) {
+	for _, o := range *clk.observers.Load() {
+		for _, ob := range obs {
+			switch ob.kind {
+			case observeSchedule:
+				o.OnSchedule(ob.t, ob.when)
+			case observeFire:
+				o.OnFire(ob.t, ob.when, ob.actual)
+			case observeStop:
+				o.OnStop(ob.t)
+			}
+		}
+	}
+}
+
+// RegisterObserver adds o to the observers of the timers of the default
+// Scheduler. Observers are called for every timer that is armed, fires, or is
+// stopped, so that the life cycle of timers can be traced or measured without
+// changing the package. They are called without the mutex held, so they may
+// use the timers (and Stop or Reset them, say) but the events of a critical
+// section are only delivered when it ends, by the goroutine that held the
+// mutex: the one that armed or stopped the timer, or the timer goroutine for
+// the expiries. Observers must therefore be fast, and be safe for concurrent
+// use, since different goroutines may call them at once; the events of each
+// timer are delivered in order, unless the timer is used by several
+// goroutines at once. An observer cannot be unregistered. Without observers,
+// the cost is a single atomic load per event.
+func RegisterObserver(o Observer) {
+	defaultScheduler.RegisterObserver(o)
+}
@@ -0,0 +1,97 @@
+package kairos
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// An Observer that records the events of the timers it is interested in.
+type recorder struct {
+	mu     sync.Mutex
+	timers map[*Timer]string
+	events []string
+}
+
+func (r *recorder) record(t *Timer, format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name, ok := r.timers[t]; ok {
+		r.events = append(r.events, name+" "+fmt.Sprintf(format, args...))
+	}
+}
+
+func (r *recorder) OnSchedule(t *Timer, when time.Time) {
+	r.record(t, "scheduled in %v", time.Until(when).Round(time.Hour))
+}
+
+func (r *recorder) OnFire(t *Timer, scheduled, actual time.Time) {
+	r.record(t, "fired")
+	// Observers are called without the mutex.
+	t.Stop()
+}
+
+func (r *recorder) OnStop(t *Timer) { r.record(t, "stopped") }
+
+func TestObserver(t *testing.T) {
+	s := NewScheduler()
+	r1, r2 := &recorder{timers: map[*Timer]string{}}, &recorder{timers: map[*Timer]string{}}
+	// Registering while timers are being armed and stopped is safe.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.NewTimer(time.Duration(j) * time.Microsecond).Stop()
+			}
+		}()
+	}
+	s.RegisterObserver(r1)
+	s.RegisterObserver(r2)
+	wg.Wait()
+
+	a, b := s.NewStoppedTimer(), s.NewStoppedTimer()
+	for _, r := range []*recorder{r1, r2} {
+		r.mu.Lock()
+		r.timers[a], r.timers[b] = "a", "b"
+		r.mu.Unlock()
+	}
+	a.Reset(time.Hour)
+	b.Reset(2 * time.Hour)
+	a.Reset(0)
+	<-a.C
+	b.Stop()
+	b.Stop()
+	// The expiry of a is delivered by the timer routine after it releases the mutex.
+	time.Sleep(10 * time.Millisecond)
+	want := []string{"a scheduled in 1h0m0s", "b scheduled in 2h0m0s", "a scheduled in 0s", "a fired", "b stopped"}
+	for _, r := range []*recorder{r1, r2} {
+		r.mu.Lock()
+		if fmt.Sprint(r.events) != fmt.Sprint(want) {
+			t.Errorf("events %q, want %q", r.events, want)
+		}
+		r.mu.Unlock()
+	}
+}
+
+func TestObserverTickerReset(t *testing.T) {
+	s := NewScheduler()
+	r := &recorder{timers: map[*Timer]string{}}
+	s.RegisterObserver(r)
+	tk := s.NewTicker(time.Hour)
+	r.mu.Lock()
+	r.timers[tk.t] = "tk"
+	r.mu.Unlock()
+	tk.Reset(2 * time.Hour)
+	tk.Stop()
+	tk.Reset(3 * time.Hour)
+	tk.Stop()
+	want := []string{"tk scheduled in 2h0m0s", "tk stopped", "tk scheduled in 3h0m0s", "tk stopped"}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if fmt.Sprint(r.events) != fmt.Sprint(want) {
+		t.Errorf("events %q, want %q", r.events, want)
+	}
+}
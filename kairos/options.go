@@ -0,0 +1,240 @@
+package kairos
+
+import (
+	"time"
+)
+
+// An Option configures a Timer at construction time.
+type Option func(*Timer)
+
+// WithChannelBuffer gives the timer's channel C a capacity of n instead of 1, so
+// that a slow consumer of a frequently re-armed timer (see ResetKeepPending) can
+// observe how many fires it missed. Sends never block: once the buffer is full,
+// newer values are dropped. Reset drains the whole buffer. It has no effect on
+// timers that call a function instead (AfterFunc, NewStoppedFunc,
+// NewRepeatTimer). WithChannelBuffer panics if n is less than 1.
+func WithChannelBuffer(n int) Option {
+	if n < 1 {
+		panic("timer: WithChannelBuffer called with non-positive size")
+	}
+	return func(t *Timer) {
+		if t.f != nil || t.call != nil {
+			return
+		}
+		c := make(chan time.Time, n)
+		t.C, t.c = c, c
+	}
+}
+
+// WithModernSemantics makes the timer behave like a time.Timer in Go 1.23 and
+// later: no stale value can be received from C after Stop or Reset returns.
+// Stop clears the channel in addition to preventing the fire, and Reset (as
+// well as ResetKeepPending and ResetAt) only ever delivers the new expiry.
+// Because kairos sends on C while holding the same lock that Stop and Reset
+// take, no send for an earlier arming can be in flight when they return.
+func WithModernSemantics() Option {
+	return func(t *Timer) {
+		t.modern = true
+	}
+}
+
+// WithName gives the timer a name, like calling SetName right after
+// construction.
+func WithName(name string) Option {
+	return func(t *Timer) {
+		t.name = name
+	}
+}
+
+// WithPhasePreserved makes Ticker.Reset keep the ticker's phase: instead of
+// scheduling the next tick one new interval from now, it is scheduled at the
+// first multiple of the new interval since the ticker was created that is
+// still in the future. It has no effect on timers that are not tickers.
+func WithPhasePreserved() Option {
+	return func(t *Timer) {
+		t.phase = true
+	}
+}
+
+// WithDriftCorrection schedules each tick of a ticker (or each iteration of a
+// repeat timer) relative to the previous deadline instead of the time the
+// previous tick was actually processed, so that the n-th tick is due at
+// start+n*interval and lateness does not accumulate. If the process stalls for
+// longer than an interval, the missed deadlines are skipped rather than
+// delivered in a burst; Ticker.Skipped reports how many.
+func WithDriftCorrection() Option {
+	return func(t *Timer) {
+		t.drift = true
+	}
+}
+
+// WithJitter perturbs every deadline of the timer by a uniformly random amount
+// of up to ±fraction of its duration, which helps keep many timers started at
+// the same moment from firing in lockstep. The jitter is chosen each time the
+// timer is armed, so every tick of a ticker gets its own; it does not
+// accumulate, because each tick is still computed from the unperturbed
+// schedule. WithJitter panics if fraction is not between 0 and 1.
+func WithJitter(fraction float64) Option {
+	if !(fraction >= 0 && fraction <= 1) {
+		panic("timer: jitter fraction out of range")
+	}
+	return func(t *Timer) {
+		t.jitter = fraction
+	}
+}
+
+// WithJitterSource makes the timer draw the random numbers for WithJitter from
+// rnd instead of math/rand, for example to make tests deterministic. rnd must
+// return values in [0, 1) like rand.Float64; it is called with the timer's
+// lock held and must not call into the timer.
+func WithJitterSource(rnd func() float64) Option {
+	return func(t *Timer) {
+		t.rnd = rnd
+	}
+}
+
+// WithMissedPolicy selects what a ticker does about ticks that its receiver
+// was too slow to take; see MissedPolicy. With CoalesceMissed or ReplayMissed,
+// the ticks are delivered as TickEvent values on the Ticker's Ticks channel
+// instead of C, so that they can carry the number of missed ticks. Stop and
+// Reset discard the ticks that have not been replayed yet. For TickerFunc, it
+// selects what happens to ticks that are due while the function is still
+// running. It has no effect on timers that are not tickers.
+func WithMissedPolicy(p MissedPolicy) Option {
+	return func(t *Timer) {
+		t.policy = p
+	}
+}
+
+// WithDSTPolicy selects what a calendar timer (see NewCalendarTimer) does on
+// the days on which the clocks are set forward over its time of day: fire as
+// much later (ShiftNonexistent, the default) or not at all (SkipNonexistent).
+// It has no effect on other timers.
+func WithDSTPolicy(p DSTPolicy) Option {
+	return func(t *Timer) {
+		t.dst = p
+	}
+}
+
+// WithMaxTicks makes a ticker stop itself after it has ticked n times since it
+// was last started; Ticker.Done is closed when it does. WithMaxTicks panics if
+// n is less than 1.
+func WithMaxTicks(n int) Option {
+	if n < 1 {
+		panic("timer: WithMaxTicks called with non-positive count")
+	}
+	return func(t *Timer) {
+		t.limit = n
+	}
+}
+
+// WithDeadline makes a ticker stop itself at end; Ticker.Done is closed then.
+// No tick that is due at or after end is delivered, even if Reset is called
+// later.
+func WithDeadline(end time.Time) Option {
+	return func(t *Timer) {
+		t.end = end
+	}
+}
+
+// WithTickEvents makes a ticker deliver TickEvent values, which carry a
+// sequence number, on its Ticks channel instead of the time on C. Tickers
+// created with WithMissedPolicy(CoalesceMissed) or
+// WithMissedPolicy(ReplayMissed) always do. It has no effect on timers that
+// are not tickers.
+func WithTickEvents() Option {
+	return func(t *Timer) {
+		t.events = true
+	}
+}
+
+// WithImmediateFirstTick makes a new ticker tick right away instead of after
+// its first interval; the later ticks follow at the regular interval. The
+// immediate tick counts toward WithMaxTicks, and its TickEvent.Seq is 0. Pass
+// it to Ticker.Reset to get an immediate tick after changing the interval. It
+// has no effect on timers that are not tickers.
+func WithImmediateFirstTick() Option {
+	return func(t *Timer) {
+		t.immediate = true
+	}
+}
+
+// WithAsyncCallback makes every call of the timer's callback run in a new
+// goroutine even if SetCallbackWorkers has set up a pool, so that a
+// long-running callback does not tie up a worker. The calls may then overlap,
+// as they do without a pool. The timer is marked as fired before the
+// goroutine starts, and StopWait waits for the calls as usual. It has no
+// effect on timers without a callback.
+func WithAsyncCallback() Option {
+	return func(t *Timer) {
+		t.async = true
+	}
+}
+
+// WithPayload attaches payload to the timer and makes Snapshot record it, along
+// with the name and deadline of the timer, so that the timer can be re-created
+// by Restore after a restart. Timers without a payload are not snapshotted:
+// their callbacks and channels cannot be serialized. The payload is copied.
+func WithPayload(payload []byte) Option {
+	return func(t *Timer) {
+		t.payload = append([]byte{}, payload...)
+	}
+}
+
+// WithWallClock makes the deadline of the timer an instant of the wall clock
+// rather than a duration of the monotonic clock, for timers that must fire at a
+// given time of day: if the system clock is stepped (by NTP or by hand) past
+// the deadline, the timer fires within a second, and if it is stepped back, the
+// timer waits that much longer. The Scheduler checks the wall clock at least
+// once a second while it has pending timers, from the first time it is given a
+// wall-clock timer on. Timers without WithWallClock are unaffected by steps.
+// WithWallClock and WithIncludeSuspend replace each other.
+func WithWallClock() Option {
+	return func(t *Timer) {
+		t.ref = wallClock
+	}
+}
+
+// WithIncludeSuspend makes the timer count the time that the system spends
+// suspended, which Go's monotonic clock leaves out on Linux: a timer of 10
+// minutes armed an hour before a suspend fires within a second of the resume,
+// instead of 10 minutes later. On Linux, the deadline is an instant of
+// CLOCK_BOOTTIME; elsewhere, it is an instant of the wall clock. As with
+// WithWallClock, the Scheduler then checks the clock at least once a second
+// while it has pending timers, so it notices a resume even though its own
+// sleep is not cut short by it. WithWallClock and WithIncludeSuspend replace
+// each other.
+func WithIncludeSuspend() Option {
+	return func(t *Timer) {
+		t.ref = bootClock
+	}
+}
+
+// WithSlack lets the timer fire up to d after its deadline, never before, so
+// that the Scheduler can fire it along with other timers in a single wakeup:
+// the timer routine sleeps until the earliest time at which a pending timer
+// would be past the end of its slack, and then fires every timer that is due,
+// which saves wakeups (and battery) when many timers do not need to fire
+// exactly on time, such as keepalives. A timer without a slack fires on time,
+// and the slack timers due before it fire with it. WithSlack panics if d is
+// negative.
+func WithSlack(d time.Duration) Option {
+	if d < 0 {
+		panic("timer: negative slack for WithSlack")
+	}
+	return func(t *Timer) {
+		t.slack = d
+	}
+}
+
+// WithLateness makes an EventTimer fill in the Late field of the Events it
+// sends, which is zero otherwise. The timer routine computes it right before
+// it sends the Event, so it is the scheduling delay and excludes the delay of
+// the receiver. The callbacks of AfterFuncScheduled receive both times anyway,
+// and MaxLateness tracks the worst lateness of all timers whatever their
+// options, since the lateness histogram needs it for every expiry.
+func WithLateness() Option {
+	return func(t *Timer) {
+		t.late = true
+	}
+}
@@ -0,0 +1,186 @@
+package kairos
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+func TestWithChannelBuffer(t *testing.T) {
+	const n = 3
+	timer := NewTimer(0, WithChannelBuffer(n))
+	if got := cap(timer.C); got != n {
+		t.Fatalf("wrong channel capacity; got %v, want %v", got, n)
+	}
+	<-timer.C
+	// Fire more often than the buffer can hold without consuming.
+	for i := 0; i < n+2; i++ {
+		timer.ResetKeepPending(0)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := len(timer.C); got != n {
+		t.Errorf("wrong number of buffered values; got %v, want %v", got, n)
+	}
+	// The timer routine must not have blocked on the full channel.
+	select {
+	case <-NewTimer(0).C:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timer routine blocked by full channel")
+	}
+	timer.Reset(time.Hour)
+	t.Cleanup(func() { timer.Stop() })
+	if got := len(timer.C); got != 0 {
+		t.Errorf("Reset did not drain the whole buffer; %v values left", got)
+	}
+}
+
+func TestWithChannelBufferPanic(t *testing.T) {
+	defer func() {
+		r := recover()
+		if r == nil || r.(string) != "timer: WithChannelBuffer called with non-positive size" {
+			t.Errorf("invalid panic %v", r)
+		}
+	}()
+
+	WithChannelBuffer(0)
+}
+
+func TestWithChannelBufferAfterFunc(t *testing.T) {
+	timer := AfterFunc(time.Hour, func() {}, WithChannelBuffer(2))
+	t.Cleanup(func() { timer.Stop() })
+	if timer.C != nil {
+		t.Errorf("AfterFunc timer has non-nil C")
+	}
+}
+
+func TestModernSemanticsStop(t *testing.T) {
+	// The classic stale-value bug: the timer fires, nobody receives, Stop returns false, and a later
+	// receive yields the old value.
+	for _, modern := range []bool{false, true} {
+		t.Run(fmt.Sprintf("modern=%v", modern), func(t *testing.T) {
+			var opts []Option
+			if modern {
+				opts = append(opts, WithModernSemantics())
+			}
+			timer := NewTimer(0, opts...)
+			time.Sleep(100 * time.Millisecond)
+			if timer.Stop() {
+				t.Errorf("stop fired timer: was active is true")
+			}
+			wantLen := 1
+			if modern {
+				wantLen = 0
+			}
+			if got := len(timer.C); got != wantLen {
+				t.Errorf("wrong number of values after Stop; got %v, want %v", got, wantLen)
+			}
+		})
+	}
+}
+
+func TestModernSemanticsStopRace(t *testing.T) {
+	// Racing Stop against expiry must never leave a value behind.
+	var gr errgroup.Group
+	for i := 0; i < 1000; i++ {
+		d := time.Duration(i%20) * time.Microsecond
+		gr.Go(func() error {
+			timer := NewTimer(d, WithModernSemantics())
+			timer.Stop()
+			time.Sleep(time.Millisecond)
+			if len(timer.C) != 0 {
+				return fmt.Errorf("stale value on channel after Stop")
+			}
+			return nil
+		})
+	}
+	if err := gr.Wait(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModernSemanticsReset(t *testing.T) {
+	timer := NewTimer(0, WithModernSemantics())
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	timer.ResetKeepPending(100 * time.Millisecond)
+	select {
+	case got := <-timer.C:
+		if got.Before(start) {
+			t.Errorf("received stale value %v from before Reset at %v", got, start)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timer did not fire")
+	}
+}
+
+func TestWithJitter(t *testing.T) {
+	for _, tc := range []struct {
+		rnd  float64
+		want time.Duration
+	}{
+		{0, 30 * time.Minute},
+		{0.5, time.Hour},
+		{0.75, 75 * time.Minute},
+	} {
+		t.Run(fmt.Sprint(tc.rnd), func(t *testing.T) {
+			start := time.Now()
+			timer := NewTimer(time.Hour, WithJitter(0.5), WithJitterSource(func() float64 { return tc.rnd }))
+			end := time.Now()
+			t.Cleanup(func() { timer.Stop() })
+			when, _ := timer.Deadline()
+			// Allow for rounding, because the jitter is a fraction of the time
+			// left when the timer is armed, which is slightly less than an hour.
+			if when.Before(start.Add(tc.want-time.Millisecond)) || when.After(end.Add(tc.want)) {
+				t.Errorf("deadline %v after start, want %v", when.Sub(start), tc.want)
+			}
+			timer.Reset(time.Hour)
+			if when2, _ := timer.Deadline(); when2.Before(when) {
+				t.Errorf("jitter not applied by Reset")
+			}
+		})
+	}
+}
+
+func TestWithJitterTicker(t *testing.T) {
+	const d = 100 * time.Millisecond
+	// Alternate between -25ms and +25ms so that consecutive deadlines are
+	// 150ms and 50ms apart.  Drift correction makes that exact after the first
+	// tick: the jitter must be applied to the nominal schedule, not to the
+	// previous jittered deadline.
+	rnds := []float64{0.25, 0.75}
+	i := 0
+	tk := NewTicker(d, WithJitter(0.5), WithDriftCorrection(), WithJitterSource(func() float64 {
+		r := rnds[i%len(rnds)]
+		i++
+		return r
+	}))
+	defer tk.Stop()
+	prev, _ := tk.t.Deadline()
+	for k := 0; k < 4; k++ {
+		<-tk.C
+		when, _ := tk.t.Deadline()
+		want := d + 50*time.Millisecond
+		if k%2 == 1 {
+			want = d - 50*time.Millisecond
+		}
+		if got := when.Sub(prev); got != want && (k > 0 || got < want-time.Millisecond || got > want) {
+			t.Errorf("tick %v: deadline %v after previous one, want %v", k, got, want)
+		}
+		prev = when
+	}
+}
+
+func TestWithJitterPanic(t *testing.T) {
+	for _, f := range []float64{-0.1, 1.5} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithJitter(%v) did not panic", f)
+				}
+			}()
+			WithJitter(f)
+		}()
+	}
+}
@@ -0,0 +1,41 @@
+package kairos
+
+import (
+	"log"
+	"runtime/debug"
+)
+
+// Pass the value r recovered from a panic in a callback of t to the panic handler of clk.
+func (clk *Scheduler) handlePanic(t *Timer, r any) {
+	if h := clk.onPanic.Load(); h != nil {
+		(*h)(t, r)
+		return
+	}
+	log.Printf("timer: panic in callback of %v: %v\n%s", t, r, debug.Stack())
+}
+
+// SetPanicHandler makes h handle the panics of the callbacks of the timers of clk.  See the package
+// function SetPanicHandler.
+func (clk *Scheduler) SetPanicHandler(h func(t *Timer, recovered any)) {
+	var p *func(*Timer, any)
+	if h != nil {
+		p = &h
+	}
+	clk.onPanic.Store(p)
+	for _, s := range clk.shards {
+		s.onPanic.Store(p)
+	}
+}
+
+// SetPanicHandler sets the function that is called when the callback of a
+// timer (of AfterFunc, TickerFunc, NewDynamicTicker and so on) panics. The
+// panic is recovered, so it affects neither the other timers nor the timer t
+// itself, which has been updated as usual before the callback was called: a
+// one-shot timer is no longer pending, and a repeating timer keeps repeating.
+// h is called in the goroutine of the callback, with the timer and the value
+// passed to panic, after StopWait has stopped waiting for the call; it may
+// panic again to crash the program deliberately. If h is nil (the default),
+// the panic and its stack trace are logged with the log package.
+func SetPanicHandler(h func(t *Timer, recovered any)) {
+	defaultScheduler.SetPanicHandler(h)
+}
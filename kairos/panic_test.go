@@ -0,0 +1,54 @@
+package kairos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPanicHandler(t *testing.T) {
+	s := NewScheduler()
+	type recovered struct {
+		t *Timer
+		r any
+	}
+	panics := make(chan recovered, 1)
+	s.SetPanicHandler(func(t *Timer, r any) { panics <- recovered{t, r} })
+	bad := s.AfterFunc(0, func() { panic("boom") })
+	select {
+	case got := <-panics:
+		if got.t != bad || got.r != "boom" {
+			t.Errorf("handler called with %p, %v; want %p, boom", got.t, got.r, bad)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("panic handler was not called")
+	}
+	if n := s.PendingCount(); n != 0 {
+		t.Errorf("%v timers pending after the panic", n)
+	}
+	if bad.StopWait() {
+		t.Errorf("stop timer with panicked callback: was active is true")
+	}
+	select {
+	case <-s.NewTimer(10 * time.Millisecond).C:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timer did not fire after a callback panicked")
+	}
+}
+
+func TestPanicHandlerWorkers(t *testing.T) {
+	// A panic must not take a pool worker down.
+	s := NewScheduler(WithShards(2))
+	s.SetCallbackWorkers(1)
+	defer s.SetCallbackWorkers(0)
+	panics := make(chan any, 1)
+	s.SetPanicHandler(func(_ *Timer, r any) { panics <- r })
+	s.AfterFunc(0, func() { panic("boom") })
+	<-panics
+	called := make(chan struct{})
+	s.AfterFunc(0, func() { close(called) })
+	select {
+	case <-called:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("callback did not run after another one panicked")
+	}
+}
@@ -0,0 +1,164 @@
+package kairos
+
+import (
+	"sync"
+)
+
+// Number of timers that the queue of a callback pool holds per worker.
+const poolQueuePerWorker = 64
+
+// A callbackPool is a fixed set of goroutines that run the callbacks of the timers of a Scheduler
+// and of its shards, set up by SetCallbackWorkers.
+type callbackPool struct {
+	owner *Scheduler // The Scheduler whose SetCallbackWorkers created the pool.
+	n     int        // Number of workers.
+	wg    sync.WaitGroup
+
+	mu     sync.Mutex  // protects:
+	closed bool        // Whether queue is closed.
+	queue  chan *Timer // Timers with callbacks to run, each at most once (see Timer.pooled).
+}
+
+// A callback call waiting in the queue of a timer for a pool worker.
+type callbackJob struct {
+	f    func()
+	call func(expiry)
+	e    expiry
+}
+
+// Create a pool of n workers for owner and start them.
+func newCallbackPool(owner *Scheduler, n int) *callbackPool {
+	p := &callbackPool{owner: owner, n: n, queue: make(chan *Timer, n*poolQueuePerWorker)}
+	p.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *callbackPool) work() {
+	defer p.wg.Done()
+	id := goid()
+	for t := range p.queue {
+		t.runJobs(id)
+	}
+}
+
+// Queue t for a worker, unless the queue is full or closed, and report whether it was queued.
+func (p *callbackPool) submit(t *Timer) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	select {
+	case p.queue <- t:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stop accepting timers and wait until the workers have run all the callbacks already queued.
+func (p *callbackPool) close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// Run the queued callback calls of t one after the other, oldest first, until there are none
+// left, in the goroutine whose ID is id (or 0, for runFuncIn to look it up if needed).  Keeping all
+// the calls of t in a single goroutine at a time is what keeps them in order.
+func (t *Timer) runJobs(id uint64) {
+	clk := t.clk
+	clk.lock()
+	for len(t.jobs) > 0 {
+		j := t.jobs[0]
+		t.jobs[0] = callbackJob{}
+		t.jobs = t.jobs[1:]
+		clk.unlock()
+		t.runFuncIn(id, j.f, j.call, j.e)
+		clk.lock()
+	}
+	t.pooled = false
+	clk.unlock()
+}
+
+// Arrange for f (or call, if f is nil) to be called with e, in a worker of the callback pool if
+// clk has one and t was not created with WithAsyncCallback, or else in a new goroutine.  The caller
+// must hold the mutex.
+func (clk *Scheduler) dispatchLocked(t *Timer, f func(), call func(expiry), e expiry) {
+	t.inflight++
+	clk.callbacks++
+	p := clk.pool
+	if p == nil || t.async || clk.manualDispatch {
+		clk.goLocked(t, f, call, e)
+		return
+	}
+	t.jobs = append(t.jobs, callbackJob{f: f, call: call, e: e})
+	if t.pooled {
+		// The goroutine that runs the earlier calls runs this one after them.
+		return
+	}
+	t.pooled = true
+	if !p.submit(t) {
+		go t.runJobs(0)
+	}
+}
+
+// Make p the callback pool of clk and of its shards, and return the previous one.
+func (clk *Scheduler) setPool(p *callbackPool) *callbackPool {
+	for _, s := range clk.shards {
+		s.lock()
+		s.pool = p
+		s.unlock()
+	}
+	clk.lock()
+	old := clk.pool
+	clk.pool = p
+	clk.unlock()
+	return old
+}
+
+// Close the callback pool of clk, if clk created it, and wait for the queued callbacks.
+func (clk *Scheduler) closePool() {
+	clk.lock()
+	p := clk.pool
+	clk.unlock()
+	if p != nil && p.owner == clk {
+		p.close()
+	}
+}
+
+// SetCallbackWorkers makes n goroutines run the callbacks of the timers of clk, instead of a new
+// goroutine per call.  See the package function SetCallbackWorkers.
+func (clk *Scheduler) SetCallbackWorkers(n int) {
+	if n < 0 {
+		panic("timer: negative worker count for SetCallbackWorkers")
+	}
+	var p *callbackPool
+	if n > 0 {
+		p = newCallbackPool(clk, n)
+	}
+	if old := clk.setPool(p); old != nil {
+		old.close()
+	}
+}
+
+// SetCallbackWorkers makes a pool of n goroutines run the callbacks of
+// AfterFunc, AfterFuncScheduled, NewTimerFuncCtx, and NewRepeatTimer timers,
+// which bounds the number of goroutines that callbacks can use at the same time.
+// If n is 0 (the default), every call runs in its own goroutine. The calls of a
+// single timer always run one at a time in the order of the fires, even if the
+// timer is reset in between. The calls wait in a queue that holds 64 timers
+// per worker; if it is full, the calls of the fired timer run in a new
+// goroutine instead, as if n were 0 (but still in order). SetCallbackWorkers
+// waits for the workers of the previous pool to run the calls already queued
+// for them, and so does Shutdown. SetCallbackWorkers panics if n < 0.
+func SetCallbackWorkers(n int) {
+	defaultScheduler.SetCallbackWorkers(n)
+}
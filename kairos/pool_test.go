@@ -0,0 +1,154 @@
+package kairos
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCallbackWorkers(t *testing.T) {
+	s := NewScheduler()
+	s.SetCallbackWorkers(2)
+	var running, most atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		s.AfterFunc(0, func() {
+			defer wg.Done()
+			n := running.Add(1)
+			for {
+				m := most.Load()
+				if n <= m || most.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+		})
+	}
+	wg.Wait()
+	if m := most.Load(); m > 2 {
+		t.Errorf("%v callbacks ran at the same time with 2 workers", m)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() = %v", err)
+	}
+}
+
+func TestCallbackWorkersOrder(t *testing.T) {
+	s := NewScheduler()
+	s.SetCallbackWorkers(4)
+	// The callback is slower than the interval, so the fires pile up.
+	const count = 10
+	var busy atomic.Bool
+	calls := make(chan int, count)
+	timer := s.NewRepeatTimer(time.Millisecond, count, func(n int) {
+		if !busy.CompareAndSwap(false, true) {
+			t.Errorf("call %v overlaps another one", n)
+		}
+		time.Sleep(3 * time.Millisecond)
+		busy.Store(false)
+		calls <- n
+	})
+	for want := 1; want <= count; want++ {
+		select {
+		case got := <-calls:
+			if got != want {
+				t.Fatalf("call %v ran in place of call %v", got, want)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("call %v did not run", want)
+		}
+	}
+	timer.Stop()
+	s.SetCallbackWorkers(0)
+}
+
+func TestCallbackWorkersOverflow(t *testing.T) {
+	s := NewScheduler()
+	s.SetCallbackWorkers(1)
+	release := make(chan struct{})
+	s.AfterFunc(0, func() { <-release })
+	// More timers than the queue holds, none of which may be lost.
+	const n = 2 * poolQueuePerWorker
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		s.AfterFunc(time.Millisecond, wg.Done)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Errorf("all callbacks ran while the only worker was blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("callbacks lost when the queue was full")
+	}
+	s.SetCallbackWorkers(0)
+}
+
+func TestCallbackWorkersShutdown(t *testing.T) {
+	s := NewScheduler(WithShards(2))
+	s.SetCallbackWorkers(1)
+	var calls atomic.Int32
+	for i := 0; i < 10; i++ {
+		s.AfterFunc(0, func() {
+			time.Sleep(2 * time.Millisecond)
+			calls.Add(1)
+		})
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() = %v", err)
+	}
+	if n := calls.Load(); n != 10 {
+		t.Errorf("%v of 10 queued callbacks ran before Shutdown returned", n)
+	}
+	s.Start()
+	called := make(chan struct{})
+	s.AfterFunc(0, func() { close(called) })
+	<-called
+	s.SetCallbackWorkers(0)
+}
+
+func TestAsyncCallback(t *testing.T) {
+	s := NewScheduler()
+	s.SetCallbackWorkers(1)
+	defer s.SetCallbackWorkers(0)
+	release := make(chan struct{})
+	s.AfterFunc(0, func() { <-release })
+	called := make(chan struct{})
+	timer := s.AfterFunc(0, func() {
+		close(called)
+		<-release
+	}, WithAsyncCallback())
+	select {
+	case <-called:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("async callback waited for the busy worker")
+	}
+	if got := timer.State(); got != Fired {
+		t.Errorf("State() = %v during the callback, want %v", got, Fired)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		timer.StopWait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Errorf("StopWait returned while the async callback was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-stopped
+}
@@ -0,0 +1,62 @@
+package kairos
+
+import (
+	"context"
+	"time"
+)
+
+// Return a stopped timer with an empty channel from the pool of clk, started with duration d.
+func (clk *Scheduler) getRecvTimer(d time.Duration) *Timer {
+	t, _ := clk.recvTimers.Get().(*Timer)
+	if t == nil {
+		t = clk.NewStoppedTimer()
+	}
+	t.clk.resetTimer(t, t.clk.now().Add(d))
+	return t
+}
+
+// Stop t, drain its channel, and return it to the pool of clk.  Draining in the same critical
+// section as stopping guarantees that the next user of t cannot receive a stale value.
+func (clk *Scheduler) putRecvTimer(t *Timer) {
+	t.clk.stopAndDrainTimer(t)
+	clk.recvTimers.Put(t)
+}
+
+// Receive from ch, giving up after d, using a timer of clk.
+func recv[T any](clk *Scheduler, ctx context.Context, ch <-chan T, d time.Duration) (v T, ok bool, err error) {
+	if d <= 0 {
+		select {
+		case v, ok = <-ch:
+			return v, ok, nil
+		default:
+			return v, false, context.DeadlineExceeded
+		}
+	}
+	t := clk.getRecvTimer(d)
+	defer clk.putRecvTimer(t)
+	select {
+	case v, ok = <-ch:
+		return v, ok, nil
+	case <-t.C:
+		return v, false, context.DeadlineExceeded
+	case <-ctx.Done():
+		return v, false, ctx.Err()
+	}
+}
+
+// Recv receives a value from ch, waiting at most d for one. It returns the
+// value and ok like a receive operation does (ok is false if ch is closed), or
+// timedOut true if d elapsed first. If d <= 0, Recv does not wait at all. The
+// timer comes from a pool and is stopped and drained before Recv returns,
+// whichever case won, so it never leaves anything in the heap.
+func Recv[T any](ch <-chan T, d time.Duration) (v T, ok bool, timedOut bool) {
+	v, ok, err := recv(defaultScheduler, context.Background(), ch, d)
+	return v, ok, err != nil
+}
+
+// RecvContext is like Recv, but it also gives up when ctx is done. The error
+// is nil if a value was received or ch is closed, context.DeadlineExceeded if
+// d elapsed first, and ctx.Err() if ctx was done first.
+func RecvContext[T any](ctx context.Context, ch <-chan T, d time.Duration) (v T, ok bool, err error) {
+	return recv(defaultScheduler, ctx, ch, d)
+}
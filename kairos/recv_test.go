@@ -0,0 +1,62 @@
+package kairos
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRecv(t *testing.T) {
+	ch := make(chan int, 1)
+	ch <- 42
+	if v, ok, timedOut := Recv(ch, time.Hour); v != 42 || !ok || timedOut {
+		t.Errorf("Recv() = %v, %v, %v; want 42, true, false", v, ok, timedOut)
+	}
+	const d = 50 * time.Millisecond
+	start := time.Now()
+	if v, ok, timedOut := Recv(ch, d); v != 0 || ok || !timedOut {
+		t.Errorf("Recv() = %v, %v, %v; want 0, false, true", v, ok, timedOut)
+	}
+	if got := time.Since(start); got < d || got >= d+margin {
+		t.Errorf("Recv timed out after %v, want %v", got, d)
+	}
+	close(ch)
+	if _, ok, timedOut := Recv(ch, time.Hour); ok || timedOut {
+		t.Errorf("Recv() on closed channel = %v, %v; want false, false", ok, timedOut)
+	}
+	if _, _, timedOut := Recv(make(chan int), 0); !timedOut {
+		t.Errorf("Recv with zero duration did not time out")
+	}
+}
+
+func TestRecvReuse(t *testing.T) {
+	clk := NewScheduler()
+	ch := make(chan int)
+	// Race values against timeouts, so that some timers fire after the value has won and go back to
+	// the pool with a value on their channel unless it is drained.
+	for i := 0; i < 100; i++ {
+		go func() { ch <- 1 }()
+		time.Sleep(time.Millisecond)
+		if _, _, err := recv(clk, context.Background(), ch, time.Millisecond); err != nil {
+			<-ch // The value lost; do not leave the sender behind.
+		}
+	}
+	start := time.Now()
+	if _, _, err := recv(clk, context.Background(), ch, 20*time.Millisecond); err == nil || time.Since(start) < 20*time.Millisecond {
+		t.Errorf("stale value from a reused timer")
+	}
+	if n := clk.PendingCount(); n != 0 {
+		t.Errorf("%v timers left in the heap", n)
+	}
+}
+
+func TestRecvContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok, err := RecvContext(ctx, make(chan int), time.Hour); ok || err != context.Canceled {
+		t.Errorf("RecvContext() = %v, %v; want false, %v", ok, err, context.Canceled)
+	}
+	if _, ok, err := RecvContext(context.Background(), make(chan int), time.Millisecond); ok || err != context.DeadlineExceeded {
+		t.Errorf("RecvContext() = %v, %v; want false, %v", ok, err, context.DeadlineExceeded)
+	}
+}
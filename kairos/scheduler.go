@@ -0,0 +1,1341 @@
+package kairos
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// The Scheduler used by the package-level functions.
+var defaultScheduler = NewScheduler(WithShards(runtime.GOMAXPROCS(0)))
+
+// A Scheduler is an independent timer domain: it has its own heap of timers, its own lock, and its
+// own goroutine that fires them, so that its timers do not contend with those of other Schedulers.
+// The package-level functions use a default Scheduler; the methods of the same names create
+// timers of s instead.  A Scheduler must be created with NewScheduler.
+type Scheduler struct {
+	rescheduleC chan struct{}
+	mutex       sync.Mutex // protects:
+	timers      timerQueue
+	// Broadcast whenever an AfterFunc callback or a tick replay returns, and when the heap becomes
+	// empty.
+	funcDone  *sync.Cond
+	callbacks int           // Number of calls to the callbacks of the timers that have not returned yet.
+	shutdown  bool          // Whether new timers are refused; set by Shutdown until Start.
+	stopped   bool          // Whether Shutdown has completed.
+	started   bool          // Whether the timer routine is running; it is started by the first timer.
+	quit      chan struct{} // Closed by Shutdown to terminate the timer routine...
+	exited    chan struct{} // ...which closes this channel when it returns.
+	// Number of tick replays of the tickers of clk that have been stopped but whose goroutines have
+	// not exited yet; see stopReplayLocked.  Guarded by mutex.
+	stoppingReplays int
+
+	maxLate atomic.Int64 // Worst lateness (in nanoseconds) of any expiry processed so far.
+	stats   counters
+	latency atomic.Pointer[latencyHistogram] // Replaced by SetLatencyBuckets and ResetStats.
+	batch   int                              // Maximum number of expiries per critical section; see WithExpiryBatch.
+	capHint int                              // Set by SetCapacityHint; the heap never shrinks below it.  Guarded by mutex.
+	queued  uint64                           // Number of timers queued so far, for Timer.order.  Guarded by mutex.
+
+	recvTimers sync.Pool // Stopped, drained timers for Recv and RecvContext.
+	freeTimers sync.Pool // Timers released by ReleaseTimer.
+
+	pool *callbackPool // Set up by SetCallbackWorkers; shared by the shards.  Guarded by mutex.
+
+	onPanic atomic.Pointer[func(*Timer, any)] // Set by SetPanicHandler.
+
+	observers atomic.Pointer[[]Observer] // Set by RegisterObserver; nil if there are none.
+	observed  []observation              // Made in the current critical section.  Guarded by mutex.
+
+	// Created by NextDeadlineChanged, and signaled by unlock if the earliest deadline is no longer
+	// lastDeadline.  Guarded by mutex.
+	deadlineC    chan struct{}
+	lastDeadline time.Time
+	// Created by FakeClock.BlockUntilTimers, and closed when a timer is added to the heap or removed
+	// from it.  Guarded by mutex.
+	countC chan struct{}
+
+	// The deadline until which the timer routine sleeps, as a duration since base, or MaxInt64 if
+	// it waits for a timer to be added or is not running.  The timer routine stores it with the
+	// mutex held (except when it exits), and wakeFor lowers it.
+	sleepUntil atomic.Int64
+	base       time.Time
+	beat       atomic.Int64 // When the timer routine last woke up, as a duration since base.
+
+	// The source of the current time, if it is not time.Now (see WithNowFunc), how long to sleep
+	// until a time by it (see WithWaitFunc), and whether it only moves when told to (see
+	// FakeClock), in which case the timer routine never sleeps, but waits to be woken up.
+	nowFunc    func() time.Time
+	waitFunc   func(until time.Time) time.Duration
+	manualTime bool
+
+	// The virtual time of clk, if it was created with WithVirtualTime, in which case the timer
+	// routine jumps to the next deadline instead of sleeping, once the callbacks have returned.
+	virtual *virtualClock
+
+	// Whether clk is frozen (see Freeze), in which case the timer routine fires no timer, and
+	// since when.  Guarded by mutex, but frozen may be read without it.
+	frozen   atomic.Bool
+	frozenAt time.Time
+
+	// The clocks other than the monotonic one that the timers of clk have followed (see
+	// WithWallClock and WithIncludeSuspend), as a set of bits, in which case the timer routine
+	// wakes up at least every refCheckInterval to check them, and how far ahead of the monotonic
+	// clock each of them was the last time that the routine adjusted the timers that follow it.
+	// Guarded by mutex.
+	refClocks uint8
+	refOff    [numRefClocks]time.Duration
+
+	// Set by WithTimerFD, and the timerfds that the timer routine sleeps on while it runs, if so.
+	// Guarded by mutex.
+	useTimerFD bool
+	tfd        *timerFD
+
+	highRes bool // Set by WithHighResolution.
+
+	// Whether clk has had a timer with a slack (see WithSlack), in which case the timer routine
+	// sleeps until the end of the earliest slack instead of the earliest deadline.  Guarded by
+	// mutex.
+	slack bool
+
+	// Set by WithManualDispatch, and the calls of callbacks that the goroutine running RunNext or
+	// RunUntil is to make once it releases the mutex.  Guarded by mutex.
+	manualDispatch bool
+	calls          []func()
+
+	// Set by SetStallHandler, and the watchdog that calls it, if it is running.  Guarded by mutex.
+	onStall  func(lag time.Duration)
+	stallLag time.Duration
+	watchdog *watchdog
+
+	// The shards that own the timers of clk, if it is sharded (see WithShards).  The fields above
+	// are unused then, except for recvTimers, freeTimers, pool, the stall handler, and the shutdown
+	// flags.
+	shards  []*Scheduler
+	nshards int // Set by WithShards.
+}
+
+// A SchedulerOption configures a Scheduler created by NewScheduler.
+type SchedulerOption func(*Scheduler)
+
+// The default maximum number of expiries that the timer routine processes per critical section.
+const defaultBatch = 1024
+
+// WithExpiryBatch limits the number of expired timers that the timer routine of the Scheduler
+// processes in a single critical section to n (1024 by default).  Once it has processed n, it
+// releases the mutex, so that the goroutines waiting to add, reset, or stop timers are not held up
+// by a long backlog, and then goes on with the remaining expired timers, still in deadline order;
+// none is skipped.  WithExpiryBatch panics if n < 1.
+func WithExpiryBatch(n int) SchedulerOption {
+	if n < 1 {
+		panic("timer: non-positive batch size for WithExpiryBatch")
+	}
+	return func(clk *Scheduler) {
+		clk.batch = n
+	}
+}
+
+// WithTimingWheel makes the Scheduler keep its timers in a hierarchical timing wheel with ticks of
+// tick and wheelSize slots per level (rounded up to a power of two, and to at least 64), instead of
+// a heap.  Starting and stopping a timer then take constant time however many timers are pending,
+// which pays off with hundreds of thousands of them, but every deadline is rounded up to the next
+// tick: a timer may fire up to tick late.  Timers that are due in the same tick still fire in the
+// order of their exact deadlines.  WithTimingWheel panics if tick <= 0 or wheelSize < 1.
+func WithTimingWheel(tick time.Duration, wheelSize int) SchedulerOption {
+	if tick <= 0 || wheelSize < 1 {
+		panic("timer: invalid tick or size for WithTimingWheel")
+	}
+	return func(clk *Scheduler) {
+		// NewScheduler sets the base once the source of the time is known.
+		clk.timers = newTimingWheel(tick, wheelSize, time.Time{})
+	}
+}
+
+// NewScheduler creates a new Scheduler configured by opts.  Its goroutine is started when its first
+// timer is, so a Scheduler that is never used costs no goroutine.  This also makes a Scheduler that
+// is created in a testing/synctest bubble run entirely in the bubble, on its fake time, as long as
+// it is shut down (see Shutdown) before the bubble ends.  The default Scheduler must not be used in
+// a bubble, since its goroutine and timers belong to the whole program.
+func NewScheduler(opts ...SchedulerOption) *Scheduler {
+	clk := &Scheduler{rescheduleC: make(chan struct{}, 1), timers: &timerHeap{}, batch: defaultBatch}
+	clk.funcDone = sync.NewCond(&clk.mutex)
+	clk.latency.Store(newLatencyHistogram(defaultLatencyBounds))
+	clk.sleepUntil.Store(math.MaxInt64)
+	for _, opt := range opts {
+		opt(clk)
+	}
+	clk.base = clk.now()
+	if w, ok := clk.timers.(*timingWheel); ok {
+		w.base = clk.base
+	}
+	if clk.manualDispatch && clk.nshards > 1 {
+		panic("timer: WithManualDispatch and WithShards are mutually exclusive")
+	}
+	if clk.virtual != nil && clk.nshards > 1 {
+		panic("timer: WithVirtualTime and WithShards are mutually exclusive")
+	}
+	if clk.nshards > 1 {
+		// The shards are configured like clk, except that they are not sharded themselves.
+		shardOpts := append(opts[:len(opts):len(opts)], WithShards(1))
+		clk.shards = make([]*Scheduler, clk.nshards)
+		for i := range clk.shards {
+			clk.shards[i] = NewScheduler(shardOpts...)
+		}
+	}
+	return clk
+}
+
+// Shutdown stops clk: it refuses new timers, waits for the pending ones to fire until ctx is done,
+// stops the ones that are still pending then, and terminates the goroutine of clk.  It also waits
+// for the workers set up by SetCallbackWorkers to run the callbacks queued for them.
+func (clk *Scheduler) Shutdown(ctx context.Context) error {
+	if clk.shards != nil {
+		err := clk.shutdownShards(ctx)
+		clk.closePool()
+		clk.lock()
+		clk.shutdown, clk.stopped = true, true
+		w := clk.takeWatchdogLocked()
+		clk.unlock()
+		w.stop()
+		return err
+	}
+	clk.lock()
+	if clk.shutdown {
+		clk.unlock()
+		return nil
+	}
+	clk.shutdown = true
+	stop := context.AfterFunc(ctx, func() {
+		clk.lock()
+		clk.funcDone.Broadcast()
+		clk.unlock()
+	})
+	for clk.timers.Len() > 0 && ctx.Err() == nil {
+		clk.funcDone.Wait()
+	}
+	stop()
+	var err error
+	var onStops []func()
+	for clk.timers.Len() > 0 {
+		err = ctx.Err()
+		t := clk.timers.Peek()
+		b := clk.delTimerLocked(t)
+		t.endLocked()
+		if f := t.takeOnStopLocked(b); f != nil {
+			onStops = append(onStops, f)
+		}
+	}
+	started, exited := clk.started, clk.exited
+	if started {
+		close(clk.quit)
+	}
+	clk.unlock()
+	if started {
+		<-exited
+	}
+	clk.closePool()
+	clk.lock()
+	clk.stopped = true
+	clk.started = false
+	w := clk.takeWatchdogLocked()
+	clk.unlock()
+	w.stop()
+	for _, f := range onStops {
+		f()
+	}
+	return err
+}
+
+// Start restarts clk after Shutdown.  It does nothing if clk is running or still shutting down.
+func (clk *Scheduler) Start() {
+	for _, s := range clk.shards {
+		s.Start()
+	}
+	clk.lock()
+	if !clk.stopped {
+		clk.unlock()
+		return
+	}
+	clk.shutdown, clk.stopped = false, false
+	clk.startWatchdogLocked()
+	p := clk.pool
+	clk.unlock()
+	// Shutdown closed the callback pool, so replace it with one of the same size.
+	if p != nil && p.owner == clk {
+		clk.setPool(newCallbackPool(clk, p.n))
+	}
+}
+
+// Release the memory of the heap if it has shrunk a lot, and wake up Shutdown and WaitIdle if it has
+// become empty while they wait for that.  The caller must hold the mutex.
+func (clk *Scheduler) removedLocked() {
+	clk.stats.pending.Store(int64(clk.timers.Len()))
+	clk.timers.Shrink(clk.capHint)
+	clk.countChangedLocked()
+	if clk.timers.Len() == 0 {
+		clk.funcDone.Broadcast()
+	}
+}
+
+// NewTimer creates a new [Timer] and starts it with duration d.
+func (clk *Scheduler) NewTimer(d time.Duration, opts ...Option) *Timer {
+	return clk.NewTimerAt(clk.now().Add(d), opts...)
+}
+
+// NewTimerAt creates a new [Timer] and starts it with deadline when.  If when is in the past, the
+// timer fires as soon as possible.
+func (clk *Scheduler) NewTimerAt(when time.Time, opts ...Option) *Timer {
+	t := clk.NewStoppedTimer(opts...)
+	t.clk.resetTimer(t, when)
+	return t
+}
+
+// NewStoppedTimer creates a new stopped [Timer].  Call [Timer.Reset] to start it.
+func (clk *Scheduler) NewStoppedTimer(opts ...Option) *Timer {
+	t := clk.newTimer(nil, nil, opts)
+	if t.c == nil {
+		c := make(chan time.Time, 1)
+		t.C, t.c = c, c
+	}
+	return t
+}
+
+// NewStoppedFunc creates a new stopped [Timer] that calls f in its own goroutine when it expires.
+// Call [Timer.Reset] to start it.
+func (clk *Scheduler) NewStoppedFunc(f func(), opts ...Option) *Timer {
+	return clk.newTimer(f, nil, opts)
+}
+
+// AfterFunc creates a new [Timer] that calls f in its own goroutine after duration d.
+func (clk *Scheduler) AfterFunc(d time.Duration, f func(), opts ...Option) *Timer {
+	t := clk.NewStoppedFunc(f, opts...)
+	t.clk.resetTimer(t, clk.now().Add(d))
+	return t
+}
+
+// AfterFuncScheduled creates a new [Timer] that calls f in its own goroutine after duration d with
+// the deadline the timer was armed with and the time at which the expiry was processed.
+func (clk *Scheduler) AfterFuncScheduled(d time.Duration, f func(scheduled, actual time.Time), opts ...Option) *Timer {
+	t := clk.newTimer(nil, func(e expiry) { f(e.scheduled, e.actual) }, opts)
+	t.clk.resetTimer(t, clk.now().Add(d))
+	return t
+}
+
+// NewTimerFuncCtx creates a new [Timer] that calls f in its own goroutine after duration d, with a
+// context that is canceled when the timer is stopped or reset.
+func (clk *Scheduler) NewTimerFuncCtx(d time.Duration, f func(ctx context.Context, fired time.Time), opts ...Option) *Timer {
+	t := clk.newTimer(nil, func(e expiry) { f(e.ctx, e.actual) }, opts)
+	// rearmTimerLocked replaces the context for every arming.
+	t.cancel = func() {}
+	t.clk.resetTimer(t, clk.now().Add(d))
+	return t
+}
+
+// NewRepeatTimer creates a new [Timer] that calls f in its own goroutine every d, count times.
+func (clk *Scheduler) NewRepeatTimer(d time.Duration, count int, f func(n int), opts ...Option) *Timer {
+	t := clk.newTimer(nil, func(e expiry) { f(e.n) }, opts)
+	t.period = d
+	t.limit = count
+	t.clk.resetTimer(t, clk.now().Add(d))
+	return t
+}
+
+// Allocate a new stopped timer with callback f or call (both nil for channel timers) and apply opts
+// to it.  The timer belongs to one of the shards of clk, if clk is sharded; the callers must use
+// t.clk rather than clk for the operations on t.
+func (clk *Scheduler) newTimer(f func(), call func(expiry), opts []Option) *Timer {
+	t := &Timer{f: f, call: call, i: -1}
+	t.clk = clk.shard(unsafe.Pointer(t))
+	t.recordCallSite()
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// After waits for the duration to elapse and then sends the current time on the returned channel.
+// The underlying [Timer] is removed from the heap when it fires, so it can be garbage collected even
+// if the channel is never read.
+func (clk *Scheduler) After(d time.Duration) <-chan time.Time {
+	return clk.NewTimer(d).C
+}
+
+// Delete timer t from the heap.
+// It returns true if t was removed, false if t wasn't even there.  Because the timer routine
+// delivers the notification in the same critical section that removes an expired timer, a true
+// return value also means that the notification was prevented.
+// Timers created with WithModernSemantics also have their channel cleared so that a stale value
+// cannot be received after Stop returns.
+func (clk *Scheduler) delTimer(t *Timer) bool {
+	clk.lock()
+	b := clk.delTimerLocked(t)
+	if t.modern {
+		t.drainLocked()
+	}
+	t.endLocked()
+	onStop := t.takeOnStopLocked(b)
+	clk.unlock()
+	if onStop != nil {
+		onStop()
+	}
+	return b
+}
+
+// Delete timer t from the heap and wait until none of its callbacks are running, except the one
+// running in the calling goroutine (if any), so that StopWait can be called from the callback itself.
+func (clk *Scheduler) stopWaitTimer(t *Timer) bool {
+	self := goid()
+	fromCallback := inCallback()
+	clk.lock()
+	b := clk.delTimerLocked(t)
+	t.endLocked()
+	onStop := t.takeOnStopLocked(b)
+	t.waited = true
+	for {
+		n := t.inflight
+		anonymous := false
+		for _, id := range t.running {
+			switch id {
+			case self:
+				n--
+				fromCallback = false
+			case 0:
+				anonymous = true
+			}
+		}
+		if fromCallback && anonymous {
+			// A call started before the first StopWait did not record its goroutine, so if
+			// this one comes from a callback, it may be that call; it is not waited for.
+			n--
+		}
+		// Callbacks that have been started but have not registered their goroutine yet cannot be
+		// the calling goroutine, so they are always waited for.
+		if n <= 0 {
+			break
+		}
+		clk.funcDone.Wait()
+	}
+	clk.unlock()
+	if onStop != nil {
+		onStop()
+	}
+	return b
+}
+
+// Delete timer t from the heap and clear its channel in the same critical section, so that no value
+// can be sent or left behind afterwards.  It returns whether t had fired since it was last started.
+func (clk *Scheduler) stopAndDrainTimer(t *Timer) bool {
+	clk.lock()
+	onStop := t.takeOnStopLocked(clk.delTimerLocked(t))
+	t.drainLocked()
+	t.endLocked()
+	fired := t.state == Fired
+	clk.unlock()
+	if onStop != nil {
+		onStop()
+	}
+	return fired
+}
+
+// Register a new subscriber channel of t.
+func (clk *Scheduler) subscribe(t *Timer) <-chan time.Time {
+	c := make(chan time.Time, 1)
+	clk.lock()
+	defer clk.unlock()
+	t.subs = append(t.subs, c)
+	return c
+}
+
+// Unregister a subscriber channel of t.  It returns false if c is not subscribed.
+func (clk *Scheduler) unsubscribe(t *Timer, c <-chan time.Time) bool {
+	clk.lock()
+	defer clk.unlock()
+	for i, sc := range t.subs {
+		if sc == c {
+			t.subs = append(t.subs[:i], t.subs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Register f to be called when t is stopped.
+func (clk *Scheduler) setOnStop(t *Timer, f func()) {
+	clk.lock()
+	defer clk.unlock()
+	t.onStop = f
+}
+
+// If stopped is true, unregister the OnStop hook of t and return it so that the caller can call it
+// after releasing the mutex.  Unregistering it under the mutex guarantees that it runs at most once
+// even if several goroutines stop t concurrently.
+func (t *Timer) takeOnStopLocked(stopped bool) func() {
+	if !stopped {
+		return nil
+	}
+	f := t.onStop
+	t.onStop = nil
+	return f
+}
+
+// Fire t immediately if it is pending, exactly as the timer routine would on expiry.  Removing t and
+// delivering the notification happen in one critical section, so t cannot also fire naturally.
+// It returns false if t was not pending.
+func (clk *Scheduler) fireTimer(t *Timer) bool {
+	clk.lock()
+	defer clk.unlock()
+	now := clk.now()
+	switch t.state {
+	case Paused:
+		t.when = now
+		t.nominal = now
+		clk.addTimerLocked(t)
+	case Scheduled:
+	default:
+		return false
+	}
+	clk.expireLocked(t, now)
+	clk.removedLocked()
+	return true
+}
+
+// Same as delTimer, but the caller must hold the mutex.  A paused timer counts as being in the
+// heap; deleting it cancels the pause.
+// Do not need to update the timer routine: if it wakes up early, no big deal.
+func (clk *Scheduler) delTimerLocked(t *Timer) bool {
+	if !clk.dequeueLocked(t) {
+		return false
+	}
+	clk.stats.stopped.Add(1)
+	t.traceLocked("stop")
+	t.recordLocked(EventStop, time.Time{})
+	clk.observeLocked(t, observeStop, time.Time{}, time.Time{})
+	return true
+}
+
+// Same as delTimerLocked, but t is not counted or traced as stopped, because it is about to be
+// re-armed (or was never really started).
+func (clk *Scheduler) dequeueLocked(t *Timer) bool {
+	t.endArmingLocked()
+	switch t.state {
+	case Scheduled:
+		clk.timers.Remove(t)
+		clk.removedLocked()
+	case Paused:
+	default:
+		return false
+	}
+	t.leaveGroupLocked()
+	t.state = Stopped
+	clk.checkLocked("remove")
+	return true
+}
+
+// Invalidate what is left of the current arming of t: its generation, its context, and its replay.
+// The caller must hold the mutex.
+func (t *Timer) endArmingLocked() {
+	t.gen++
+	if t.cancel != nil {
+		// Whether or not t has fired, the arming is over.
+		t.cancel()
+	}
+	t.stopReplayLocked()
+}
+
+// Insert timer t into the heap and wake up the timer routine if necessary.  The caller must hold
+// the mutex, and t must not already be in the heap.
+func (clk *Scheduler) addTimerLocked(t *Timer) {
+	if clk.shutdown {
+		panic("timer: Scheduler is shut down")
+	}
+	clk.startRoutineLocked()
+	clk.insertLocked(t)
+}
+
+// Start the timer routine if it is not running.  The caller must hold the mutex.
+func (clk *Scheduler) startRoutineLocked() {
+	if clk.started || clk.manualDispatch {
+		return
+	}
+	// The mutex makes this safe when many goroutines start their first timer at once.
+	clk.started = true
+	clk.quit, clk.exited = make(chan struct{}), make(chan struct{})
+	go clk.timerRoutine(clk.quit, clk.exited)
+}
+
+// Add t, which must not be in the heap, to the heap.  The caller must hold the mutex.
+func (clk *Scheduler) insertLocked(t *Timer) {
+	clk.queued++
+	t.order = clk.queued
+	clk.refLocked(t)
+	clk.timers.Insert(t)
+	clk.stats.addedLocked(clk.timers.Len())
+	clk.countChangedLocked()
+	t.state = Scheduled
+	if t.group != nil {
+		t.group.members[t] = struct{}{}
+	}
+	clk.checkLocked("insert")
+	if t.slack > 0 {
+		clk.slack = true
+	}
+	// Reschedule if the timer routine would otherwise sleep past the end of the slack of t.
+	clk.wakeFor(t.when.Add(t.slack))
+}
+
+// Change the deadline of t, which must be in the heap, fixing up its heap position in place.  The
+// caller must hold the mutex.
+func (clk *Scheduler) moveTimerLocked(t *Timer, when time.Time) {
+	t.when = when
+	clk.queued++
+	t.order = clk.queued
+	clk.refLocked(t)
+	clk.timers.Fix(t)
+	clk.checkLocked("move")
+	// The timer routine only needs to be woken if it would sleep past the new deadline (plus the
+	// slack).  If the deadline of the head moved later, the routine wakes up early, which is
+	// harmless.
+	clk.wakeFor(when.Add(t.slack))
+}
+
+// Wake the timer routine up if it is sleeping (or about to sleep) until after when, and lower the
+// deadline it sleeps until to when: it is due to examine the heap by then, so timers that expire
+// later need not wake it again, and a routine that does not do so is stalled (see Healthy).  While
+// the routine is awake, it examines the heap again before sleeping, so not waking it is fine, too.
+// The mutex is needed only for a definite answer.
+func (clk *Scheduler) wakeFor(when time.Time) {
+	w := int64(when.Sub(clk.base))
+	for {
+		until := clk.sleepUntil.Load()
+		if w >= until {
+			return
+		}
+		if clk.sleepUntil.CompareAndSwap(until, w) {
+			break
+		}
+	}
+	clk.wake()
+}
+
+// Lock the mutex of clk.
+func (clk *Scheduler) lock() {
+	clk.mutex.Lock()
+}
+
+// Unlock the mutex of clk, and then pass the observations made while it was held to the observers.
+// If replays of tickers were stopped, this first waits for their goroutines to exit.
+func (clk *Scheduler) unlock() {
+	for clk.stoppingReplays > 0 {
+		clk.funcDone.Wait()
+	}
+	if clk.deadlineC != nil {
+		clk.checkDeadlineLocked()
+	}
+	if len(clk.observed) == 0 {
+		clk.mutex.Unlock()
+		return
+	}
+	obs := clk.observed
+	clk.observed = nil
+	clk.mutex.Unlock()
+	clk.notify(obs)
+}
+
+// Ask the timer routine to re-examine the head of the heap.
+func (clk *Scheduler) wake() {
+	// Do not block if there is already a pending reschedule request.
+	select {
+	case clk.rescheduleC <- struct{}{}:
+	default:
+	}
+}
+
+// Reset the timer to the new deadline.
+// This clears the channel.
+func (clk *Scheduler) resetTimer(t *Timer, when time.Time) bool {
+	clk.lock()
+	defer clk.unlock()
+	return clk.resetTimerLocked(t, when)
+}
+
+// Same as resetTimer, but also return the time that was left until the old deadline, captured in
+// the same critical section.  It is negative for a timer that has already fired, and 0 for a
+// stopped timer.
+func (clk *Scheduler) resetTimerReturning(t *Timer, when time.Time) (time.Duration, bool) {
+	clk.lock()
+	defer clk.unlock()
+	var remaining time.Duration
+	switch t.state {
+	case Scheduled, Fired:
+		remaining = t.when.Sub(clk.now())
+	case Paused:
+		remaining = t.left
+	}
+	return remaining, clk.resetTimerLocked(t, when)
+}
+
+// Same as resetTimer, but the caller must hold the mutex.
+func (clk *Scheduler) resetTimerLocked(t *Timer, when time.Time) bool {
+	// The channel must be drained while the mutex is locked, otherwise a notification generated by a
+	// concurrent t.Reset(0) call might be erroneously consumed.
+	t.drainLocked()
+	return clk.rearmTimerLocked(t, when)
+}
+
+// Reset the timer to the new deadline without clearing the channel.
+func (clk *Scheduler) rearmTimer(t *Timer, when time.Time) bool {
+	clk.lock()
+	defer clk.unlock()
+	return clk.rearmTimerLocked(t, when)
+}
+
+// Same as rearmTimer, but the caller must hold the mutex.
+func (clk *Scheduler) rearmTimerLocked(t *Timer, when time.Time) bool {
+	if t.modern {
+		// With modern semantics, only the new expiry may ever be delivered.
+		t.drainLocked()
+	}
+	if t.state == Scheduled && !clk.shutdown {
+		// Fix up the heap position of t in place rather than removing and reinserting it, which
+		// takes twice the work.  t stays in its group.
+		t.endArmingLocked()
+		t.armLocked(when)
+		clk.moveTimerLocked(t, t.when)
+		clk.countResetLocked(t)
+		return true
+	}
+	b := clk.dequeueLocked(t)
+	t.armLocked(when)
+	clk.addTimerLocked(t)
+	if b {
+		clk.countResetLocked(t)
+	} else {
+		t.traceLocked("arm")
+		t.recordLocked(EventAdd, time.Time{})
+		clk.observeLocked(t, observeSchedule, t.when, time.Time{})
+	}
+	return b
+}
+
+// Count and log the reset of t, which was pending or paused, to its new deadline, and tell the
+// observers about the deadline.  Every path that resets a timer (or a ticker) calls it.  The caller
+// must hold the mutex.
+func (clk *Scheduler) countResetLocked(t *Timer) {
+	clk.stats.resets.Add(1)
+	t.traceLocked("reset")
+	t.recordLocked(EventReset, time.Time{})
+	clk.observeLocked(t, observeSchedule, t.when, time.Time{})
+}
+
+// Set the deadline of t, which is not in the heap, to when, as perturbed by its options, and reset
+// the state of the previous arming.  The caller must hold the mutex.
+func (t *Timer) armLocked(when time.Time) {
+	if t.cancel != nil {
+		t.ctx, t.cancel = context.WithCancel(context.Background())
+	}
+	if t.ended {
+		// Restarting a ticker that has ended gives it a new Done channel.
+		t.ended = false
+		t.done = nil
+	}
+	t.nominal = when
+	now := t.clk.now()
+	when = t.boundedLocked(t.jitteredLocked(when, when.Sub(now)))
+	t.when = when
+	t.n = 0
+	t.dur = when.Sub(now)
+	if t.dur < 0 {
+		t.dur = 0
+	}
+}
+
+// Move the deadline of t by d, fixing up its heap position in place.
+// It returns false if t is neither in the heap nor paused.
+func (clk *Scheduler) extendTimer(t *Timer, d time.Duration) bool {
+	clk.lock()
+	defer clk.unlock()
+	switch t.state {
+	case Paused:
+		t.left += d
+		t.dur += d
+		return true
+	case Scheduled:
+	default:
+		return false
+	}
+	t.nominal = t.nominal.Add(d)
+	clk.moveTimerLocked(t, t.when.Add(d))
+	t.dur += d
+	return true
+}
+
+// Remove t from the heap, remembering how much time was left so that resumeTimer can re-add it
+// later.  It returns false if t was not in the heap.
+func (clk *Scheduler) pauseTimer(t *Timer) (time.Duration, bool) {
+	clk.lock()
+	defer clk.unlock()
+	// The remaining time must be computed in the same critical section that removes the timer,
+	// otherwise the timer routine could fire it in between.
+	if t.state != Scheduled {
+		return 0, false
+	}
+	clk.timers.Remove(t)
+	clk.removedLocked()
+	t.left = t.when.Sub(clk.now())
+	t.state = Paused
+	clk.checkLocked("pause")
+	return t.left, true
+}
+
+// Re-add a timer removed by pauseTimer with the time that was left when it was paused.
+// It returns false if t is not paused.
+func (clk *Scheduler) resumeTimer(t *Timer) bool {
+	clk.lock()
+	defer clk.unlock()
+	if t.state != Paused {
+		return false
+	}
+	t.when = clk.now().Add(t.left)
+	t.nominal = t.when
+	clk.addTimerLocked(t)
+	return true
+}
+
+// Replace the callback of t and return the previous one.
+func (clk *Scheduler) swapFunc(t *Timer, f func()) func() {
+	clk.lock()
+	defer clk.unlock()
+	if t.f == nil {
+		panic("timer: SwapFunc called on Timer not created by AfterFunc")
+	}
+	old := t.f
+	t.f = f
+	return old
+}
+
+// Return the time left until t expires, or 0 if t is neither in the heap nor paused.
+func (clk *Scheduler) remaining(t *Timer) time.Duration {
+	clk.lock()
+	defer clk.unlock()
+	switch t.state {
+	case Scheduled:
+		return t.when.Sub(clk.now())
+	case Paused:
+		return t.left
+	}
+	return 0
+}
+
+// Return the deadline of t and true if t is in the heap, or the zero time and false otherwise.
+func (clk *Scheduler) deadline(t *Timer) (time.Time, bool) {
+	clk.lock()
+	defer clk.unlock()
+	if t.state != Scheduled {
+		return time.Time{}, false
+	}
+	return t.when, true
+}
+
+// Return how much of the duration t was started with has elapsed, that duration, and the state of
+// t.  Time spent paused does not count.
+func (clk *Scheduler) elapsed(t *Timer) (elapsed, total time.Duration, state TimerState) {
+	clk.lock()
+	defer clk.unlock()
+	state = t.state
+	switch state {
+	case Scheduled:
+		elapsed = t.dur - t.when.Sub(clk.now())
+	case Paused:
+		elapsed = t.dur - t.left
+	case Fired:
+		elapsed = t.dur
+	default:
+		return 0, t.dur, state
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	if elapsed > t.dur {
+		elapsed = t.dur
+	}
+	return elapsed, t.dur, state
+}
+
+// Report whether t has fired since it was last started.
+func (clk *Scheduler) expired(t *Timer) bool {
+	clk.lock()
+	defer clk.unlock()
+	return t.state == Fired
+}
+
+// Set the name of t.
+func (clk *Scheduler) setName(t *Timer, name string) {
+	clk.lock()
+	defer clk.unlock()
+	t.name = name
+	t.labels = nil
+}
+
+// Return the name of t.
+func (clk *Scheduler) timerName(t *Timer) string {
+	clk.lock()
+	defer clk.unlock()
+	return t.name
+}
+
+// Format t for debugging, taking a consistent snapshot of its fields.
+func (clk *Scheduler) formatTimer(t *Timer) string {
+	clk.lock()
+	name, state, when, i := t.name, t.state, t.when, t.i
+	clk.unlock()
+	if state != Scheduled {
+		i = -1
+	}
+	return fmt.Sprintf("kairos.Timer{name=%q, state=%v, when=%v, index=%d}",
+		name, state, when.Format(time.RFC3339Nano), i)
+}
+
+// Return the state of t.
+func (clk *Scheduler) timerState(t *Timer) TimerState {
+	clk.lock()
+	defer clk.unlock()
+	return t.state
+}
+
+// Process the expiry of t, which must be in the heap: deliver the notification, then either re-arm
+// t in place if it repeats or remove it from the heap.  The caller must hold the mutex.
+//
+// Removing (or re-arming) the timer and delivering the notification happen in the same critical
+// section, so delTimer can never observe a timer whose notification has been delivered but that is
+// still pending for the same arming (or vice versa).  This is what makes Stop's return value
+// trustworthy: true means that the notification was prevented, false means it was already delivered.
+func (clk *Scheduler) expireLocked(t *Timer, now time.Time) {
+	if t.pastEndLocked() {
+		// This is not a tick but the end set by WithDeadline.
+		clk.timers.Remove(t)
+		t.leaveGroupLocked()
+		t.state = Fired
+		t.endLocked()
+		return
+	}
+	clk.latency.Load().record(now.Sub(t.when))
+	if late := int64(now.Sub(t.when)); late > clk.maxLate.Load() {
+		// Only written with the mutex held, so there is no lost update.
+		clk.maxLate.Store(late)
+	}
+	t.fireLocked(now)
+	if t.nextInterval != nil && (t.limit <= 0 || t.n < t.limit) {
+		// The next interval is computed outside the mutex, so the timer stays out of the heap
+		// until then.
+		clk.timers.Remove(t)
+		t.leaveGroupLocked()
+		t.state = Fired
+		gen, prev := t.gen, t.period
+		t.inflight++
+		clk.callbacks++
+		clk.goLocked(t, nil, func(e expiry) { clk.rearmDynamic(t, gen, prev, e) }, expiry{scheduled: t.when, actual: now, n: t.n})
+		return
+	}
+	if t.calendar != nil && (t.limit <= 0 || t.n < t.limit) {
+		// Likewise for the next occurrence of the time of day of a calendar timer.
+		clk.timers.Remove(t)
+		t.leaveGroupLocked()
+		t.state = Fired
+		gen := t.gen
+		t.inflight++
+		clk.callbacks++
+		clk.goLocked(t, nil, func(e expiry) { clk.rearmCalendar(t, gen, e) }, expiry{scheduled: t.nominal, actual: now, n: t.n})
+		return
+	}
+	if t.period > 0 && (t.limit <= 0 || t.n < t.limit) {
+		t.nominal = t.nextDeadlineLocked(now)
+		next := t.boundedLocked(t.jitteredLocked(t.nominal, t.period))
+		t.dur = next.Sub(now)
+		t.when = next
+		clk.queued++
+		t.order = clk.queued
+		clk.refLocked(t)
+		clk.timers.Fix(t)
+		clk.observeLocked(t, observeSchedule, t.when, time.Time{})
+		return
+	}
+	clk.timers.Remove(t)
+	t.leaveGroupLocked()
+	t.state = Fired
+	t.endLocked()
+}
+
+// Return the nominal (not jittered) deadline following the one of the repeating timer t, which is
+// expiring at now.  The caller must hold the mutex.
+func (t *Timer) nextDeadlineLocked(now time.Time) time.Time {
+	if t.aligned {
+		// Realign to the wall clock on every tick, so that a step of the system clock neither
+		// causes a burst of ticks nor a long pause.
+		return nextMultiple(t.anchor, t.period, now)
+	}
+	if !t.drift {
+		return now.Add(t.period)
+	}
+	// Schedule relative to the deadline rather than to now, so that lateness does not accumulate.
+	// If whole periods have been missed, skip them.
+	missed := now.Sub(t.nominal) / t.period
+	if missed > 0 {
+		t.skipped += uint64(missed)
+	}
+	return t.nominal.Add((missed + 1) * t.period)
+}
+
+// Return the deadline when perturbed by the jitter configured with WithJitter, which is a fraction
+// of the duration d.  The caller must hold the mutex.
+func (t *Timer) jitteredLocked(when time.Time, d time.Duration) time.Time {
+	if t.jitter == 0 || d <= 0 {
+		return when
+	}
+	rnd := t.rnd
+	if rnd == nil {
+		rnd = rand.Float64
+	}
+	return when.Add(time.Duration((2*rnd() - 1) * t.jitter * float64(d)))
+}
+
+// Information about a single fire of a timer, passed to callbacks that want more than func().
+type expiry struct {
+	scheduled time.Time       // The deadline the timer was armed with.
+	actual    time.Time       // The time at which the expiry was processed.
+	n         int             // Number of fires since the timer was last started, including this one.
+	ctx       context.Context // The context of the arming, for timers created by NewTimerFuncCtx.
+}
+
+// Deliver the expiry notification of t.  The caller must hold the clock mutex.
+func (t *Timer) fireLocked(now time.Time) {
+	t.n++
+	t.clk.stats.fired.Add(1)
+	if traceOn() {
+		t.logLocked("fire", now)
+	}
+	t.recordLocked(EventFire, now)
+	t.clk.observeLocked(t, observeFire, t.when, now)
+	switch {
+	case t.f != nil || t.call != nil:
+		// Run the callback in another goroutine (its own, or a pool worker) so that a slow callback
+		// does not delay the remaining timers in the heap.
+		t.clk.dispatchLocked(t, t.f, t.call, expiry{scheduled: t.when, actual: now, n: t.n, ctx: t.ctx})
+	case t.send != nil:
+		t.send(expiry{scheduled: t.when, actual: now, n: t.n})
+	default:
+		select {
+		case t.c <- now:
+		default:
+		}
+	}
+	// Subscribers must not delay the timer routine either, so slow ones miss the value.
+	for _, c := range t.subs {
+		select {
+		case c <- now:
+		default:
+		}
+	}
+}
+
+// Call f, or call(e) if f is nil, keeping track of it so that StopWait can wait for it to return.
+// A panic is recovered and passed to the panic handler once the call is no longer tracked.
+func (t *Timer) runFunc(f func(), call func(expiry), e expiry) {
+	t.runFuncIn(0, f, call, e)
+}
+
+// Same as runFunc, in the goroutine whose ID is id, for the goroutines that run many calls and look
+// up their ID only once.  If id is 0, the ID is looked up only if StopWait has been called on t,
+// since that is slow.
+func (t *Timer) runFuncIn(id uint64, f func(), call func(expiry), e expiry) {
+	clk := t.clk
+	clk.lock()
+	if id == 0 && t.waited {
+		id = goid()
+	}
+	t.running = append(t.running, id)
+	var labels context.Context
+	if !clk.manualDispatch {
+		// The goroutines that dispatch a Scheduler with manual dispatch keep their own labels,
+		// which cannot be read back to be restored.
+		labels = t.labelsLocked()
+	}
+	if traceOn() {
+		// Ends before the panic, if any, is handled.
+		defer trace.StartRegion(context.Background(), "kairos.callback").End()
+		t.logLocked("run", clk.now())
+	}
+	clk.unlock()
+	if labels != nil {
+		pprof.SetGoroutineLabels(labels)
+	}
+	defer func() {
+		r := recover()
+		clk.lock()
+		for i, rid := range t.running {
+			if rid == id {
+				t.running = append(t.running[:i], t.running[i+1:]...)
+				break
+			}
+		}
+		t.inflight--
+		clk.callbacks--
+		clk.funcDone.Broadcast()
+		if clk.callbacks == 0 && clk.virtual != nil {
+			// The timer routine waits for the callbacks to return before moving the time on.
+			clk.wake()
+		}
+		clk.unlock()
+		if r != nil {
+			clk.handlePanic(t, r)
+		}
+	}()
+	if f != nil {
+		f()
+		return
+	}
+	call(e)
+}
+
+// Discard all pending expiry notifications of t, if any.  The caller must hold the clock mutex.
+func (t *Timer) drainLocked() {
+	t.stopReplayLocked()
+	for _, c := range t.subs {
+		select {
+		case <-c:
+		default:
+		}
+	}
+	if t.drain != nil {
+		t.drain()
+		return
+	}
+	// The channel may have been given a capacity greater than 1 by WithChannelBuffer.
+	for {
+		select {
+		case <-t.C:
+		default:
+			return
+		}
+	}
+}
+
+func (clk *Scheduler) timerRoutine(quit <-chan struct{}, exited chan<- struct{}) {
+	defer close(exited)
+	pprof.SetGoroutineLabels(schedulerLabels)
+	// A stale deadline would keep the next routine from being woken up, and look like a stall.
+	defer clk.sleepUntil.Store(math.MaxInt64)
+	defer registerRoutine(clk)()
+	var now time.Time
+	var gen uint64 // The value of nowGen when now was read.
+
+	sleepTimer := time.NewTimer(0)
+	<-sleepTimer.C
+	sleepTimerActive := false
+	defer sleepTimer.Stop()
+
+	// Whether the routine has raised the resolution of the system timer for WithHighResolution.
+	raised := false
+	defer func() {
+		if raised {
+			restoreTimerResolution()
+		}
+	}()
+
+	// With WithTimerFD, the routine sleeps on a timerfd instead of sleepTimer, if it can.
+	var tfd *timerFD
+	var tfdC <-chan struct{}
+	if clk.useTimerFD {
+		if tfd = newTimerFD(); tfd != nil {
+			tfdC = tfd.c
+			clk.lock()
+			clk.tfd = tfd
+			clk.unlock()
+			defer func() {
+				clk.lock()
+				clk.tfd = nil
+				clk.unlock()
+				tfd.close()
+			}()
+		}
+	}
+
+Loop:
+	for {
+		select {
+		case <-quit:
+			return
+
+		case <-sleepTimer.C:
+
+		case <-tfdC:
+
+		case <-clk.rescheduleC:
+			clk.stats.wakeups.Add(1)
+			// If not yet received a value from sleepTimer.C, the timer must be
+			// stopped and—if Stop reports that the timer expired before being
+			// stopped—the channel explicitly drained.
+			if !sleepTimer.Stop() && sleepTimerActive {
+				<-sleepTimer.C
+			}
+		}
+		sleepTimerActive = false
+
+	Reschedule:
+		gen = nowGen.Load()
+		now = clk.now()
+		clk.beat.Store(int64(now.Sub(clk.base)))
+
+		clk.lock()
+		if clk.frozen.Load() {
+			// Thaw wakes the routine up.
+			clk.sleepUntil.Store(math.MaxInt64)
+			clk.unlock()
+			continue Loop
+		}
+		if clk.refClocks != 0 {
+			clk.checkRefLocked(now)
+		}
+		if clk.virtual != nil && clk.callbacks > 0 {
+			// The last callback to return wakes the routine up.
+			clk.unlock()
+			continue Loop
+		}
+		if expired := clk.expireDueLocked(now); expired > 0 {
+			clk.unlock()
+			// Processing the expired timers took time, so check again with a fresh reading of
+			// the clock before sleeping.  If the batch was cut short, let Shutdown and the timers
+			// being added in the meantime have the mutex first.
+			if expired == clk.batch {
+				select {
+				case <-quit:
+					return
+				case <-clk.rescheduleC:
+				default:
+				}
+				runtime.Gosched()
+			}
+			goto Reschedule
+		}
+
+		// Publish the deadline to sleep until, so that only timers that expire before it need to
+		// wake the routine up.
+		var delta time.Duration
+		until := int64(math.MaxInt64)
+		next, pending := clk.timers.Next()
+		if pending && clk.slack {
+			// Sleep until the end of the earliest slack, and fire every timer due by then at once.
+			if latest := clk.timers.Latest(); latest.After(next) {
+				next = latest
+			}
+		}
+		if pending {
+			delta = clk.sleepFor(next, now)
+			if clk.refClocks != 0 && delta > refCheckInterval {
+				delta = refCheckInterval
+			}
+			until = int64(next.Sub(clk.base))
+		}
+		clk.sleepUntil.Store(until)
+		// The deadline and delta must not have been computed with a function that SetNowFunc has
+		// replaced since: the timers started by the new one may be due before the deadline without
+		// waking the routine.
+		clk.unlock()
+		if nowGen.Load() != gen {
+			goto Reschedule
+		}
+		if !pending || clk.manualTime {
+			if tfd != nil {
+				tfd.stop()
+			}
+			if raised {
+				restoreTimerResolution()
+				raised = false
+			}
+			continue Loop
+		}
+		if clk.virtual != nil {
+			// Jump to the deadline instead of sleeping until it, unless the timer was stopped in the
+			// meantime, but still check for quit.
+			clk.lock()
+			if next, pending := clk.timers.Next(); pending {
+				clk.virtual.advance(next)
+			}
+			clk.unlock()
+			clk.wake()
+			continue Loop
+		}
+		if clk.highRes {
+			if !raised {
+				raiseTimerResolution()
+				raised = true
+			}
+			// Poll the clock for the last stretch, which a sleep would likely overshoot.
+			if delta <= spinThreshold {
+				if !clk.spinUntil(next, quit) {
+					return
+				}
+				goto Reschedule
+			}
+			delta -= spinThreshold
+		}
+		if tfd != nil {
+			tfd.sleep(delta)
+			continue Loop
+		}
+		sleepTimer.Reset(delta)
+		sleepTimerActive = true
+	}
+}
+
+// Process the expiry of the timers in the heap that are due at now, in deadline order, up to the
+// batch size of clk, and return their number.  Doing them all in a single critical section keeps a
+// burst of expiries (after a GC pause or a suspend, say) from taking the mutex and reading the
+// clock once per timer.  The caller must hold the mutex.
+func (clk *Scheduler) expireDueLocked(now time.Time) int {
+	n := 0
+	for n < clk.batch {
+		t := clk.timers.Due(now)
+		if t == nil {
+			break
+		}
+		clk.expireLocked(t, now)
+		clk.checkLocked("expiry")
+		n++
+		if clk.virtual != nil && clk.callbacks > 0 {
+			// The callbacks of a Scheduler on virtual time run one at a time.
+			break
+		}
+	}
+	if n > 0 {
+		clk.removedLocked()
+	}
+	return n
+}
+
+// Shutdown stops the default Scheduler, for a clean process exit or to
+// satisfy goroutine leak detectors in tests. New timers are refused from then
+// on: creating or restarting a timer panics. Shutdown waits for the pending
+// timers to fire until ctx is done; then it stops the ones that are left
+// (which includes tickers, unless they end on their own) and returns
+// ctx.Err(). Pass a context that is already canceled to stop them all right
+// away. Finally, it terminates the goroutine of the Scheduler, and the
+// callback workers (see SetCallbackWorkers) once they have run the callbacks
+// queued for them. Calling Shutdown again has no effect until Start is called.
+func Shutdown(ctx context.Context) error {
+	return defaultScheduler.Shutdown(ctx)
+}
+
+// Start brings the default Scheduler back after Shutdown has returned, so
+// that timers can be created again. It does nothing if the Scheduler is
+// running.
+func Start() {
+	defaultScheduler.Start()
+}
@@ -0,0 +1,199 @@
+package kairos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+func TestSchedulersIndependent(t *testing.T) {
+	s1, s2 := NewScheduler(), NewScheduler()
+	var long []*Timer
+	for i := 0; i < 100; i++ {
+		long = append(long, s1.NewTimer(time.Hour))
+	}
+	if n1, n2 := s1.PendingCount(), s2.PendingCount(); n1 != 100 || n2 != 0 {
+		t.Errorf("pending counts are %v and %v, want 100 and 0", n1, n2)
+	}
+	const d = 20 * time.Millisecond
+	var gr errgroup.Group
+	for _, s := range []*Scheduler{s1, s2} {
+		s := s
+		for i := 0; i < 10; i++ {
+			gr.Go(func() error {
+				start := time.Now()
+				<-s.NewTimer(d).C
+				if got := time.Since(start); got < d || got >= d+margin {
+					t.Errorf("timer fired after %v, want %v", got, d)
+				}
+				return nil
+			})
+		}
+	}
+	gr.Wait()
+	for _, timer := range long {
+		timer.Stop()
+	}
+	if n := s1.PendingCount(); n != 0 {
+		t.Errorf("%v timers of s1 pending after Stop", n)
+	}
+}
+
+func TestSchedulerShutdown(t *testing.T) {
+	s := NewScheduler()
+	fired := s.NewTimer(10 * time.Millisecond)
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() = %v, want nil", err)
+	}
+	select {
+	case <-fired.C:
+	default:
+		t.Errorf("Shutdown did not wait for the pending timer")
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != "timer: Scheduler is shut down" {
+				t.Errorf("invalid panic %v", r)
+			}
+		}()
+		s.NewTimer(0)
+	}()
+
+	s.Start()
+	tk := s.NewTicker(time.Millisecond)
+	stoppedByShutdown := make(chan struct{})
+	long := s.NewTimer(time.Hour)
+	long.OnStop(func() { close(stoppedByShutdown) })
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	<-stoppedByShutdown
+	<-tk.Done()
+	if n := s.PendingCount(); n != 0 {
+		t.Errorf("%v timers pending after Shutdown", n)
+	}
+
+	s.Start()
+	<-s.NewTimer(0).C
+}
+
+func TestSchedulerLazyStart(t *testing.T) {
+	s := NewScheduler()
+	// Stopped timers do not start the goroutine.
+	s.NewStoppedTimer()
+	s.mutex.Lock()
+	started := s.started
+	s.mutex.Unlock()
+	if started {
+		t.Errorf("timer routine started without a timer")
+	}
+	// Many first timers at once must start a single routine.
+	var gr errgroup.Group
+	for i := 0; i < 100; i++ {
+		gr.Go(func() error {
+			<-s.NewTimer(time.Millisecond).C
+			return nil
+		})
+	}
+	gr.Wait()
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() = %v", err)
+	}
+	if err := NewScheduler().Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() of an unused Scheduler = %v", err)
+	}
+}
+
+func TestBurstExpiry(t *testing.T) {
+	s := NewScheduler()
+	const n = 10000
+	deadline := time.Now().Add(20 * time.Millisecond)
+	timers := make([]*Timer, n)
+	for i := range timers {
+		// Deadlines in reverse order of creation; all are due when the first one is.
+		timers[i] = s.NewTimerAt(deadline.Add(time.Duration(n-i) * time.Nanosecond))
+	}
+	// Creating the timers may take longer than 20ms when the race detector is on.
+	due := time.Now()
+	if due.Before(deadline) {
+		due = deadline
+	}
+	for _, timer := range timers {
+		select {
+		case <-timer.C:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timer of a burst did not fire")
+		}
+	}
+	if got := time.Since(due); got >= margin {
+		t.Errorf("burst of %v timers took %v to fire", n, got)
+	}
+	if n := s.PendingCount(); n != 0 {
+		t.Errorf("%v timers pending after the burst", n)
+	}
+}
+
+// Measure how long the timer routine takes to process 10k timers that are due at the same time.
+func BenchmarkBurstExpiry(b *testing.B) {
+	const n = 10000
+	s := NewScheduler()
+	timers := make([]*Timer, n)
+	for i := range timers {
+		timers[i] = s.NewStoppedTimer()
+	}
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		deadline := time.Now().Add(5 * time.Millisecond)
+		for _, timer := range timers {
+			timer.ResetAt(deadline)
+		}
+		time.Sleep(time.Until(deadline))
+		b.StartTimer()
+		for _, timer := range timers {
+			<-timer.C
+		}
+	}
+}
+
+func TestExpiryBatch(t *testing.T) {
+	s := NewScheduler(WithExpiryBatch(10))
+	const n = 1000
+	var order []int // Appended to by the send hooks, with the mutex held.
+	fired := make(chan struct{}, n)
+	deadline := time.Now().Add(20 * time.Millisecond)
+	for i := 0; i < n; i++ {
+		i := i
+		timer := s.NewStoppedTimer()
+		timer.send = func(expiry) {
+			order = append(order, i)
+			fired <- struct{}{}
+		}
+		// Created in reverse deadline order.
+		timer.ResetAt(deadline.Add(time.Duration(n-i) * time.Nanosecond))
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case <-fired:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("only %v of %v timers fired", i, n)
+		}
+	}
+	for k, i := range order {
+		if i != n-1-k {
+			t.Fatalf("timer %v fired in position %v, want %v", i, k, n-1-i)
+		}
+	}
+}
+
+func TestExpiryBatchPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "timer: non-positive batch size for WithExpiryBatch" {
+			t.Errorf("invalid panic %v", r)
+		}
+	}()
+	WithExpiryBatch(0)
+}
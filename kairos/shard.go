@@ -0,0 +1,52 @@
+package kairos
+
+import (
+	"context"
+	"sync"
+	"unsafe"
+)
+
+// WithShards splits the Scheduler into n shards, each with its own heap, lock, and goroutine, so
+// that timers of different shards do not contend for a lock.  Each timer is assigned to a shard
+// when it is created; the timers of a TimeoutGroup all belong to the same shard.  The ordering
+// guarantees between timers with the same deadline hold only within a shard.  WithShards panics if
+// n < 1; one shard is the same as no sharding.
+func WithShards(n int) SchedulerOption {
+	if n < 1 {
+		panic("timer: non-positive shard count for WithShards")
+	}
+	return func(clk *Scheduler) {
+		clk.nshards = n
+	}
+}
+
+// Return the shard of clk that owns the object at p: clk itself if clk is not sharded.
+func (clk *Scheduler) shard(p unsafe.Pointer) *Scheduler {
+	if clk.shards == nil {
+		return clk
+	}
+	// Fibonacci hashing spreads the aligned addresses of consecutive allocations evenly.
+	h := uint64(uintptr(p)) * 0x9e3779b97f4a7c15
+	return clk.shards[(h>>32)%uint64(len(clk.shards))]
+}
+
+// Shut down all the shards of clk concurrently, so that none of them keeps accepting timers while
+// another one waits for its pending timers, and return the first error.
+func (clk *Scheduler) shutdownShards(ctx context.Context) error {
+	errs := make([]error, len(clk.shards))
+	var wg sync.WaitGroup
+	for i, s := range clk.shards {
+		wg.Add(1)
+		go func(i int, s *Scheduler) {
+			defer wg.Done()
+			errs[i] = s.Shutdown(ctx)
+		}(i, s)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package kairos
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestShards(t *testing.T) {
+	s := NewScheduler(WithShards(4))
+	var long []*Timer
+	for i := 0; i < 100; i++ {
+		long = append(long, s.NewTimer(time.Hour))
+	}
+	used := 0
+	for _, shard := range s.shards {
+		if n := shard.PendingCount(); n > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		t.Errorf("100 timers use %v of 4 shards", used)
+	}
+	if n := s.PendingCount(); n != 100 {
+		t.Errorf("PendingCount() = %v, want 100", n)
+	}
+	start := time.Now()
+	tm := s.NewTimer(20 * time.Millisecond)
+	<-tm.C
+	if got := time.Since(start); got >= 20*time.Millisecond+margin {
+		t.Errorf("timer fired after %v, want 20ms", got)
+	}
+	g := s.NewTimeoutGroup()
+	for i := 0; i < 10; i++ {
+		g.NewTimer(time.Hour)
+	}
+	g.StopAll()
+	if n := g.Len(); n != 0 {
+		t.Errorf("%v timers left in the group after StopAll", n)
+	}
+
+	stopped := make(chan struct{})
+	long[0].OnStop(func() { close(stopped) })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	<-stopped
+	if n := s.PendingCount(); n != 0 {
+		t.Errorf("%v timers pending after Shutdown", n)
+	}
+	s.Start()
+	<-s.NewTimer(0).C
+}
+
+func TestWithShardsPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "timer: non-positive shard count for WithShards" {
+			t.Errorf("invalid panic %v", r)
+		}
+	}()
+	WithShards(0)
+}
+
+// Compare a single lock with one shard per P when many goroutines start and stop timers.
+func BenchmarkShardedTimers(b *testing.B) {
+	for _, n := range []int{1, runtime.GOMAXPROCS(0)} {
+		b.Run(fmt.Sprintf("shards %v", n), func(b *testing.B) {
+			s := NewScheduler(WithShards(n))
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					timer := s.NewTimer(time.Hour)
+					timer.Reset(time.Minute)
+					timer.Stop()
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package kairos
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// An Observer that records the time at which each timer fired.
+type expiryRecorder struct {
+	mu     sync.Mutex
+	actual map[*Timer]time.Time
+}
+
+func (r *expiryRecorder) OnSchedule(t *Timer, when time.Time) {}
+
+func (r *expiryRecorder) OnFire(t *Timer, scheduled, actual time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.actual == nil {
+		r.actual = make(map[*Timer]time.Time)
+	}
+	r.actual[t] = actual
+}
+
+func (r *expiryRecorder) OnStop(t *Timer) {}
+
+func TestSlackCoalesces(t *testing.T) {
+	for _, opts := range [][]SchedulerOption{nil, {WithTimingWheel(time.Millisecond, 64)}} {
+		clk := NewScheduler(opts...)
+		var r expiryRecorder
+		clk.RegisterObserver(&r)
+		a := clk.NewTimer(margin, WithSlack(margin))
+		b := clk.NewTimer(margin*3/2, WithSlack(margin))
+		c := clk.NewTimer(margin * 9 / 5)
+		later := clk.NewTimer(3*margin, WithSlack(margin))
+		for _, timer := range []*Timer{a, b, c, later} {
+			<-timer.C
+		}
+		time.Sleep(margin / 10)
+		r.mu.Lock()
+		// a and b can wait for c, which cannot wait, but later is not due yet then.
+		if !r.actual[a].Equal(r.actual[c]) || !r.actual[b].Equal(r.actual[c]) {
+			t.Errorf("timers fired at %v, %v, and %v instead of all at once", r.actual[a], r.actual[b], r.actual[c])
+		}
+		if r.actual[later].Equal(r.actual[c]) {
+			t.Error("timer fired along with one that was due before its deadline")
+		}
+		r.mu.Unlock()
+	}
+}
+
+func TestSlackBounds(t *testing.T) {
+	clk := NewScheduler()
+	const n = 200
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		d := time.Duration(rand.Int63n(int64(2 * margin)))
+		var slack time.Duration
+		if i%4 != 0 {
+			slack = time.Duration(rand.Int63n(int64(margin)))
+		}
+		deadline := time.Now().Add(d)
+		clk.AfterFunc(d, func() {
+			defer wg.Done()
+			now := time.Now()
+			if now.Before(deadline) {
+				t.Errorf("timer fired %v before its deadline", deadline.Sub(now))
+			}
+			if late := now.Sub(deadline); late > slack+margin/10 {
+				t.Errorf("timer with a slack of %v fired %v after its deadline", slack, late)
+			}
+		}, WithSlack(slack))
+	}
+	wg.Wait()
+}
+
+func TestSlackPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("WithSlack did not panic for a negative slack")
+		}
+	}()
+	WithSlack(-time.Second)
+}
+
+func TestSlackDoesNotWake(t *testing.T) {
+	clk := NewScheduler()
+	head := clk.NewTimer(2 * margin)
+	defer head.Stop()
+	// Let the routine go to sleep until the deadline of head.
+	time.Sleep(margin / 10)
+	before := clk.Stats().TotalWakeups
+	for i := 0; i < 10; i++ {
+		// Due before head, but head is due before their slack ends.
+		defer clk.NewTimer(margin, WithSlack(2*margin)).Stop()
+	}
+	time.Sleep(margin / 10)
+	if n := clk.Stats().TotalWakeups - before; n != 0 {
+		t.Errorf("arming timers that can wait for the next wakeup woke the routine %d times", n)
+	}
+}
@@ -0,0 +1,57 @@
+package kairos
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep pauses the current goroutine for at least the duration d, using a timer of clk.
+func (clk *Scheduler) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	<-clk.NewTimer(d).C
+}
+
+// SleepContext pauses the current goroutine for at least the duration d or until ctx is done,
+// whichever happens first, using a timer of clk.
+func (clk *Scheduler) SleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	t := clk.NewTimer(d)
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		// Remove the timer from the heap so that canceled sleeps do not pile up.
+		t.Stop()
+		return ctx.Err()
+	}
+}
+
+// Sleep pauses the current goroutine for at least the duration d, like
+// time.Sleep, but using a kairos timer so that no runtime timer is created.
+// A negative or zero duration causes Sleep to return immediately.
+func Sleep(d time.Duration) {
+	defaultScheduler.Sleep(d)
+}
+
+// SleepContext is like Sleep, but returns ctx.Err() as soon as ctx is done.
+// The timer is removed from the heap in that case. It returns nil if the full
+// duration elapsed. A negative or zero duration causes SleepContext to return
+// nil immediately.
+func SleepContext(ctx context.Context, d time.Duration) error {
+	return defaultScheduler.SleepContext(ctx, d)
+}
+
+// WaitContext is the same as SleepContext, under the name that retry loops
+// usually give it: it returns nil if the full duration d elapsed and
+// ctx.Err() if ctx was done first, without leaving the timer in the heap. If
+// d <= 0, it returns nil immediately without touching the heap.
+func WaitContext(ctx context.Context, d time.Duration) error {
+	return defaultScheduler.SleepContext(ctx, d)
+}
@@ -0,0 +1,75 @@
+package kairos
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSleep(t *testing.T) {
+	for _, d := range []time.Duration{-time.Second, 0, 100 * time.Millisecond} {
+		t.Run(d.String(), func(t *testing.T) {
+			want := d
+			if want < 0 {
+				want = 0
+			}
+			start := time.Now()
+			Sleep(d)
+			if got := time.Since(start); got < want || got >= want+margin {
+				t.Errorf("slept for wrong duration; got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSleepContext(t *testing.T) {
+	const want = 100 * time.Millisecond
+	start := time.Now()
+	if err := SleepContext(context.Background(), want); err != nil {
+		t.Errorf("SleepContext returned error %v", err)
+	}
+	if got := time.Since(start); got < want || got >= want+margin {
+		t.Errorf("slept for wrong duration; got %v, want %v", got, want)
+	}
+}
+
+func TestSleepContextCanceled(t *testing.T) {
+	clk := NewScheduler()
+	const cancelAfter = 100 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), cancelAfter)
+	t.Cleanup(cancel)
+	start := time.Now()
+	if err := clk.SleepContext(ctx, time.Hour); err != context.DeadlineExceeded {
+		t.Errorf("wrong error; got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := time.Since(start); got < cancelAfter || got >= cancelAfter+margin {
+		t.Errorf("SleepContext did not return promptly; got %v, want %v", got, cancelAfter)
+	}
+	clk.mutex.Lock()
+	n := clk.timers.Len()
+	clk.mutex.Unlock()
+	if n != 0 {
+		t.Errorf("canceled sleep left %v timers in the heap", n)
+	}
+	if err := clk.SleepContext(ctx, 0); err != nil {
+		t.Errorf("zero duration: got error %v, want nil", err)
+	}
+}
+
+func TestWaitContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := WaitContext(ctx, 10*time.Millisecond); err != nil {
+		t.Errorf("WaitContext returned %v, want nil", err)
+	}
+	cancel()
+	before := PendingCount()
+	if err := WaitContext(ctx, time.Hour); err != context.Canceled {
+		t.Errorf("WaitContext returned %v, want %v", err, context.Canceled)
+	}
+	if err := WaitContext(ctx, 0); err != nil {
+		t.Errorf("zero duration: got error %v, want nil", err)
+	}
+	if after := PendingCount(); after > before {
+		t.Errorf("WaitContext left %v timers in the heap", after-before)
+	}
+}
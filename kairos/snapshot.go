@@ -0,0 +1,68 @@
+package kairos
+
+import (
+	"slices"
+	"time"
+)
+
+// A TimerRecord describes a pending timer created with WithPayload, as returned by Snapshot, so
+// that Restore can re-create it.  It has no serialization of its own; its fields are for the
+// caller to encode.
+type TimerRecord struct {
+	Name     string    // Set by SetName or WithName.
+	Payload  []byte    // Set by WithPayload.
+	Deadline time.Time // When the timer is due, by the wall clock.
+}
+
+// Snapshot returns a record of every pending timer of clk (and of its shards) that was created with
+// WithPayload, ordered by deadline.  Paused timers, which have no deadline, are left out.
+func (clk *Scheduler) Snapshot() []TimerRecord {
+	var records []TimerRecord
+	for _, s := range append([]*Scheduler{clk}, clk.shards...) {
+		s.lock()
+		s.timers.Walk(func(t *Timer) {
+			if t.payload != nil {
+				// The monotonic reading means nothing after a restart.
+				records = append(records, TimerRecord{Name: t.name, Payload: slices.Clone(t.payload), Deadline: t.when.Round(0)})
+			}
+		})
+		s.unlock()
+	}
+	slices.SortStableFunc(records, func(a, b TimerRecord) int { return a.Deadline.Compare(b.Deadline) })
+	return records
+}
+
+// Restore arms a timer of clk for each of records, due at its recorded deadline, that calls
+// handler with the name, payload, and deadline of the record in its own goroutine; if the deadline
+// has passed, the timer fires right away.  The timers have the name and payload of their records,
+// so they are in the next Snapshot too if they are still pending.  Restore returns the timers, in
+// the order of records.
+func (clk *Scheduler) Restore(records []TimerRecord, handler func(name string, payload []byte, scheduled time.Time)) []*Timer {
+	timers := make([]*Timer, len(records))
+	for i, r := range records {
+		r := r
+		t := clk.NewStoppedFunc(func() { handler(r.Name, r.Payload, r.Deadline) }, WithName(r.Name), WithPayload(r.Payload))
+		t.clk.resetTimer(t, r.Deadline)
+		timers[i] = t
+	}
+	return timers
+}
+
+// Snapshot returns a record of every pending timer of the default Scheduler
+// that was created with WithPayload: its name, payload, and deadline, by the
+// wall clock. Encode the records on shutdown, and pass them to Restore on
+// startup, to have the jobs fire at the times they were scheduled for even
+// across a restart. Timers without a payload are left out, since their
+// callbacks and channels cannot be saved.
+func Snapshot() []TimerRecord {
+	return defaultScheduler.Snapshot()
+}
+
+// Restore re-creates the timers described by records on the default Scheduler:
+// each one calls handler with the name, payload, and deadline of its record, at
+// that deadline, or right away if it has already passed (during the downtime,
+// say). It returns the timers, which can be stopped like those returned by
+// AfterFunc.
+func Restore(records []TimerRecord, handler func(name string, payload []byte, scheduled time.Time)) []*Timer {
+	return defaultScheduler.Restore(records, handler)
+}
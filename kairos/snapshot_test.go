@@ -0,0 +1,69 @@
+package kairos
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	for _, opts := range [][]SchedulerOption{nil, {WithShards(2)}} {
+		clk := NewScheduler(opts...)
+		payload := []byte("job 1")
+		first := clk.AfterFunc(2*time.Hour, func() {}, WithName("first"), WithPayload(payload))
+		payload[0] = 'J'
+		clk.AfterFunc(time.Hour, func() {}, WithName("second"), WithPayload(nil))
+		clk.AfterFunc(time.Hour, func() {}, WithName("raw callback"))
+		clk.NewTimer(time.Hour, WithPayload([]byte("channel")))
+		paused := clk.NewTimer(time.Hour, WithPayload([]byte("paused")))
+		paused.Pause()
+
+		records := clk.Snapshot()
+		if len(records) != 3 {
+			t.Fatalf("Snapshot returned %d records, want 3: %v", len(records), records)
+		}
+		deadline, _ := first.Deadline()
+		if r := records[2]; r.Name != "first" || string(r.Payload) != "job 1" || !r.Deadline.Equal(deadline) {
+			t.Errorf("wrong record of the first timer: %+v", r)
+		}
+		if r := records[0]; r.Name != "second" || r.Payload == nil || len(r.Payload) != 0 {
+			t.Errorf("wrong record of the second timer: %+v", r)
+		}
+		if r := records[1]; string(r.Payload) != "channel" {
+			t.Errorf("wrong record of the channel timer: %+v", r)
+		}
+		clk.StopAll()
+
+		// Restore on another Scheduler, after the deadline of the first record has passed.
+		records[0].Deadline = time.Now().Add(-time.Minute)
+		restored := NewScheduler(opts...)
+		type call struct {
+			name      string
+			payload   []byte
+			scheduled time.Time
+		}
+		calls := make(chan call, len(records))
+		timers := restored.Restore(records, func(name string, payload []byte, scheduled time.Time) {
+			calls <- call{name, payload, scheduled}
+		})
+		select {
+		case c := <-calls:
+			if c.name != "second" || !c.scheduled.Equal(records[0].Deadline) {
+				t.Errorf("wrong call for an overdue record: %+v", c)
+			}
+		case <-time.After(margin):
+			t.Error("the timer of an overdue record did not fire right away")
+		}
+		if len(timers) != 3 || timers[2].Name() != "first" {
+			t.Fatalf("wrong timers from Restore: %v", timers)
+		}
+		if got, _ := timers[2].Deadline(); !got.Equal(deadline) {
+			t.Errorf("restored timer due at %v, want %v", got, deadline)
+		}
+		again := restored.Snapshot()
+		if len(again) != 2 || again[1].Name != "first" || !bytes.Equal(again[1].Payload, records[2].Payload) {
+			t.Errorf("wrong snapshot of the restored timers: %v", again)
+		}
+		restored.StopAll()
+	}
+}
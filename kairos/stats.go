@@ -0,0 +1,93 @@
+package kairos
+
+import "sync/atomic"
+
+// SchedulerStats are counters of the activity of a Scheduler, as returned by Stats.
+type SchedulerStats struct {
+	PendingTimers       int // Timers in the heap, that is, armed and not yet expired.
+	MaxPendingHighWater int // The largest value of PendingTimers since the counters were reset.
+
+	TotalFired   uint64 // Expiries delivered (every tick of a ticker counts).
+	TotalStopped uint64 // Pending or paused timers stopped before firing.
+	TotalResets  uint64 // Pending or paused timers re-armed with a new deadline.
+	TotalWakeups uint64 // Times the timer routine was woken up early to look at a new deadline.
+}
+
+// The counters of a Scheduler.  They are written with the mutex held (except wakeups, which only
+// the timer routine writes), and read without it.
+type counters struct {
+	pending   atomic.Int64
+	highWater atomic.Int64
+	fired     atomic.Uint64
+	stopped   atomic.Uint64
+	resets    atomic.Uint64
+	wakeups   atomic.Uint64
+}
+
+// Record that the heap has grown to n timers.  The caller must hold the mutex.
+func (s *counters) addedLocked(n int) {
+	s.pending.Store(int64(n))
+	if int64(n) > s.highWater.Load() {
+		s.highWater.Store(int64(n))
+	}
+}
+
+// Stats returns the counters of clk, or the sums of those of its shards if it is sharded (so
+// MaxPendingHighWater is the sum of the high-water marks of the shards, which may not have been
+// reached at once).  The counters are read one by one, without the mutex, so they may be a little
+// inconsistent with each other while timers are being started and stopped.
+func (clk *Scheduler) Stats() SchedulerStats {
+	var st SchedulerStats
+	for _, s := range clk.shards {
+		ss := s.Stats()
+		st.PendingTimers += ss.PendingTimers
+		st.MaxPendingHighWater += ss.MaxPendingHighWater
+		st.TotalFired += ss.TotalFired
+		st.TotalStopped += ss.TotalStopped
+		st.TotalResets += ss.TotalResets
+		st.TotalWakeups += ss.TotalWakeups
+	}
+	if len(clk.shards) > 0 {
+		return st
+	}
+	return SchedulerStats{
+		PendingTimers:       int(clk.stats.pending.Load()),
+		MaxPendingHighWater: int(clk.stats.highWater.Load()),
+		TotalFired:          clk.stats.fired.Load(),
+		TotalStopped:        clk.stats.stopped.Load(),
+		TotalResets:         clk.stats.resets.Load(),
+		TotalWakeups:        clk.stats.wakeups.Load(),
+	}
+}
+
+// ResetStats zeroes the counters and the lateness histogram of clk (and of its shards), and lowers
+// the high-water mark to the current number of pending timers.
+func (clk *Scheduler) ResetStats() {
+	for _, s := range clk.shards {
+		s.ResetStats()
+	}
+	clk.lock()
+	defer clk.unlock()
+	clk.stats.highWater.Store(clk.stats.pending.Load())
+	clk.stats.fired.Store(0)
+	clk.stats.stopped.Store(0)
+	clk.stats.resets.Store(0)
+	clk.stats.wakeups.Store(0)
+	clk.latency.Store(newLatencyHistogram(clk.latency.Load().bounds))
+}
+
+// Stats returns the counters of the default Scheduler: the number of timers
+// pending now and at most, and the numbers of expiries, stops, and resets
+// since the program started (or since ResetStats). Reading them does not take
+// the mutex, so Stats can be called often, to feed a monitoring system for
+// example.
+func Stats() SchedulerStats {
+	return defaultScheduler.Stats()
+}
+
+// ResetStats zeroes the counters and the lateness histogram (see
+// LatencyHistogram) of the default Scheduler, between the cases
+// of a test for example.
+func ResetStats() {
+	defaultScheduler.ResetStats()
+}
@@ -0,0 +1,68 @@
+package kairos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	for _, shards := range []int{1, 4} {
+		s := NewScheduler(WithShards(shards))
+		var timers []*Timer
+		for i := 0; i < 10; i++ {
+			timers = append(timers, s.NewTimer(time.Hour))
+		}
+		for _, timer := range timers[:3] {
+			timer.Stop()
+		}
+		for _, timer := range timers[3:5] {
+			timer.Reset(time.Hour)
+		}
+		timers[5].Stop()
+		timers[5].Reset(time.Hour) // Not a reset, since the timer was stopped.
+		for _, timer := range timers[6:8] {
+			timer.Reset(0)
+			<-timer.C
+		}
+		tk := s.NewTicker(time.Millisecond)
+		for i := 0; i < 3; i++ {
+			<-tk.C
+		}
+		tk.Reset(time.Hour) // A reset of a ticker counts, too.
+		tk.Stop()
+		want := SchedulerStats{
+			PendingTimers:       5,
+			MaxPendingHighWater: 10,
+			TotalFired:          5,
+			TotalStopped:        5,
+			TotalResets:         5,
+		}
+		st := s.Stats()
+		// The ticker may have ticked once more before it was stopped.
+		if st.TotalFired == want.TotalFired+1 {
+			st.TotalFired--
+		}
+		// How many times the routines were woken up depends on their timing.
+		if st.TotalWakeups == 0 {
+			t.Errorf("%v shards: no wakeups counted", shards)
+		}
+		st.TotalWakeups = 0
+		if shards > 1 {
+			// The sum of the high-water marks of the shards may be more.
+			if st.MaxPendingHighWater < want.MaxPendingHighWater {
+				t.Errorf("high-water mark of %v with %v pending timers", st.MaxPendingHighWater, want.MaxPendingHighWater)
+			}
+			st.MaxPendingHighWater = want.MaxPendingHighWater
+		}
+		if st != want {
+			t.Errorf("%v shards: Stats() = %+v, want %+v", shards, st, want)
+		}
+		s.ResetStats()
+		if st, want := s.Stats(), (SchedulerStats{PendingTimers: 5, MaxPendingHighWater: 5}); st != want {
+			t.Errorf("%v shards: Stats() after ResetStats = %+v, want %+v", shards, st, want)
+		}
+		for _, timer := range timers {
+			timer.Stop()
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package kairos
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Added to the readings of the clock that includes suspend, by tests that simulate a suspend.
+var bootSkew atomic.Int64
+
+// Return the reading of the clock that includes the time the system was suspended at now: the
+// boot time on Linux, where the monotonic clock stops during a suspend, and the wall clock
+// elsewhere (or if the boot time cannot be read).
+func bootTime(now time.Time) time.Duration {
+	if d, ok := readBootTime(); ok {
+		return d + time.Duration(bootSkew.Load())
+	}
+	return wallTime(now) + time.Duration(bootSkew.Load())
+}
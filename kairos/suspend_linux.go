@@ -0,0 +1,20 @@
+package kairos
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// CLOCK_BOOTTIME, which the syscall package does not define.
+const clockBoottime = 7
+
+// Return the time since boot, including the time the system was suspended, and true, or false if
+// the kernel does not support CLOCK_BOOTTIME.
+func readBootTime() (time.Duration, bool) {
+	var ts syscall.Timespec
+	if _, _, e := syscall.Syscall(syscall.SYS_CLOCK_GETTIME, clockBoottime, uintptr(unsafe.Pointer(&ts)), 0); e != 0 {
+		return 0, false
+	}
+	return time.Duration(ts.Nano()), true
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package kairos
+
+import "time"
+
+// The boot time is only read on Linux; the wall clock stands in for it elsewhere.
+func readBootTime() (time.Duration, bool) {
+	return 0, false
+}
@@ -0,0 +1,55 @@
+package kairos
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestIncludeSuspend(t *testing.T) {
+	defer bootSkew.Store(0)
+	clk := NewScheduler()
+	suspend := clk.NewTimer(10*time.Minute, WithIncludeSuspend())
+	monotonic := clk.NewTimer(10 * time.Minute)
+	defer monotonic.Stop()
+	wall := clk.NewTimer(10*time.Minute, WithWallClock())
+	defer wall.Stop()
+
+	// Take the timers into the heap before the clock moves.
+	clk.PendingCount()
+	// Simulate a suspend of an hour.
+	bootSkew.Store(int64(time.Hour))
+	start := time.Now()
+	select {
+	case <-suspend.C:
+	case <-time.After(refCheckInterval + margin):
+		t.Fatal("timer that includes suspend did not fire after the resume")
+	}
+	if got := time.Since(start); got >= refCheckInterval+margin {
+		t.Errorf("timer that includes suspend fired %v after the resume", got)
+	}
+	if left := monotonic.Remaining(); left < 10*time.Minute-2*refCheckInterval {
+		t.Errorf("monotonic timer has %v left after the resume, want about 10m", left)
+	}
+	if left := wall.Remaining(); runtime.GOOS == "linux" && left < 10*time.Minute-2*refCheckInterval {
+		t.Errorf("wall-clock timer has %v left after the resume, want about 10m", left)
+	}
+}
+
+func TestBootTime(t *testing.T) {
+	d, ok := readBootTime()
+	if runtime.GOOS != "linux" {
+		if ok {
+			t.Error("readBootTime succeeded on another system than Linux")
+		}
+		return
+	}
+	if !ok {
+		t.Skip("CLOCK_BOOTTIME is not supported")
+	}
+	time.Sleep(margin)
+	later, _ := readBootTime()
+	if got := later - d; got < margin || got >= 2*margin {
+		t.Errorf("boot time moved by %v during a sleep of %v", got, margin)
+	}
+}
@@ -0,0 +1,43 @@
+//go:build go1.25
+
+package kairos
+
+import (
+	"context"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+func TestSynctest(t *testing.T) {
+	realStart := time.Now()
+	synctest.Test(t, func(t *testing.T) {
+		clk := NewScheduler()
+		// The callback workers and the watchdog must let the bubble end, too.
+		clk.SetCallbackWorkers(2)
+		clk.SetStallHandler(time.Second, func(lag time.Duration) { t.Errorf("stall of %v", lag) })
+		defer clk.Shutdown(context.Background())
+		start := time.Now()
+		timer := clk.NewTimer(time.Hour)
+		<-timer.C
+		if d := time.Since(start); d != time.Hour {
+			t.Errorf("timer fired after %v of fake time, want 1h", d)
+		}
+		fired := false
+		clk.AfterFunc(time.Minute, func() { fired = true })
+		time.Sleep(time.Minute)
+		synctest.Wait()
+		if !fired {
+			t.Error("AfterFunc callback did not run by its deadline")
+		}
+		clk.Sleep(time.Hour)
+		ticker := clk.NewTicker(time.Second)
+		for i := 0; i < 3; i++ {
+			<-ticker.C
+		}
+		ticker.Stop()
+	})
+	if d := time.Since(realStart); d >= margin {
+		t.Errorf("test took %v of real time", d)
+	}
+}
@@ -0,0 +1,66 @@
+package kairos
+
+import (
+	"context"
+	"time"
+)
+
+// Create and start a channel timer that fires every d until it is stopped.
+func (clk *Scheduler) newTickTimer(d time.Duration, opts []Option) *Timer {
+	t := clk.NewStoppedTimer(opts...)
+	t.period = d
+	now := clk.now()
+	t.anchor = now
+	t.initTicks()
+	t.clk.resetTimer(t, t.firstTick(now.Add(d), now))
+	return t
+}
+
+// TickStop delivers the current time on the returned channel every d, like time.Tick, and also
+// returns a function that stops the ticks.
+func (clk *Scheduler) TickStop(d time.Duration) (<-chan time.Time, func()) {
+	if d <= 0 {
+		return nil, func() {}
+	}
+	t := clk.newTickTimer(d, nil)
+	return t.C, func() { t.Stop() }
+}
+
+// TickContext delivers the current time on the returned channel every d until ctx is done.
+func (clk *Scheduler) TickContext(ctx context.Context, d time.Duration) <-chan time.Time {
+	if d <= 0 {
+		return nil
+	}
+	t := clk.newTickTimer(d, nil)
+	if done := ctx.Done(); done != nil {
+		go func() {
+			<-done
+			t.StopAndDrain()
+		}()
+	}
+	return t.C
+}
+
+// Tick delivers the current time on the returned channel every d, like
+// time.Tick. Ticks are never queued: if the receiver is slow, ticks are dropped.
+// The ticks can never be stopped, so use TickStop or TickContext (or a Ticker)
+// unless the channel is needed for the lifetime of the program. Tick returns
+// nil if d <= 0.
+func Tick(d time.Duration) <-chan time.Time {
+	c, _ := defaultScheduler.TickStop(d)
+	return c
+}
+
+// TickStop is like Tick, but also returns a function that stops the ticks and
+// releases the underlying timer. After the stop function returns, no more ticks
+// are sent. TickStop returns a nil channel if d <= 0.
+func TickStop(d time.Duration) (<-chan time.Time, func()) {
+	return defaultScheduler.TickStop(d)
+}
+
+// TickContext is like Tick, but the ticks stop (and the underlying timer is
+// released) once ctx is done. A tick that has not been received when ctx is
+// done is discarded. TickContext returns nil if d <= 0.
+func TickContext(ctx context.Context, d time.Duration) <-chan time.Time {
+	return defaultScheduler.TickContext(ctx, d)
+}
@@ -0,0 +1,65 @@
+package kairos
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTick(t *testing.T) {
+	const d = 20 * time.Millisecond
+	c := Tick(d)
+	start := time.Now()
+	for i := 1; i <= 5; i++ {
+		select {
+		case <-c:
+			if got, want := time.Since(start), time.Duration(i)*d; got < want {
+				t.Errorf("tick %v early; got duration %v, want >= %v", i, got, want)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("tick %v not delivered", i)
+		}
+	}
+	if Tick(0) != nil {
+		t.Errorf("Tick(0) returned non-nil channel")
+	}
+}
+
+func TestTickStop(t *testing.T) {
+	const d = 10 * time.Millisecond
+	c, stop := TickStop(d)
+	<-c
+	// Missed ticks are dropped rather than queued.
+	time.Sleep(10 * d)
+	if got := len(c); got > 1 {
+		t.Errorf("ticks queued up; got %v, want <= 1", got)
+	}
+	stop()
+	for len(c) > 0 {
+		<-c
+	}
+	select {
+	case <-c:
+		t.Errorf("tick delivered after stop")
+	case <-time.After(5 * d):
+	}
+}
+
+func TestTickContext(t *testing.T) {
+	const d = 10 * time.Millisecond
+	clk := NewScheduler()
+	ctx, cancel := context.WithCancel(context.Background())
+	c := clk.TickContext(ctx, d)
+	<-c
+	cancel()
+	time.Sleep(5 * d)
+	if got := len(c); got != 0 {
+		t.Errorf("tick delivered after cancel")
+	}
+	clk.mutex.Lock()
+	n := clk.timers.Len()
+	clk.mutex.Unlock()
+	if n != 0 {
+		t.Errorf("canceled ticks left %v timers in the heap", n)
+	}
+}
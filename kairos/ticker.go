@@ -0,0 +1,555 @@
+package kairos
+
+import (
+	"fmt"
+	"time"
+)
+
+// A Ticker holds a channel that delivers “ticks” of a clock at intervals.
+// A Ticker must be created with NewTicker.
+type Ticker struct {
+	C <-chan time.Time // The channel on which the ticks are delivered.
+	// The channel on which the ticks are delivered instead of C (which is nil) if the ticker was
+	// created with WithTickEvents, WithMissedPolicy(CoalesceMissed) or
+	// WithMissedPolicy(ReplayMissed).
+	Ticks <-chan TickEvent
+
+	t *Timer
+}
+
+// A TickEvent is delivered on the Ticks channel of a Ticker.
+type TickEvent struct {
+	Time time.Time // When the tick fired, or approximately when it was due if it was replayed.
+	// The number of ticks that were missed since the last delivered one because the receiver was too
+	// slow, if they were coalesced into this one.
+	Missed int
+	// The number of the tick.  It is incremented for every tick that is due, whether it is delivered
+	// or not, so a gap means that ticks were dropped.  It is not reset by Ticker.Reset.
+	Seq uint64
+}
+
+// A MissedPolicy selects what a Ticker does about ticks that its receiver was too slow to take.
+type MissedPolicy int
+
+const (
+	// SkipMissed drops the missed ticks, like time.Ticker.  This is the default.
+	SkipMissed MissedPolicy = iota
+	// CoalesceMissed drops the missed ticks, but counts them in the Missed field of the next tick
+	// that is delivered.
+	CoalesceMissed
+	// ReplayMissed delivers the missed ticks back to back as soon as the receiver takes them.
+	ReplayMissed
+)
+
+func (p MissedPolicy) String() string {
+	switch p {
+	case SkipMissed:
+		return "SkipMissed"
+	case CoalesceMissed:
+		return "CoalesceMissed"
+	case ReplayMissed:
+		return "ReplayMissed"
+	}
+	return fmt.Sprintf("MissedPolicy(%d)", int(p))
+}
+
+// TickerFunc creates a new [Ticker] that calls f in its own goroutine every d.
+func (clk *Scheduler) TickerFunc(d time.Duration, f func(time.Time), opts ...Option) *Ticker {
+	if d <= 0 {
+		panic("timer: non-positive interval for TickerFunc")
+	}
+	t := clk.newTimer(nil, nil, opts)
+	t.onTick = f
+	t.send = t.sendTickFunc
+	t.period = d
+	now := clk.now()
+	t.anchor = now
+	t.clk.resetTimer(t, t.firstTick(now.Add(d), now))
+	return &Ticker{t: t}
+}
+
+// Return when, or the end set by WithDeadline if the nominal deadline is not before it, in which
+// case the nominal deadline becomes the end, too.  The caller must hold the mutex.
+func (t *Timer) boundedLocked(when time.Time) time.Time {
+	if t.pastEndLocked() {
+		t.nominal = t.end
+		return t.end
+	}
+	return when
+}
+
+// Report whether the nominal deadline of t is at or after the end set by WithDeadline.  The caller
+// must hold the mutex.
+func (t *Timer) pastEndLocked() bool {
+	return !t.end.IsZero() && !t.nominal.Before(t.end)
+}
+
+// Mark the ticker t as ended and close its Done channel.  The caller must hold the mutex.
+func (t *Timer) endLocked() {
+	if t.ended {
+		return
+	}
+	t.ended = true
+	if t.done != nil {
+		close(t.done)
+	}
+}
+
+// Return the Done channel of the ticker t.
+func (clk *Scheduler) tickerDone(t *Timer) <-chan struct{} {
+	clk.lock()
+	defer clk.unlock()
+	// The channel is created on demand, so that tickers that nobody asks have none.
+	if t.done == nil {
+		t.done = make(chan struct{})
+		if t.ended {
+			close(t.done)
+		}
+	}
+	return t.done
+}
+
+// NewDynamicTicker creates a new [Ticker] whose intervals are computed by next.
+func (clk *Scheduler) NewDynamicTicker(next func(prev time.Duration, n int) time.Duration, opts ...Option) *Ticker {
+	t := clk.NewStoppedTimer(opts...)
+	t.nextInterval = next
+	t.initTicks()
+	d := next(0, 0)
+	if d <= 0 {
+		t.ended = true
+		return newTicker(t)
+	}
+	t.period = d
+	now := clk.now()
+	t.anchor = now
+	t.clk.resetTimer(t, t.firstTick(now.Add(d), now))
+	return newTicker(t)
+}
+
+// Re-arm the dynamic ticker t, which fired as described by e, with the interval returned by its
+// nextInterval function, unless t was stopped or re-armed since (that is, its generation is no
+// longer gen).  prev is the interval that ended with the fire.
+func (clk *Scheduler) rearmDynamic(t *Timer, gen uint64, prev time.Duration, e expiry) {
+	d := t.nextInterval(prev, e.n)
+	clk.lock()
+	defer clk.unlock()
+	if t.gen != gen {
+		return
+	}
+	if d <= 0 || clk.shutdown {
+		t.endLocked()
+		return
+	}
+	t.period = d
+	// The interval starts at the fire, not when next returned.
+	t.nominal = e.actual.Add(d)
+	t.when = t.boundedLocked(t.jitteredLocked(t.nominal, d))
+	t.dur = t.when.Sub(e.actual)
+	clk.addTimerLocked(t)
+}
+
+// Return the current interval of the ticker t.
+func (clk *Scheduler) interval(t *Timer) time.Duration {
+	clk.lock()
+	defer clk.unlock()
+	return t.period
+}
+
+// Return a Ticker for the ticker timer t.
+func newTicker(t *Timer) *Ticker {
+	if t.ticks != nil {
+		return &Ticker{Ticks: t.ticks, t: t}
+	}
+	return &Ticker{C: t.C, t: t}
+}
+
+// Make the ticker timer t deliver on its Ticks channel if it was created with WithTickEvents or a
+// MissedPolicy other than SkipMissed.  It must be called before t is started.
+func (t *Timer) initTicks() {
+	if t.policy == SkipMissed && !t.events {
+		return
+	}
+	// Honor WithChannelBuffer.
+	t.ticks = make(chan TickEvent, cap(t.c))
+	t.send = t.sendTick
+	t.drain = t.drainTicks
+}
+
+// Called by the timer routine with the clock mutex held.
+func (t *Timer) sendTick(e expiry) {
+	// Normally this is 1, but it is more if the timer routine itself could not keep up or drift
+	// correction skipped some periods.
+	n := 1
+	if !t.lastTick.IsZero() {
+		n = int((e.actual.Sub(t.lastTick) + t.period/2) / t.period)
+		if n < 1 {
+			n = 1
+		}
+	}
+	t.lastTick = e.actual
+	if t.zeroTick {
+		// The immediate first tick of a new ticker is number 0.
+		t.zeroTick = false
+		t.seq--
+	}
+	t.seq += uint64(n)
+	t.backlog += n
+	switch t.policy {
+	case SkipMissed:
+		select {
+		case t.ticks <- TickEvent{Time: e.actual, Seq: t.seq}:
+		default:
+		}
+		t.backlog = 0
+		return
+	case CoalesceMissed:
+		select {
+		case t.ticks <- TickEvent{Time: e.actual, Missed: t.backlog - 1, Seq: t.seq}:
+			t.backlog = 0
+		default:
+		}
+		return
+	}
+	if !t.replaying {
+		t.startReplayLocked()
+	}
+}
+
+// Start a goroutine to deliver the backlog of the ReplayMissed ticker t.  The caller must hold the
+// mutex, and no such goroutine may be running.
+func (t *Timer) startReplayLocked() {
+	t.replaying = true
+	t.stopReplay = make(chan struct{})
+	go t.replay(t.stopReplay)
+}
+
+// Deliver the backlog of the ReplayMissed ticker t until it is empty or stop is closed.  Sends
+// block, which is why this does not run in the timer routine.
+func (t *Timer) replay(stop chan struct{}) {
+	clk := t.clk
+	clk.lock()
+	for t.backlog > 0 && t.stopReplay == stop {
+		t.backlog--
+		// Ticks still in the backlog were due before this one.
+		tick := TickEvent{
+			Time: t.lastTick.Add(-time.Duration(t.backlog) * t.period),
+			Seq:  t.seq - uint64(t.backlog),
+		}
+		// Not clk.unlock, which waits for the stopped replays to exit: this one may be stopped
+		// while it waits, and would then wait for itself.
+		clk.mutex.Unlock()
+		sent := false
+		select {
+		case t.ticks <- tick:
+			sent = true
+		case <-stop:
+		}
+		clk.lock()
+		if sent && t.stopReplay != stop {
+			t.retractTickLocked(tick.Seq)
+		}
+	}
+	if t.stopReplay == stop {
+		t.stopReplay = nil
+	} else {
+		clk.stoppingReplays--
+	}
+	t.replaying = false
+	if t.backlog > 0 {
+		// The ticker was re-armed and fell behind again while this goroutine was being stopped.
+		t.startReplayLocked()
+	}
+	clk.funcDone.Broadcast()
+	clk.mutex.Unlock()
+}
+
+// Take the tick numbered seq back from the channel of the ReplayMissed ticker t, unless it has been
+// received already, leaving the ticks sent before it in order.  Only the replay goroutine sends to
+// that channel, so the mutex keeps any other tick from being sent meanwhile.  The caller must hold
+// the mutex.
+func (t *Timer) retractTickLocked(seq uint64) {
+	var kept []TickEvent
+	for n := len(t.ticks); n > 0; n-- {
+		select {
+		case tick := <-t.ticks:
+			if tick.Seq != seq {
+				kept = append(kept, tick)
+			}
+		default:
+			// The receiver took the others.
+		}
+	}
+	for _, tick := range kept {
+		t.ticks <- tick
+	}
+}
+
+// Called by the timer routine with the clock mutex held.  The backlog counts the calls of onTick
+// that are due but have not started yet.
+func (t *Timer) sendTickFunc(e expiry) {
+	t.lastTick = e.actual
+	if !t.tickBusy {
+		t.tickBusy = true
+		t.backlog = 1
+		t.inflight++
+		t.clk.callbacks++
+		t.clk.goLocked(t, nil, t.runTicks, e)
+		return
+	}
+	switch t.policy {
+	case CoalesceMissed:
+		t.backlog = 1
+	case ReplayMissed:
+		t.backlog++
+	}
+}
+
+// Call onTick until the backlog is empty.  Because Stop and Reset clear the backlog, no call starts
+// after they return.
+func (t *Timer) runTicks(expiry) {
+	clk := t.clk
+	clk.lock()
+	for t.backlog > 0 {
+		t.backlog--
+		// Calls still in the backlog were due before this one.
+		now := t.lastTick.Add(-time.Duration(t.backlog) * t.period)
+		clk.unlock()
+		t.onTick(now)
+		clk.lock()
+	}
+	t.tickBusy = false
+	clk.unlock()
+}
+
+// Stop delivering the backlog of the ticker t and forget it.  A replay goroutine that is in the
+// middle of a send takes the tick back once it has the mutex again, and unlock waits for it to do
+// so, so that no stale tick is left for the receiver after the call that stopped the replay
+// returns.  The caller must hold the mutex, which this does not release.
+func (t *Timer) stopReplayLocked() {
+	if t.stopReplay != nil {
+		close(t.stopReplay)
+		t.stopReplay = nil
+		t.clk.stoppingReplays++
+	}
+	t.backlog = 0
+	t.lastTick = time.Time{}
+}
+
+// Called with the clock mutex held.
+func (t *Timer) drainTicks() {
+	for {
+		select {
+		case <-t.ticks:
+		default:
+			return
+		}
+	}
+}
+
+// NewTicker creates a new [Ticker] that ticks every d.
+func (clk *Scheduler) NewTicker(d time.Duration, opts ...Option) *Ticker {
+	if d <= 0 {
+		panic("timer: non-positive interval for NewTicker")
+	}
+	t := clk.newTickTimer(d, opts)
+	return newTicker(t)
+}
+
+// NewAlignedTicker creates a new [Ticker] that ticks at every multiple of interval since the Unix
+// epoch, shifted by offset.
+func (clk *Scheduler) NewAlignedTicker(interval, offset time.Duration, opts ...Option) *Ticker {
+	if interval <= 0 {
+		panic("timer: non-positive interval for NewAlignedTicker")
+	}
+	t := clk.NewStoppedTimer(opts...)
+	t.period = interval
+	t.aligned = true
+	// time.Unix returns a time without a monotonic clock reading.
+	t.anchor = time.Unix(0, 0).Add(offset % interval)
+	t.initTicks()
+	now := clk.now()
+	t.clk.resetTimer(t, t.firstTick(nextMultiple(t.anchor, interval, now), now))
+	return newTicker(t)
+}
+
+// Return the first deadline of the new ticker timer t: when, or now if t was created with
+// WithImmediateFirstTick.
+func (t *Timer) firstTick(when, now time.Time) time.Time {
+	if !t.immediate {
+		return when
+	}
+	t.zeroTick = true
+	return now
+}
+
+// Change the interval of the ticker timer t to d and schedule its next tick d from now, or, if t
+// preserves its phase, at the first multiple of d since its anchor that is in the future.  If
+// immediate is true, the next tick is due now instead.  A pending tick is dropped.
+func (clk *Scheduler) resetTicker(t *Timer, d time.Duration, immediate bool) {
+	now := clk.now()
+	clk.lock()
+	defer clk.unlock()
+	t.period = d
+	when := now.Add(d)
+	if t.phase || t.aligned {
+		when = nextMultiple(t.anchor, d, now)
+	} else {
+		t.anchor = now
+	}
+	if immediate {
+		when = now
+	}
+	t.drainLocked()
+	if t.state != Scheduled {
+		clk.rearmTimerLocked(t, when)
+		return
+	}
+	t.nominal = when
+	when = t.boundedLocked(t.jitteredLocked(when, when.Sub(now)))
+	clk.moveTimerLocked(t, when)
+	t.dur = when.Sub(now)
+	t.n = 0
+	clk.countResetLocked(t)
+}
+
+// Return the earliest time anchor+k*d (for an integer k) that is after now.
+func nextMultiple(anchor time.Time, d time.Duration, now time.Time) time.Time {
+	if now.Before(anchor) {
+		return anchor
+	}
+	k := now.Sub(anchor)/d + 1
+	return anchor.Add(k * d)
+}
+
+// NewTicker returns a new Ticker containing a channel that will send the
+// current time on the channel after each tick. The period of the ticks is
+// specified by the duration argument. The ticker will adjust the time interval
+// or drop ticks to make up for slow receivers. The duration d must be greater
+// than zero; if not, NewTicker will panic.
+//
+// Like time.Ticker, but the ticker shares the kairos timer routine, and the
+// next tick is scheduled in the same step that delivers the current one, so
+// Stop can never miss it.
+func NewTicker(d time.Duration, opts ...Option) *Ticker {
+	return defaultScheduler.NewTicker(d, opts...)
+}
+
+// NewAlignedTicker returns a new Ticker whose ticks are aligned to the wall
+// clock: it ticks at every multiple of interval since the Unix epoch, plus
+// offset. For example, NewAlignedTicker(time.Minute, 0) ticks at the top of
+// every minute (in UTC; use the offset to align to a time zone for intervals
+// above an hour). Intervals need not divide an hour or a day evenly. After
+// each tick, the next deadline is recomputed from the current wall time, so if
+// the system clock is stepped, the ticker realigns instead of delivering a
+// burst of ticks. Reset keeps the alignment. The interval must be greater than
+// zero; if not, NewAlignedTicker will panic.
+func NewAlignedTicker(interval, offset time.Duration, opts ...Option) *Ticker {
+	return defaultScheduler.NewAlignedTicker(interval, offset, opts...)
+}
+
+// TickerFunc returns a new Ticker that calls f in its own goroutine every d,
+// with the time of the tick. The Ticker's channels are nil. Calls of f never
+// overlap: by default, ticks that are due while f is still running are
+// skipped. With WithMissedPolicy(CoalesceMissed), exactly one call is queued
+// instead, and with WithMissedPolicy(ReplayMissed), all of them are. No call
+// starts after Stop or Reset returns, and StopWait also waits for a call that
+// is already running. The duration d must be greater than zero; if not,
+// TickerFunc will panic.
+func TickerFunc(d time.Duration, f func(time.Time), opts ...Option) *Ticker {
+	return defaultScheduler.TickerFunc(d, f, opts...)
+}
+
+// NewDynamicTicker returns a new Ticker whose intervals are computed by next,
+// for example to poll with exponential backoff. The first interval is
+// next(0, 0). After the n-th tick, the following interval is next(prev, n),
+// where prev is the interval that just ended; it is measured from the tick.
+// next is called in its own goroutine, without any lock held, so it may take
+// its time, and StopWait waits for it. If next returns zero or a negative
+// duration, the ticker ends (see Ticker.Done). Reset sets the current interval
+// and restarts the ticker, which keeps calling next after that; the tick count
+// starts over.
+func NewDynamicTicker(next func(prev time.Duration, n int) time.Duration, opts ...Option) *Ticker {
+	return defaultScheduler.NewDynamicTicker(next, opts...)
+}
+
+// Stop turns off a ticker. After Stop, no more ticks will be sent. Stop does
+// not close the channel, to prevent a concurrent goroutine reading from the
+// channel from seeing an erroneous "tick".
+func (tk *Ticker) Stop() {
+	if tk.t == nil {
+		panic("timer: Stop called on uninitialized Ticker")
+	}
+	tk.t.Stop()
+}
+
+// StopWait is like Stop, but for a Ticker created by TickerFunc it also waits
+// for a call of the function that has already started to return, unless it is
+// called from within the function itself.
+func (tk *Ticker) StopWait() {
+	if tk.t == nil {
+		panic("timer: StopWait called on uninitialized Ticker")
+	}
+	tk.t.StopWait()
+}
+
+// Done returns a channel that is closed when the ticker ends: when it has
+// ticked as often as allowed by WithMaxTicks, when the end set by WithDeadline
+// is reached, or when it is stopped. This lets a receiving loop tell an ended
+// ticker from a slow one. The channel is not closed for ticks that are merely
+// dropped. Reset restarts an ended ticker with a new channel, so Done must be
+// called again after Reset.
+func (tk *Ticker) Done() <-chan struct{} {
+	if tk.t == nil {
+		panic("timer: Done called on uninitialized Ticker")
+	}
+	return tk.t.clk.tickerDone(tk.t)
+}
+
+// Reset stops a ticker and resets its period to the specified duration. The
+// next tick will arrive after the new period elapses, or, if the ticker was
+// created with WithPhasePreserved, at the next multiple of the new period
+// since the ticker was started. A pending tick that has not been received yet
+// is dropped. The only option that Reset honors is WithImmediateFirstTick,
+// which makes the next tick arrive right away instead; the others are ignored.
+// The duration d must be greater than zero; if not, Reset will panic.
+func (tk *Ticker) Reset(d time.Duration, opts ...Option) {
+	if tk.t == nil {
+		panic("timer: Reset called on uninitialized Ticker")
+	}
+	if d <= 0 {
+		panic("timer: non-positive interval for Ticker.Reset")
+	}
+	// Apply the options to a scratch Timer so that the other ones cannot change the ticker.
+	var o Timer
+	for _, opt := range opts {
+		opt(&o)
+	}
+	tk.t.clk.resetTicker(tk.t, d, o.immediate)
+}
+
+// Interval returns the current interval of the ticker: the one it was created
+// or last Reset with, or, for a Ticker created by NewDynamicTicker, the last
+// one returned by its function.
+func (tk *Ticker) Interval() time.Duration {
+	if tk.t == nil {
+		panic("timer: Interval called on uninitialized Ticker")
+	}
+	return tk.t.clk.interval(tk.t)
+}
+
+// Skipped returns the number of ticks that a ticker created with
+// WithDriftCorrection skipped because the process stalled for longer than its
+// interval. It is always 0 for other tickers.
+func (tk *Ticker) Skipped() uint64 {
+	if tk.t == nil {
+		panic("timer: Skipped called on uninitialized Ticker")
+	}
+	return tk.t.clk.skipped(tk.t)
+}
+
+// Return the number of periods skipped by t.
+func (clk *Scheduler) skipped(t *Timer) uint64 {
+	clk.lock()
+	defer clk.unlock()
+	return t.skipped
+}
@@ -0,0 +1,594 @@
+package kairos
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTicker(t *testing.T) {
+	const d = 20 * time.Millisecond
+	tk := NewTicker(d)
+	t.Cleanup(tk.Stop)
+	start := time.Now()
+	for i := 1; i <= 5; i++ {
+		select {
+		case <-tk.C:
+			if got, want := time.Since(start), time.Duration(i)*d; got < want || got >= want+margin {
+				t.Errorf("tick %v at wrong time; got duration %v, want %v", i, got, want)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("tick %v not delivered", i)
+		}
+	}
+}
+
+func TestTickerStop(t *testing.T) {
+	const d = 10 * time.Millisecond
+	tk := NewTicker(d)
+	<-tk.C
+	tk.Stop()
+	for len(tk.C) > 0 {
+		<-tk.C
+	}
+	select {
+	case <-tk.C:
+		t.Errorf("tick delivered after Stop")
+	case <-time.After(5 * d):
+	}
+}
+
+func TestTickerDropsTicks(t *testing.T) {
+	const d = 10 * time.Millisecond
+	tk := NewTicker(d)
+	t.Cleanup(tk.Stop)
+	time.Sleep(10 * d)
+	if got := len(tk.C); got != 1 {
+		t.Errorf("wrong number of queued ticks; got %v, want 1", got)
+	}
+}
+
+func TestTickerReset(t *testing.T) {
+	tk := NewTicker(time.Hour)
+	t.Cleanup(tk.Stop)
+	const d = 20 * time.Millisecond
+	start := time.Now()
+	tk.Reset(d)
+	for i := 1; i <= 3; i++ {
+		select {
+		case <-tk.C:
+			if got, want := time.Since(start), time.Duration(i)*d; got < want || got >= want+margin {
+				t.Errorf("tick %v at wrong time; got duration %v, want %v", i, got, want)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("tick %v not delivered", i)
+		}
+	}
+	// A pending tick is dropped by Reset.
+	time.Sleep(2 * d)
+	tk.Reset(time.Hour)
+	if got := len(tk.C); got != 0 {
+		t.Errorf("pending tick not dropped by Reset")
+	}
+}
+
+func TestTickerResetPhasePreserved(t *testing.T) {
+	const d = 100 * time.Millisecond
+	tk := NewTicker(time.Hour, WithPhasePreserved())
+	t.Cleanup(tk.Stop)
+	start, _ := tk.t.Deadline()
+	start = start.Add(-time.Hour)
+	time.Sleep(d * 3 / 2)
+	tk.Reset(d)
+	// The next tick is at start+2d, not at the time of Reset plus d.
+	want := start.Add(2 * d)
+	select {
+	case <-tk.C:
+		if got := time.Now(); got.Before(want) || got.Sub(want) >= margin {
+			t.Errorf("tick at wrong time; got %v after start, want %v", got.Sub(start), want.Sub(start))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("tick not delivered")
+	}
+}
+
+func TestTickerDriftCorrection(t *testing.T) {
+	// The interval must be long enough that scheduling hiccups of the test machine do not make the
+	// ticker skip a period.
+	const d = 25 * time.Millisecond
+	const n = 20
+	tk := NewTicker(d, WithDriftCorrection())
+	t.Cleanup(tk.Stop)
+	start, _ := tk.t.Deadline()
+	start = start.Add(-d)
+	var last time.Time
+	for i := 0; i < n; i++ {
+		select {
+		case last = <-tk.C:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("tick %v not delivered", i)
+		}
+	}
+	// Without drift correction, the lateness of every tick adds up.  With it, the n-th tick is due
+	// exactly at start+n*d.
+	want := start.Add(n * d)
+	if last.Before(want) || last.Sub(want) >= margin {
+		t.Errorf("tick %v at wrong time; got %v after start, want %v", n, last.Sub(start), want.Sub(start))
+	}
+	if got := tk.Skipped(); got != 0 {
+		t.Errorf("wrong number of skipped ticks; got %v, want 0", got)
+	}
+}
+
+func TestTickerDriftCorrectionSkips(t *testing.T) {
+	clk := NewScheduler()
+	const d = 10 * time.Millisecond
+	// Simulate a stall by making the ticker overdue by several periods.
+	tk := clk.NewTicker(time.Hour, WithDriftCorrection())
+	t.Cleanup(tk.Stop)
+	clk.mutex.Lock()
+	tk.t.period = d
+	clk.mutex.Unlock()
+	clk.extendTimer(tk.t, -time.Hour-5*d/2)
+	<-tk.C
+	// The deadline was 2.5 periods ago, so 2 periods were skipped and the next tick is due half a
+	// period from now.
+	if got := tk.Skipped(); got != 2 {
+		t.Errorf("wrong number of skipped ticks; got %v, want 2", got)
+	}
+	if got := tk.t.Remaining(); got <= 0 || got > d/2 {
+		t.Errorf("wrong time until next tick; got %v, want (0, %v]", got, d/2)
+	}
+}
+
+func TestAlignedTicker(t *testing.T) {
+	const interval = 70 * time.Millisecond // Does not divide a second evenly.
+	const offset = 30 * time.Millisecond
+	tk := NewAlignedTicker(interval, offset)
+	t.Cleanup(tk.Stop)
+	for i := 0; i < 5; i++ {
+		select {
+		case got := <-tk.C:
+			// The tick time is at most margin after an aligned boundary.
+			if phase := time.Duration(got.UnixNano()) % interval; phase < offset || phase >= offset+margin%interval {
+				t.Errorf("tick %v not aligned; got phase %v, want ~%v", i, phase, offset)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("tick %v not delivered", i)
+		}
+	}
+}
+
+func TestAlignedTickerRealigns(t *testing.T) {
+	// Simulate a backwards step of the wall clock by moving the deadline far into the past: the
+	// ticker must deliver a single tick and realign, not catch up with a burst.
+	clk := NewScheduler()
+	const interval = 50 * time.Millisecond
+	tk := clk.NewAlignedTicker(interval, 0)
+	t.Cleanup(tk.Stop)
+	clk.extendTimer(tk.t, -time.Hour)
+	<-tk.C
+	if got := tk.t.Remaining(); got <= 0 || got > interval {
+		t.Errorf("ticker did not realign; got %v until next tick, want (0, %v]", got, interval)
+	}
+	when, _ := tk.t.Deadline()
+	if phase := time.Duration(when.UnixNano()) % interval; phase != 0 {
+		t.Errorf("next deadline not aligned; got phase %v, want 0", phase)
+	}
+}
+
+func TestNextMultiple(t *testing.T) {
+	anchor := time.Unix(1000, 0)
+	for _, tc := range []struct {
+		now  time.Duration // Relative to anchor.
+		want time.Duration // Relative to anchor.
+	}{
+		{-5 * time.Second, 0},
+		{0, 10 * time.Second},
+		{5 * time.Second, 10 * time.Second},
+		{10 * time.Second, 20 * time.Second},
+		{25 * time.Second, 30 * time.Second},
+	} {
+		if got := nextMultiple(anchor, 10*time.Second, anchor.Add(tc.now)).Sub(anchor); got != tc.want {
+			t.Errorf("nextMultiple(anchor, 10s, anchor+%v) = anchor+%v, want anchor+%v", tc.now, got, tc.want)
+		}
+	}
+}
+
+func TestTickerPanic(t *testing.T) {
+	defer func() {
+		r := recover()
+		if r == nil || r.(string) != "timer: non-positive interval for NewTicker" {
+			t.Errorf("invalid panic %v", r)
+		}
+	}()
+
+	NewTicker(0)
+}
+
+func TestTickerCoalesceMissed(t *testing.T) {
+	const d = 50 * time.Millisecond
+	tk := NewTicker(d, WithMissedPolicy(CoalesceMissed))
+	t.Cleanup(tk.Stop)
+	if tk.C != nil {
+		t.Errorf("C is not nil with CoalesceMissed")
+	}
+	// The first tick waits in the channel while the next four are missed.
+	time.Sleep(5*d + d/2)
+	if tick := <-tk.Ticks; tick.Missed != 0 {
+		t.Errorf("first tick missed %v ticks, want 0", tick.Missed)
+	}
+	if tick := <-tk.Ticks; tick.Missed < 3 || tick.Missed > 4 {
+		t.Errorf("second tick missed %v ticks, want 4", tick.Missed)
+	}
+	if tick := <-tk.Ticks; tick.Missed != 0 {
+		t.Errorf("third tick missed %v ticks, want 0", tick.Missed)
+	}
+}
+
+func TestTickerReplayMissed(t *testing.T) {
+	const d = 50 * time.Millisecond
+	tk := NewTicker(d, WithMissedPolicy(ReplayMissed))
+	t.Cleanup(tk.Stop)
+	time.Sleep(5*d + d/2)
+	// All five ticks must be available right away, in order.
+	var prev time.Time
+	for i := 0; i < 5; i++ {
+		select {
+		case tick := <-tk.Ticks:
+			if tick.Time.Before(prev) {
+				t.Errorf("tick %v at %v is before the previous one at %v", i, tick.Time, prev)
+			}
+			if want := uint64(i + 1); tick.Seq != want {
+				t.Errorf("tick %v has Seq %v, want %v", i, tick.Seq, want)
+			}
+			prev = tick.Time
+		case <-time.After(d / 2):
+			t.Fatalf("only %v ticks were replayed, want 5", i)
+		}
+	}
+}
+
+func TestTickerReplayMissedStop(t *testing.T) {
+	const d = 20 * time.Millisecond
+	tk := NewTicker(d, WithMissedPolicy(ReplayMissed), WithModernSemantics())
+	time.Sleep(5 * d)
+	tk.Stop()
+	select {
+	case <-tk.Ticks:
+		t.Errorf("tick replayed after Stop")
+	case <-time.After(5 * d):
+	}
+	// Reset must start over without the old backlog.
+	tk.Reset(d)
+	t.Cleanup(tk.Stop)
+	start := time.Now()
+	<-tk.Ticks
+	if elapsed := time.Since(start); elapsed < d {
+		t.Errorf("first tick after Reset %v early", d-elapsed)
+	}
+}
+
+// Stop and Reset a ticker whose replay is in the middle of a send while the receiver takes a tick
+// and the ticker keeps falling behind, with the checks on: the replay must neither leave a stale tick behind nor let the ticker fire
+// halfway through Stop or Reset.
+func TestTickerReplayMissedStopDuringSend(t *testing.T) {
+	EnableInvariantChecks(true)
+	defer EnableInvariantChecks(false)
+	const d = time.Second
+	for i := 0; i < 200; i++ {
+		fc := NewFakeClock()
+		tk := fc.NewTicker(d, WithMissedPolicy(ReplayMissed), WithModernSemantics())
+		fc.Advance(3 * d)
+		var wg sync.WaitGroup
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-tk.Ticks:
+			default:
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			fc.Advance(3 * d)
+		}()
+		go func() {
+			defer wg.Done()
+			tk.Reset(d)
+		}()
+		tk.Stop()
+		wg.Wait()
+		tk.Stop()
+		select {
+		case tick := <-tk.Ticks:
+			t.Fatalf("tick %v left after Stop", tick.Seq)
+		case <-time.After(time.Millisecond):
+		}
+		fc.Shutdown(context.Background())
+	}
+}
+
+// Stop several ReplayMissed tickers at once while their receivers keep taking ticks, so that a
+// replay may be stopped while it waits for another stopped one to exit.
+func TestTickerReplayMissedStopTogether(t *testing.T) {
+	const d = time.Second
+	for i := 0; i < 200; i++ {
+		fc := NewFakeClock()
+		var tks []*Ticker
+		quit := make(chan struct{})
+		for j := 0; j < 4; j++ {
+			tk := fc.NewTicker(d, WithMissedPolicy(ReplayMissed))
+			tks = append(tks, tk)
+			go func() {
+				for {
+					select {
+					case <-tk.Ticks:
+					case <-quit:
+						return
+					}
+				}
+			}()
+		}
+		fc.Advance(1000 * d)
+		var wg sync.WaitGroup
+		for _, tk := range tks {
+			wg.Add(1)
+			go func(tk *Ticker) {
+				defer wg.Done()
+				tk.Stop()
+			}(tk)
+		}
+		stopped := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Stop deadlocked")
+		}
+		close(quit)
+		fc.Shutdown(context.Background())
+	}
+}
+
+func TestTickerFunc(t *testing.T) {
+	const d = 20 * time.Millisecond
+	for _, tc := range []struct {
+		policy   MissedPolicy
+		min, max int
+	}{
+		// Each call takes 2.5 intervals, so with skipping, every third tick starts a call.
+		{SkipMissed, 3, 4},
+		// With queueing, calls run back to back.
+		{CoalesceMissed, 4, 5},
+		{ReplayMissed, 4, 5},
+	} {
+		t.Run(fmt.Sprint(tc.policy), func(t *testing.T) {
+			var calls, running atomic.Int32
+			tk := TickerFunc(d, func(time.Time) {
+				if running.Add(1) != 1 {
+					t.Errorf("calls overlap")
+				}
+				time.Sleep(d * 5 / 2)
+				calls.Add(1)
+				running.Add(-1)
+			}, WithMissedPolicy(tc.policy))
+			time.Sleep(12 * d)
+			tk.StopWait()
+			if running.Load() != 0 {
+				t.Errorf("StopWait returned while a call was running")
+			}
+			n := int(calls.Load())
+			if n < tc.min || n > tc.max {
+				t.Errorf("got %v calls, want between %v and %v", n, tc.min, tc.max)
+			}
+			time.Sleep(5 * d)
+			if got := int(calls.Load()); got != n {
+				t.Errorf("%v calls after StopWait", got-n)
+			}
+		})
+	}
+}
+
+func TestTickerFuncStop(t *testing.T) {
+	const d = 10 * time.Millisecond
+	started := make(chan struct{}, 100)
+	release := make(chan struct{})
+	tk := TickerFunc(d, func(time.Time) {
+		started <- struct{}{}
+		<-release
+	}, WithMissedPolicy(ReplayMissed))
+	<-started
+	// Let a backlog build up behind the blocked call, then make sure Stop discards it.
+	time.Sleep(5 * d)
+	tk.Stop()
+	close(release)
+	time.Sleep(5 * d)
+	if n := len(started); n != 0 {
+		t.Errorf("%v calls started after Stop", n)
+	}
+}
+
+func TestTickerMaxTicks(t *testing.T) {
+	const d = 10 * time.Millisecond
+	tk := NewTicker(d, WithMaxTicks(3))
+	t.Cleanup(tk.Stop)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-tk.C:
+		case <-tk.Done():
+			t.Fatalf("ticker ended after %v ticks", i)
+		}
+	}
+	select {
+	case <-tk.C:
+		t.Errorf("ticker ticked more than 3 times")
+	case <-tk.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Done was not closed")
+	}
+	// Reset restarts the count with a new channel.
+	tk.Reset(d)
+	select {
+	case <-tk.Done():
+		t.Errorf("Done still closed after Reset")
+	default:
+	}
+}
+
+func TestTickerDeadline(t *testing.T) {
+	const d = 20 * time.Millisecond
+	start := time.Now()
+	end := start.Add(5*d + d/2)
+	var calls atomic.Int32
+	tk := TickerFunc(d, func(time.Time) { calls.Add(1) }, WithDeadline(end))
+	t.Cleanup(tk.Stop)
+	select {
+	case <-tk.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Done was not closed")
+	}
+	if now := time.Now(); now.Before(end) || now.After(end.Add(margin)) {
+		t.Errorf("Done closed %v after the deadline", now.Sub(end))
+	}
+	time.Sleep(2 * d)
+	if n := calls.Load(); n != 5 {
+		t.Errorf("got %v calls, want 5", n)
+	}
+}
+
+func TestTickerDoneStop(t *testing.T) {
+	tk := NewTicker(time.Hour)
+	done := tk.Done()
+	tk.Stop()
+	select {
+	case <-done:
+	default:
+		t.Errorf("Stop did not close Done")
+	}
+}
+
+func TestDynamicTicker(t *testing.T) {
+	const d = 10 * time.Millisecond
+	var calls []int
+	tk := NewDynamicTicker(func(prev time.Duration, n int) time.Duration {
+		calls = append(calls, n)
+		if n == 0 {
+			return d
+		}
+		if n == 4 {
+			return 0
+		}
+		return 2 * prev
+	})
+	t.Cleanup(tk.Stop)
+	start := time.Now()
+	prev := start
+	for i, want := range []time.Duration{d, 2 * d, 4 * d, 8 * d} {
+		select {
+		case <-tk.C:
+		case <-tk.Done():
+			t.Fatalf("ticker ended after %v ticks", i)
+		}
+		now := time.Now()
+		if got := now.Sub(prev); got < want || got > want+margin {
+			t.Errorf("tick %v came %v after the previous one, want %v", i+1, got, want)
+		}
+		prev = now
+	}
+	select {
+	case <-tk.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Done was not closed")
+	}
+	if got := tk.Interval(); got != 8*d {
+		t.Errorf("Interval() = %v, want %v", got, 8*d)
+	}
+	if got, want := fmt.Sprint(calls), "[0 1 2 3 4]"; got != want {
+		t.Errorf("next called with n = %v, want %v", got, want)
+	}
+}
+
+func TestDynamicTickerStop(t *testing.T) {
+	release := make(chan struct{})
+	tk := NewDynamicTicker(func(prev time.Duration, n int) time.Duration {
+		if n > 0 {
+			<-release
+		}
+		return time.Millisecond
+	})
+	<-tk.C
+	// next is blocked; stopping now must keep it from re-arming the ticker.
+	tk.Stop()
+	close(release)
+	tk.StopWait()
+	select {
+	case <-tk.C:
+		t.Errorf("ticker re-armed after Stop")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestTickerSeq(t *testing.T) {
+	const d = 20 * time.Millisecond
+	tk := NewTicker(d, WithTickEvents())
+	t.Cleanup(tk.Stop)
+	if tk.C != nil {
+		t.Errorf("C is not nil with WithTickEvents")
+	}
+	if tick := <-tk.Ticks; tick.Seq != 1 {
+		t.Errorf("first tick has Seq %v, want 1", tick.Seq)
+	}
+	// Tick 2 waits in the channel while ticks 3 to 6 are dropped.
+	time.Sleep(5*d + d/2)
+	if tick := <-tk.Ticks; tick.Seq != 2 {
+		t.Errorf("second tick has Seq %v, want 2", tick.Seq)
+	}
+	if tick := <-tk.Ticks; tick.Seq < 6 || tick.Seq > 7 {
+		t.Errorf("third tick has Seq %v, want 7", tick.Seq)
+	}
+	// Reset does not restart the sequence.
+	tk.Reset(d)
+	if tick := <-tk.Ticks; tick.Seq < 7 {
+		t.Errorf("tick after Reset has Seq %v, want more than 7", tick.Seq)
+	}
+}
+
+func TestTickerImmediateFirstTick(t *testing.T) {
+	const d = 50 * time.Millisecond
+	start := time.Now()
+	tk := NewTicker(d, WithImmediateFirstTick(), WithTickEvents(), WithMaxTicks(3))
+	t.Cleanup(tk.Stop)
+	for i, want := range []time.Duration{0, d, 2 * d} {
+		tick := <-tk.Ticks
+		if got := time.Since(start); got < want || got > want+margin {
+			t.Errorf("tick %v came after %v, want %v", i, got, want)
+		}
+		if tick.Seq != uint64(i) {
+			t.Errorf("tick %v has Seq %v", i, tick.Seq)
+		}
+	}
+	select {
+	case <-tk.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("immediate tick not counted by WithMaxTicks")
+	}
+
+	start = time.Now()
+	tk.Reset(d, WithImmediateFirstTick())
+	if tick := <-tk.Ticks; tick.Seq != 3 || time.Since(start) > margin {
+		t.Errorf("got tick %v after %v from Reset, want Seq 3 right away", tick.Seq, time.Since(start))
+	}
+	tk.Reset(d)
+	start = time.Now()
+	<-tk.Ticks
+	if got := time.Since(start); got < d {
+		t.Errorf("Reset without WithImmediateFirstTick ticked after %v", got)
+	}
+}
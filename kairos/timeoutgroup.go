@@ -0,0 +1,92 @@
+package kairos
+
+import (
+	"time"
+	"unsafe"
+)
+
+// A TimeoutGroup is a set of timers that can all be stopped at once, for example to cancel the
+// timeouts of a batch of tasks on shutdown. A TimeoutGroup must be created with NewTimeoutGroup.
+type TimeoutGroup struct {
+	clk     *Scheduler
+	members map[*Timer]struct{} // The pending or paused timers of the group; guarded by clk.mutex.
+}
+
+// NewTimeoutGroup creates a new, empty [TimeoutGroup] for timers of clk.
+func (clk *Scheduler) NewTimeoutGroup() *TimeoutGroup {
+	g := &TimeoutGroup{members: make(map[*Timer]struct{})}
+	// All members share one shard, so that StopAll needs a single lock.
+	g.clk = clk.shard(unsafe.Pointer(g))
+	return g
+}
+
+// NewTimeoutGroup creates a new, empty TimeoutGroup.
+func NewTimeoutGroup() *TimeoutGroup {
+	return defaultScheduler.NewTimeoutGroup()
+}
+
+// NewTimer is like the package function NewTimer, but the timer belongs to the group.
+func (g *TimeoutGroup) NewTimer(d time.Duration, opts ...Option) *Timer {
+	if g.clk == nil {
+		panic("timer: NewTimer called on uninitialized TimeoutGroup")
+	}
+	t := g.clk.NewStoppedTimer(opts...)
+	t.group = g
+	g.clk.resetTimer(t, g.clk.now().Add(d))
+	return t
+}
+
+// After is like AfterFunc, but the timer belongs to the group.
+func (g *TimeoutGroup) After(d time.Duration, f func(), opts ...Option) *Timer {
+	if g.clk == nil {
+		panic("timer: After called on uninitialized TimeoutGroup")
+	}
+	t := g.clk.NewStoppedFunc(f, opts...)
+	t.group = g
+	g.clk.resetTimer(t, g.clk.now().Add(d))
+	return t
+}
+
+// StopAll stops every timer of the group that is still pending (or paused) and drains its
+// channel, all in a single critical section, so that none of them can fire while the others are
+// being stopped. Timers that are started again afterwards (with Reset, for example) rejoin the
+// group.
+func (g *TimeoutGroup) StopAll() {
+	if g.clk == nil {
+		panic("timer: StopAll called on uninitialized TimeoutGroup")
+	}
+	clk := g.clk
+	var onStops []func()
+	clk.lock()
+	for t := range g.members {
+		// Removes t from g.members, which is allowed while ranging over it.
+		b := clk.delTimerLocked(t)
+		t.drainLocked()
+		t.endLocked()
+		if f := t.takeOnStopLocked(b); f != nil {
+			onStops = append(onStops, f)
+		}
+	}
+	clk.unlock()
+	for _, f := range onStops {
+		f()
+	}
+}
+
+// Len returns the number of timers of the group that are pending or paused. Timers leave the group
+// when they fire (unless they repeat) or are stopped.
+func (g *TimeoutGroup) Len() int {
+	if g.clk == nil {
+		panic("timer: Len called on uninitialized TimeoutGroup")
+	}
+	g.clk.lock()
+	defer g.clk.unlock()
+	return len(g.members)
+}
+
+// Remove t from the group it belongs to, if it is a member.  The caller must hold the mutex.
+func (t *Timer) leaveGroupLocked() {
+	if t.group != nil {
+		delete(t.group.members, t)
+	}
+}
@@ -0,0 +1,52 @@
+package kairos
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTimeoutGroup(t *testing.T) {
+	clk := NewScheduler()
+	g := clk.NewTimeoutGroup()
+	var calls atomic.Int32
+	ts := []*Timer{
+		g.NewTimer(time.Hour),
+		g.NewTimer(time.Hour),
+		g.After(time.Hour, func() { calls.Add(1) }),
+	}
+	fired := g.NewTimer(0)
+	<-fired.C
+	g.NewTimer(0).Stop()
+	if n := g.Len(); n != 3 {
+		t.Errorf("Len() = %v, want 3", n)
+	}
+	// A fired value is drained, too.
+	ts[0].ResetKeepPending(0)
+	time.Sleep(10 * time.Millisecond)
+	ts[0].ResetKeepPending(time.Hour)
+	g.StopAll()
+	if n := g.Len(); n != 0 {
+		t.Errorf("Len() = %v after StopAll, want 0", n)
+	}
+	if n := clk.PendingCount(); n != 0 {
+		t.Errorf("%v timers pending after StopAll", n)
+	}
+	for i, timer := range ts {
+		if got := timer.State(); got != Stopped {
+			t.Errorf("timer %v is %v after StopAll, want %v", i, got, Stopped)
+		}
+	}
+	if n := len(ts[0].C); n != 0 {
+		t.Errorf("StopAll left %v values on the channel", n)
+	}
+	// Restarted timers rejoin the group.
+	ts[1].Reset(time.Hour)
+	if n := g.Len(); n != 1 {
+		t.Errorf("Len() = %v after Reset, want 1", n)
+	}
+	g.StopAll()
+	if n := calls.Load(); n != 0 {
+		t.Errorf("stopped function called %v times", n)
+	}
+}
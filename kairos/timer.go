@@ -2,41 +2,327 @@
 package kairos
 
 import (
+	"context"
+	"fmt"
 	"time"
 )
 
 // The Timer type represents a single event. When the Timer expires,
-// the current time will be sent on C, unless the Timer was created by AfterFunc.
-// A Timer must be created with NewTimer. NewStoppedTimer or AfterFunc.
+// the current time will be sent on C, unless the Timer was created by AfterFunc
+// (or another constructor that takes a function to call instead).
+// A Timer must be created with NewTimer, NewTimerAt, NewStoppedTimer, AfterFunc,
+// NewStoppedFunc or NewRepeatTimer.
 type Timer struct {
 	C <-chan time.Time
 	c chan<- time.Time // Same channel as C.
+	f func()           // Called in its own goroutine on expiry if the Timer was created by AfterFunc.
 
-	i    int       // heap index.
-	when time.Time // Timer wakes up at when.
+	// Called in its own goroutine on expiry (if f is nil) by timers that need to know more about the
+	// expiry than func() can tell them.
+	call func(expiry)
+
+	clk *Scheduler // The clock the Timer belongs to.  Immutable after creation.
+
+	// Hooks used by wrappers such as ValueTimer that deliver on a channel other than C.  Both are
+	// called with the clock mutex held.  send must not block.
+	send  func(e expiry)
+	drain func()
+
+	i       int           // heap index.
+	when    time.Time     // Timer wakes up at when.
+	order   uint64        // Orders the timers with the same deadline by when they were queued.
+	nominal time.Time     // when before jitter was applied.
+	slack   time.Duration // Set by WithSlack.
+	late    bool          // Set by WithLateness.
+	ref     refClock      // Set by WithWallClock or WithIncludeSuspend.
+	refWhen time.Duration // when by ref, if it is not the monotonic clock.
+	state   TimerState    // The timer is in the heap if and only if state is Scheduled.
+	left    time.Duration // Time left when the timer was paused.
+	dur     time.Duration // Duration the timer was last started with, adjusted by Extend.
+
+	period time.Duration // If positive, the timer is re-armed this long after each fire...
+	limit  int           // ...until it has fired limit times (forever if limit <= 0).
+	n      int           // Number of fires since the timer was last started.
+	anchor time.Time     // When a ticker was started; its phase is relative to this.
+	phase  bool          // Set by WithPhasePreserved.
+	drift  bool          // Set by WithDriftCorrection.
+	// Whether the ticker is aligned to the wall clock.  If so, anchor has no monotonic clock reading,
+	// so deadlines are compared against the wall clock.
+	aligned bool
+
+	skipped uint64 // Number of periods skipped by drift correction.
+
+	// Set by NewDynamicTicker.  If set, it replaces period after each fire.
+	nextInterval func(prev time.Duration, n int) time.Duration
+	gen          uint64 // Incremented whenever the timer is stopped or re-armed.
+
+	calendar *calendar // Set by NewCalendarTimer and NewCalendarTicker.  If set, it replaces period.
+	dst      DSTPolicy // Set by WithDSTPolicy.
+
+	end   time.Time     // Set by WithDeadline.
+	ended bool          // Whether a ticker has ended; see Ticker.Done.
+	done  chan struct{} // Returned by Ticker.Done; created on demand.
+
+	jitter float64        // Set by WithJitter.
+	rnd    func() float64 // Set by WithJitterSource.
+
+	onTick     func(time.Time) // Called in its own goroutine on every tick of a TickerFunc.
+	tickBusy   bool            // Whether onTick is running.
+	policy     MissedPolicy    // Set by WithMissedPolicy.
+	events     bool            // Set by WithTickEvents.
+	ticks      chan TickEvent  // Ticker.Ticks, if events is set or policy is not SkipMissed.
+	seq        uint64          // Number of ticks that have been due; see TickEvent.Seq.
+	immediate  bool            // Set by WithImmediateFirstTick.
+	zeroTick   bool            // Whether the next tick is the immediate first tick of a new ticker.
+	lastTick   time.Time       // When the ticker last fired, or zero if it has not since it was started.
+	backlog    int             // Ticks that are due but have not been delivered or run yet.
+	replaying  bool            // Whether a goroutine is delivering the backlog of a ReplayMissed ticker.
+	stopReplay chan struct{}   // Closed to stop that goroutine.
+
+	modern  bool   // Set by WithModernSemantics.
+	name    string // Set by SetName or WithName, for debugging.
+	payload []byte // Set by WithPayload, for Snapshot; nil if t is not to be snapshotted.
+	// The profiler labels of the callbacks of t, if it has a name; created by labelsLocked.
+	labels context.Context
+
+	// The context of the current arming of a timer created by NewTimerFuncCtx, and its cancel
+	// function, which is nil for other timers.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	group  *TimeoutGroup    // The group that created the timer, if any.
+	bound  *binding         // Set by BindContext.
+	onStop func()           // Set by OnStop.
+	subs   []chan time.Time // Added by Subscribe.
+
+	inflight int      // Number of started calls to f that have not returned yet.
+	running  []uint64 // IDs of the goroutines running those calls, once they have registered, or 0.
+	waited   bool     // Whether StopWait has been called, so that the calls record their goroutine.
+
+	jobs   []callbackJob // Calls waiting for a worker of the callback pool, oldest first.
+	pooled bool          // Whether t is queued for or being run by a pool worker.
+	async  bool          // Set by WithAsyncCallback.
+
+	acquired *Scheduler // The Scheduler whose AcquireTimer returned t, until t is released.
+	callers  []uintptr  // The call stack of the constructor of t, if EnableCallSites was on.
+
+	// The slot of the timing wheel that holds t, if any, and the neighbors of t in it.
+	wslot        *wheelSlot
+	wnext, wprev *Timer
+}
+
+// TimerState describes where a Timer is in its lifecycle.
+type TimerState int
+
+const (
+	// Stopped means that the timer is not scheduled: it was never started, or it was stopped
+	// before it fired.
+	Stopped TimerState = iota
+	// Scheduled means that the timer is waiting for its deadline.
+	Scheduled
+	// Fired means that the timer expired and delivered its notification (the value was sent on C
+	// or the AfterFunc function was started), whether or not the value has been received.
+	Fired
+	// Paused means that the timer was stopped by Pause and can be restarted by Resume.
+	Paused
+)
+
+func (s TimerState) String() string {
+	switch s {
+	case Stopped:
+		return "Stopped"
+	case Scheduled:
+		return "Scheduled"
+	case Fired:
+		return "Fired"
+	case Paused:
+		return "Paused"
+	}
+	return fmt.Sprintf("TimerState(%d)", int(s))
 }
 
 // NewTimer creates a new Timer that will send the current time on its
 // channel after at least duration d.
-func NewTimer(d time.Duration) *Timer {
-	return realClock.NewTimer(d)
+func NewTimer(d time.Duration, opts ...Option) *Timer {
+	return defaultScheduler.NewTimer(d, opts...)
+}
+
+// NewTimerAt creates a new Timer that will send the current time on its
+// channel at or after the deadline when. If when is already in the past,
+// the Timer fires as soon as possible.
+func NewTimerAt(when time.Time, opts ...Option) *Timer {
+	return defaultScheduler.NewTimerAt(when, opts...)
 }
 
-// NewStoppedTimer creates a new stopped Timer.
-func NewStoppedTimer() *Timer {
-	return realClock.NewStoppedTimer()
+// NewStoppedTimer creates a new stopped Timer. It does not fire until it is
+// started with Reset or ResetAt.
+func NewStoppedTimer(opts ...Option) *Timer {
+	return defaultScheduler.NewStoppedTimer(opts...)
+}
+
+// NewStoppedFunc creates a new stopped Timer that, once started with Reset or
+// ResetAt, calls f in its own goroutine when it expires. The Timer's C field
+// is nil.
+func NewStoppedFunc(f func(), opts ...Option) *Timer {
+	return defaultScheduler.NewStoppedFunc(f, opts...)
+}
+
+// After waits for the duration to elapse and then sends the current time
+// on the returned channel.
+// It is equivalent to NewTimer(d).C.
+// The underlying Timer is not recovered by the garbage collector
+// until the timer fires. If efficiency is a concern, use NewTimer
+// instead and call Timer.Stop if the timer is no longer needed.
+func After(d time.Duration) <-chan time.Time {
+	return defaultScheduler.After(d)
+}
+
+// AfterFunc waits for the duration to elapse and then calls f
+// in its own goroutine. It returns a Timer that can
+// be used to cancel the call using its Stop method, or to schedule
+// another call using its Reset method. The Timer's C field is nil.
+// f is called without any lock of the package held, so it may create, reset,
+// or stop timers, including its own, and a slow f does not delay other timers.
+func AfterFunc(d time.Duration, f func(), opts ...Option) *Timer {
+	return defaultScheduler.AfterFunc(d, f, opts...)
+}
+
+// AfterFuncScheduled is like AfterFunc, but f also receives the deadline the
+// timer was armed with (scheduled) and the time at which the timer routine
+// processed the expiry (actual), so drift-sensitive code can measure lateness.
+func AfterFuncScheduled(d time.Duration, f func(scheduled, actual time.Time), opts ...Option) *Timer {
+	return defaultScheduler.AfterFuncScheduled(d, f, opts...)
+}
+
+// NewTimerFuncCtx is like AfterFunc, but f also receives a context, which is
+// created anew every time the timer is started (by NewTimerFuncCtx, Reset, and
+// so on), and the time at which the timer fired. Stop cancels the context, and
+// so does Reset before it creates the next one, even if the timer has already
+// fired: a call of f that is still running sees ctx.Done() and can give up
+// early. The context is never canceled just because the timer fired.
+func NewTimerFuncCtx(d time.Duration, f func(ctx context.Context, fired time.Time), opts ...Option) *Timer {
+	return defaultScheduler.NewTimerFuncCtx(d, f, opts...)
+}
+
+// NewRepeatTimer creates a new Timer that calls f in its own goroutine every
+// d, until f has been called count times. If count <= 0, it repeats until the
+// timer is stopped. f receives the number of the current iteration, starting
+// at 1. The timer is re-armed in the same step that fires it, so Stop
+// always cancels all remaining iterations; calls that have already started
+// are not affected (use StopWait to wait for them). Reset restarts the
+// iteration count. The Timer's C field is nil.
+func NewRepeatTimer(d time.Duration, count int, f func(n int), opts ...Option) *Timer {
+	return defaultScheduler.NewRepeatTimer(d, count, f, opts...)
 }
 
 // Stop prevents the Timer from firing.
 // It returns true if the call stops the timer,
 // false if the timer has already expired or been stopped.
+// Unlike time.Timer, a true return value guarantees that no value will be
+// sent on t.C for the current arming, and a false return value for an
+// expired channel timer guarantees that the value has already been sent.
 // Stop does not close the channel, to prevent a read from
 // the channel succeeding incorrectly.
+//
+// For a func-based timer created with AfterFunc(d, f), if t.Stop returns false,
+// then the timer has already expired and the function f has been started in its
+// own goroutine; Stop does not wait for f to complete before returning.
 func (t *Timer) Stop() (wasActive bool) {
-	if t.c == nil {
+	if t.clk == nil {
 		panic("timer: Stop called on uninitialized Timer")
 	}
-	return realClock.delTimer(t)
+	return t.clk.delTimer(t)
+}
+
+// Subscribe returns a new channel on which the current time is sent whenever
+// the timer fires, in addition to the timer's usual notification. This lets
+// several goroutines react to the same deadline. Like C, the channel has a
+// capacity of 1 and sends never block: a subscriber that has not received the
+// previous value misses the new one. Reset clears all subscriber channels just
+// like it clears C. Subscriber channels are never closed.
+func (t *Timer) Subscribe() <-chan time.Time {
+	if t.clk == nil {
+		panic("timer: Subscribe called on uninitialized Timer")
+	}
+	return t.clk.subscribe(t)
+}
+
+// Unsubscribe stops sending on a channel returned by Subscribe. It returns
+// false if c was not subscribed to the timer.
+func (t *Timer) Unsubscribe(c <-chan time.Time) bool {
+	if t.clk == nil {
+		panic("timer: Unsubscribe called on uninitialized Timer")
+	}
+	return t.clk.unsubscribe(t, c)
+}
+
+// OnStop registers f to be called when a Stop, StopWait, or StopAndDrain call
+// actually stops the pending (or paused) timer, for example to release
+// resources attached to it. f is not called when the timer fires, is reset, or
+// when Stop returns false. f runs at most once, in the goroutine that stopped
+// the timer, after the clock's lock has been released, so it may call methods
+// of the timer. Registering a new function replaces the previous one; f may be
+// nil to unregister it.
+func (t *Timer) OnStop(f func()) {
+	if t.clk == nil {
+		panic("timer: OnStop called on uninitialized Timer")
+	}
+	t.clk.setOnStop(t, f)
+}
+
+// StopWait is like Stop, but for a Timer created by AfterFunc or NewStoppedFunc
+// it also waits for any call of the function that has already started to
+// return. After StopWait returns, the function is neither running nor going to
+// start (until the timer is started again), so resources it uses can be torn
+// down safely. If StopWait is called from within the function itself, it does
+// not wait for that call (which would deadlock), only for other calls. To
+// tell them apart cheaply, the calls only record their goroutine once StopWait
+// has been called on the timer. So the first StopWait of a timer, if it is
+// called from within the function of another timer, does not wait for one of
+// the calls that had started before it.
+func (t *Timer) StopWait() (wasActive bool) {
+	if t.clk == nil {
+		panic("timer: StopWait called on uninitialized Timer")
+	}
+	return t.clk.stopWaitTimer(t)
+}
+
+// StopAndDrain stops the timer and clears t.C atomically. After it returns, t.C
+// is empty and nothing will be sent on it until the timer is started again, so
+// the caller does not need the racy "if !t.Stop() { <-t.C }" dance. It returns
+// true if the timer had fired since it was last started (whether or not the
+// value had been received).
+func (t *Timer) StopAndDrain() (fired bool) {
+	if t.clk == nil {
+		panic("timer: StopAndDrain called on uninitialized Timer")
+	}
+	return t.clk.stopAndDrainTimer(t)
+}
+
+// BindContext makes the timer stop and drain itself, as by StopAndDrain, as
+// soon as ctx is done, so that cleanup can be attached to a timer created
+// elsewhere. Binding another context replaces the previous one, and binding a
+// nil context only removes it. If ctx is already done, the timer is stopped
+// before BindContext returns. The binding survives Reset. Contexts of the
+// standard library are watched without a goroutine per binding.
+func (t *Timer) BindContext(ctx context.Context) {
+	if t.clk == nil {
+		panic("timer: BindContext called on uninitialized Timer")
+	}
+	t.clk.bindContext(t, ctx)
+}
+
+// FireNow makes a pending (or paused) timer fire immediately, exactly as if its
+// deadline had arrived: the current time is sent on t.C, or the AfterFunc
+// function is started in its own goroutine. It returns true if the timer was
+// fired, false if it had already expired or been stopped, in which case it does
+// nothing. A timer never fires twice for one arming, even if FireNow races with
+// its natural expiry.
+func (t *Timer) FireNow() bool {
+	if t.clk == nil {
+		panic("timer: FireNow called on uninitialized Timer")
+	}
+	return t.clk.fireTimer(t)
 }
 
 // Reset changes the timer to expire after duration d.
@@ -45,8 +331,200 @@ func (t *Timer) Stop() (wasActive bool) {
 // The channel t.C is cleared and calling t.Reset() behaves as creating a
 // new Timer.
 func (t *Timer) Reset(d time.Duration) bool {
-	if t.c == nil {
+	if t.clk == nil {
 		panic("timer: Reset called on uninitialized Timer")
 	}
-	return realClock.resetTimer(t, d)
+	return t.clk.resetTimer(t, t.clk.now().Add(d))
+}
+
+// ResetAt changes the timer to expire at the deadline when.
+// It returns true if the timer had been active,
+// false if the timer had expired or been stopped.
+// Like Reset, the channel t.C is cleared. If when is already in the past,
+// the timer fires as soon as possible.
+func (t *Timer) ResetAt(when time.Time) bool {
+	if t.clk == nil {
+		panic("timer: ResetAt called on uninitialized Timer")
+	}
+	return t.clk.resetTimer(t, when)
+}
+
+// ResetReturning is like Reset, but also returns the time that was left until
+// the previous deadline when the timer was reset. For a timer that had already
+// fired, remaining is negative (how long ago it fired), whether or not the
+// value has been received from t.C. For a paused timer it is the time that was
+// left when it was paused, and for a stopped timer it is 0.
+func (t *Timer) ResetReturning(d time.Duration) (remaining time.Duration, wasActive bool) {
+	if t.clk == nil {
+		panic("timer: ResetReturning called on uninitialized Timer")
+	}
+	return t.clk.resetTimerReturning(t, t.clk.now().Add(d))
+}
+
+// ResetKeepPending is like Reset, except that it does not clear the channel
+// t.C: a value sent by a previous expiry that has not been received yet stays
+// readable (unless the timer was created with WithModernSemantics, in which
+// case ResetKeepPending behaves exactly like Reset). Because t.C has a
+// capacity of 1, if that value is still unread when the timer fires again, the
+// newer value is dropped (the timer routine never blocks on the send).
+func (t *Timer) ResetKeepPending(d time.Duration) bool {
+	if t.clk == nil {
+		panic("timer: ResetKeepPending called on uninitialized Timer")
+	}
+	return t.clk.rearmTimer(t, t.clk.now().Add(d))
+}
+
+// SwapFunc replaces the function called when a Timer created by AfterFunc or
+// NewStoppedFunc expires, and returns the previous function. The swap is
+// atomic with respect to expiry: each fire calls either the old or the new
+// function, never a mix. A call that has already started keeps running the old
+// function; the new one applies to all later fires. The timer's schedule is not
+// changed.
+func (t *Timer) SwapFunc(f func()) (old func()) {
+	if t.clk == nil {
+		panic("timer: SwapFunc called on uninitialized Timer")
+	}
+	if f == nil {
+		panic("timer: SwapFunc called with nil func")
+	}
+	return t.clk.swapFunc(t, f)
+}
+
+// Remaining returns the time left until the timer expires. It returns 0 if
+// the timer has already expired or been stopped. For a paused timer, it
+// returns the time that was left when the timer was paused. The returned value
+// may be negative if the timer is overdue but the timer routine has not
+// processed it yet.
+func (t *Timer) Remaining() time.Duration {
+	if t.clk == nil {
+		panic("timer: Remaining called on uninitialized Timer")
+	}
+	return t.clk.remaining(t)
+}
+
+// Deadline returns the time at which the timer is scheduled to fire and
+// true, or the zero time and false if the timer is not currently scheduled
+// (it has expired, been stopped, or was never started).
+func (t *Timer) Deadline() (time.Time, bool) {
+	if t.clk == nil {
+		panic("timer: Deadline called on uninitialized Timer")
+	}
+	return t.clk.deadline(t)
+}
+
+// Expired reports whether the timer has fired since it was last started (by
+// NewTimer, AfterFunc, Reset, etc.). It returns false for a stopped timer
+// that never fired.
+func (t *Timer) Expired() bool {
+	if t.clk == nil {
+		panic("timer: Expired called on uninitialized Timer")
+	}
+	return t.clk.expired(t)
+}
+
+// Extend moves the timer's deadline by d (which may be negative) relative to
+// its current deadline rather than to the current time. For a paused timer,
+// it adjusts the time left. It returns false, and does nothing, if the timer
+// has already expired or been stopped.
+func (t *Timer) Extend(d time.Duration) bool {
+	if t.clk == nil {
+		panic("timer: Extend called on uninitialized Timer")
+	}
+	return t.clk.extendTimer(t, d)
+}
+
+// Elapsed returns how much of the duration the timer was last started with
+// has elapsed. Time spent paused does not count. After the timer fires it
+// returns the full duration; for a stopped timer it returns 0.
+func (t *Timer) Elapsed() time.Duration {
+	if t.clk == nil {
+		panic("timer: Elapsed called on uninitialized Timer")
+	}
+	e, _, _ := t.clk.elapsed(t)
+	return e
+}
+
+// Progress returns the fraction of the duration the timer was last started
+// with that has elapsed, from 0.0 to 1.0. It returns 1.0 once the timer has
+// fired (or for a pending timer started with a non-positive duration) and 0.0
+// for a stopped timer.
+func (t *Timer) Progress() float64 {
+	if t.clk == nil {
+		panic("timer: Progress called on uninitialized Timer")
+	}
+	e, total, state := t.clk.elapsed(t)
+	if total <= 0 {
+		if state == Scheduled || state == Fired {
+			return 1
+		}
+		return 0
+	}
+	return float64(e) / float64(total)
+}
+
+// SetName gives the timer a name that is shown by String, to tell timers apart
+// when debugging. The callbacks of the timer also run with the profiler label
+// "kairos.timer" set to the name (besides "kairos" set to "callback", which
+// all callbacks have; the timer goroutine has "kairos" set to "scheduler"), so
+// CPU profiles can be broken down per timer, with pprof -tagfocus for example.
+// Under WithManualDispatch, the callbacks run with the labels of the goroutine
+// that calls RunNext or RunUntil instead, which are left alone.
+func (t *Timer) SetName(name string) {
+	if t.clk == nil {
+		panic("timer: SetName called on uninitialized Timer")
+	}
+	t.clk.setName(t, name)
+}
+
+// Name returns the name set by SetName or WithName.
+func (t *Timer) Name() string {
+	if t.clk == nil {
+		panic("timer: Name called on uninitialized Timer")
+	}
+	return t.clk.timerName(t)
+}
+
+// String returns a description of the timer for debugging, for example
+// kairos.Timer{name="lease-renew", state=Scheduled, when=2024-05-03T10:00:00Z, index=7}.
+// The fields are read together under the clock's lock, so String is safe to
+// call from any goroutine. The index is the timer's position in the heap, or
+// -1 if it is not scheduled.
+func (t *Timer) String() string {
+	if t.clk == nil {
+		return "kairos.Timer{uninitialized}"
+	}
+	return t.clk.formatTimer(t)
+}
+
+// State returns the current state of the timer. The state changes to Fired as
+// soon as the notification is delivered, not when the value is received from
+// t.C.
+func (t *Timer) State() TimerState {
+	if t.clk == nil {
+		panic("timer: State called on uninitialized Timer")
+	}
+	return t.clk.timerState(t)
+}
+
+// Pause removes a pending timer from the heap and records the time that was
+// left until it would have expired. Resume restarts it with that time
+// remaining. Pause returns the time left and true on success, or 0 and false if
+// the timer has already expired, been stopped, or is already paused.
+//
+// Stop, Reset, and ResetAt on a paused timer cancel the pause and report that
+// the timer had been active.
+func (t *Timer) Pause() (remaining time.Duration, ok bool) {
+	if t.clk == nil {
+		panic("timer: Pause called on uninitialized Timer")
+	}
+	return t.clk.pauseTimer(t)
+}
+
+// Resume restarts a timer stopped by Pause with the time that was left when it
+// was paused. It returns false if the timer is not paused.
+func (t *Timer) Resume() bool {
+	if t.clk == nil {
+		panic("timer: Resume called on uninitialized Timer")
+	}
+	return t.clk.resumeTimer(t)
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -91,6 +93,34 @@ func TestNewTimer(t *testing.T) {
 	}
 }
 
+func TestNewTimerAt(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		d    time.Duration // Deadline relative to the start of the test.
+	}{
+		{"past", -time.Hour},
+		{"now", 0},
+		{"future", 100 * time.Millisecond},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			want := tc.d
+			if want < 0 {
+				want = 0
+			}
+			start := time.Now()
+			timer := NewTimerAt(start.Add(tc.d))
+			select {
+			case <-timer.C:
+				if got := time.Since(start); got < want || got >= want+margin {
+					t.Errorf("timer fired at wrong time; got duration %v, want %v", got, want)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("timer did not fire")
+			}
+		})
+	}
+}
+
 func TestStoppedTimer(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	t.Cleanup(cancel)
@@ -99,6 +129,12 @@ func TestStoppedTimer(t *testing.T) {
 	if !timer.when.IsZero() {
 		t.Errorf("invalid stopped timer when value")
 	}
+	if timer.i != -1 {
+		t.Errorf("invalid stopped timer heap index; got %v, want -1", timer.i)
+	}
+	if timer.Stop() {
+		t.Errorf("stopped timer: Stop returned true")
+	}
 
 	const want = time.Second
 	start := time.Now()
@@ -124,6 +160,106 @@ func TestStoppedTimer(t *testing.T) {
 	}
 }
 
+func TestStoppedFunc(t *testing.T) {
+	called := make(chan struct{})
+	timer := NewStoppedFunc(func() { close(called) })
+	if timer.C != nil {
+		t.Errorf("func timer has non-nil C")
+	}
+	if timer.Stop() {
+		t.Errorf("stopped timer: Stop returned true")
+	}
+	select {
+	case <-called:
+		t.Fatalf("callback of stopped timer was called")
+	case <-time.After(100 * time.Millisecond):
+	}
+	const want = 100 * time.Millisecond
+	start := time.Now()
+	if timer.Reset(want) {
+		t.Errorf("stopped timer: was active is true")
+	}
+	select {
+	case <-called:
+		if got := time.Since(start); got < want || got >= want+margin {
+			t.Errorf("callback called at wrong time; got duration %v, want %v", got, want)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("callback was not called")
+	}
+}
+
+func TestRepeatTimer(t *testing.T) {
+	const d = 20 * time.Millisecond
+	const count = 5
+	calls := make(chan int, count+1)
+	start := time.Now()
+	timer := NewRepeatTimer(d, count, func(n int) { calls <- n })
+	for i := 1; i <= count; i++ {
+		select {
+		case n := <-calls:
+			if n != i {
+				t.Errorf("wrong iteration; got %v, want %v", n, i)
+			}
+			if got, want := time.Since(start), time.Duration(i)*d; got < want {
+				t.Errorf("iteration %v called early; got duration %v, want >= %v", i, got, want)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("iteration %v not called", i)
+		}
+	}
+	select {
+	case n := <-calls:
+		t.Errorf("callback called more than %v times (iteration %v)", count, n)
+	case <-time.After(5 * d):
+	}
+	if got, want := timer.State(), Fired; got != want {
+		t.Errorf("wrong state after last iteration; got %v, want %v", got, want)
+	}
+	if timer.Stop() {
+		t.Errorf("stop finished timer: was active is true")
+	}
+}
+
+func TestRepeatTimerForever(t *testing.T) {
+	const d = 10 * time.Millisecond
+	calls := make(chan int, 100)
+	timer := NewRepeatTimer(d, 0, func(n int) { calls <- n })
+	for i := 1; i <= 10; i++ {
+		select {
+		case <-calls:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("iteration %v not called", i)
+		}
+	}
+	if got, want := timer.State(), Scheduled; got != want {
+		t.Errorf("wrong state between iterations; got %v, want %v", got, want)
+	}
+	if !timer.StopWait() {
+		t.Errorf("stop repeating timer: was active is false")
+	}
+	// Drain the calls started before StopWait returned; there must be no more.
+	for len(calls) > 0 {
+		<-calls
+	}
+	select {
+	case n := <-calls:
+		t.Errorf("callback called after Stop (iteration %v)", n)
+	case <-time.After(5 * d):
+	}
+	// Reset restarts the iteration count.
+	timer.Reset(0)
+	select {
+	case n := <-calls:
+		if n != 1 {
+			t.Errorf("wrong iteration after Reset; got %v, want 1", n)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("callback not called after Reset")
+	}
+	timer.Stop()
+}
+
 func TestStop(t *testing.T) {
 	timer := NewTimer(time.Second)
 	wasActive := timer.Stop()
@@ -143,6 +279,114 @@ func TestStop(t *testing.T) {
 	}
 }
 
+func TestStopRaceWithExpiry(t *testing.T) {
+	// Hammer Stop against expiry and verify that the return value agrees with what was observed on
+	// the channel.
+	const n = 10000
+	var gr errgroup.Group
+	for i := 0; i < n; i++ {
+		d := time.Duration(i%20) * time.Microsecond
+		gr.Go(func() error {
+			timer := NewTimer(d)
+			if timer.Stop() {
+				if len(timer.C) != 0 {
+					return fmt.Errorf("value sent on channel although Stop returned true")
+				}
+				// Give a misbehaving timer routine a chance to send a late value.
+				time.Sleep(time.Millisecond)
+				if len(timer.C) != 0 {
+					return fmt.Errorf("value sent on channel after Stop returned true")
+				}
+				return nil
+			}
+			if len(timer.C) != 1 {
+				return fmt.Errorf("channel empty although Stop returned false")
+			}
+			return nil
+		})
+	}
+	if err := gr.Wait(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStopAndDrain(t *testing.T) {
+	timer := NewTimer(time.Hour)
+	if timer.StopAndDrain() {
+		t.Errorf("stop pending timer: fired is true")
+	}
+	timer.Reset(0)
+	time.Sleep(100 * time.Millisecond)
+	if !timer.StopAndDrain() {
+		t.Errorf("stop fired timer: fired is false")
+	}
+	if len(timer.C) != 0 {
+		t.Errorf("stop fired timer: channel should be empty")
+	}
+	// Race StopAndDrain against expiry: afterwards the channel must be empty and stay empty.
+	var gr errgroup.Group
+	for i := 0; i < 1000; i++ {
+		d := time.Duration(i%20) * time.Microsecond
+		gr.Go(func() error {
+			timer := NewTimer(d)
+			timer.StopAndDrain()
+			time.Sleep(time.Millisecond)
+			if len(timer.C) != 0 {
+				return fmt.Errorf("value on channel after StopAndDrain")
+			}
+			return nil
+		})
+	}
+	if err := gr.Wait(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFireNow(t *testing.T) {
+	start := time.Now()
+	timer := NewTimer(time.Hour)
+	if !timer.FireNow() {
+		t.Errorf("fire timer: ok is false")
+	}
+	select {
+	case got := <-timer.C:
+		if got.Before(start) || got.Sub(start) >= margin {
+			t.Errorf("reported time is wrong; got %v, want ~%v", got, start)
+		}
+	default:
+		t.Errorf("FireNow did not send on the channel")
+	}
+	if timer.FireNow() {
+		t.Errorf("fire fired timer: ok is true")
+	}
+	if timer.Stop() {
+		t.Errorf("stop fired timer: was active is true")
+	}
+}
+
+func TestFireNowRace(t *testing.T) {
+	// The callback must run exactly once even if FireNow races with natural expiry.
+	var gr errgroup.Group
+	for i := 0; i < 1000; i++ {
+		d := time.Duration(i%20) * time.Microsecond
+		gr.Go(func() error {
+			calls := make(chan struct{}, 2)
+			timer := AfterFunc(d, func() { calls <- struct{}{} })
+			timer.FireNow()
+			<-calls
+			select {
+			case <-calls:
+				return fmt.Errorf("callback called twice")
+			case <-time.After(time.Millisecond):
+			}
+			return nil
+		})
+	}
+	if err := gr.Wait(); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestStopPanic(t *testing.T) {
 	defer func() {
 		r := recover()
@@ -233,6 +477,36 @@ func TestReset(t *testing.T) {
 	}
 }
 
+func TestResetAt(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	// A timer far in the future makes the rescheduled timer the new head of the heap, which
+	// requires the timer routine to be woken up.
+	timer := NewTimer(time.Hour)
+	t.Cleanup(func() { timer.Stop() })
+	const want = 100 * time.Millisecond
+	start := time.Now()
+	if !timer.ResetAt(start.Add(want)) {
+		t.Errorf("reset timer: was active is false")
+	}
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case <-timer.C:
+		if got := time.Since(start); got < want || got >= want+margin {
+			t.Errorf("timer fired at wrong time; got duration %v, want %v", got, want)
+		}
+	}
+	if timer.ResetAt(time.Now()) {
+		t.Errorf("reset timer: was active is true")
+	}
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case <-timer.C:
+	}
+}
+
 func TestMultipleResets(t *testing.T) {
 	for _, d := range []time.Duration{2 * time.Second, 0, -1 * time.Second} {
 		t.Run(fmt.Sprintf("%v", d), func(t *testing.T) {
@@ -306,6 +580,608 @@ func TestResetChannelClear(t *testing.T) {
 	}
 }
 
+func TestResetAtPanic(t *testing.T) {
+	defer func() {
+		r := recover()
+		if r == nil || r.(string) != "timer: ResetAt called on uninitialized Timer" {
+			t.Errorf("reset timer: invalid reset panic")
+		}
+	}()
+
+	timer := &Timer{}
+	timer.ResetAt(time.Now())
+}
+
+func TestSubscribe(t *testing.T) {
+	const want = 100 * time.Millisecond
+	timer := NewStoppedTimer()
+	subs := []<-chan time.Time{timer.Subscribe(), timer.Subscribe(), timer.Subscribe()}
+	start := time.Now()
+	timer.Reset(want)
+	var gr errgroup.Group
+	for _, c := range append(subs, timer.C) {
+		c := c
+		gr.Go(func() error {
+			select {
+			case <-c:
+				if got := time.Since(start); got < want || got >= want+margin {
+					return fmt.Errorf("timer fired at wrong time; got duration %v, want %v", got, want)
+				}
+				return nil
+			case <-time.After(10 * time.Second):
+				return fmt.Errorf("subscriber not notified")
+			}
+		})
+	}
+	if err := gr.Wait(); err != nil {
+		t.Error(err)
+	}
+
+	// A value left in a subscriber channel is cleared by Reset.
+	timer.Reset(0)
+	time.Sleep(100 * time.Millisecond)
+	timer.Reset(time.Hour)
+	t.Cleanup(func() { timer.Stop() })
+	for i, c := range subs {
+		if len(c) != 0 {
+			t.Errorf("subscriber %v not drained by Reset", i)
+		}
+	}
+
+	if !timer.Unsubscribe(subs[0]) {
+		t.Errorf("Unsubscribe returned false")
+	}
+	if timer.Unsubscribe(subs[0]) {
+		t.Errorf("second Unsubscribe returned true")
+	}
+	timer.Reset(0)
+	<-subs[1]
+	if len(subs[0]) != 0 {
+		t.Errorf("unsubscribed channel notified")
+	}
+}
+
+func TestOnStop(t *testing.T) {
+	var calls atomic.Int32
+	timer := NewTimer(time.Hour)
+	timer.OnStop(func() { calls.Add(1) })
+	timer.Reset(time.Hour)
+	if got := calls.Load(); got != 0 {
+		t.Errorf("OnStop hook called by Reset")
+	}
+	// Only one of many concurrent Stop calls succeeds, so the hook runs once.
+	var gr errgroup.Group
+	for i := 0; i < 100; i++ {
+		gr.Go(func() error {
+			timer.Stop()
+			return nil
+		})
+	}
+	gr.Wait()
+	if got := calls.Load(); got != 1 {
+		t.Errorf("OnStop hook called %v times, want 1", got)
+	}
+	// The hook is not called when the timer fires.
+	timer.OnStop(func() { calls.Add(1) })
+	timer.Reset(0)
+	<-timer.C
+	if timer.Stop() {
+		t.Errorf("stop fired timer: was active is true")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("OnStop hook called after fire; got %v calls, want 1", got)
+	}
+}
+
+func TestOnStopReentrant(t *testing.T) {
+	// The hook runs outside the lock, so it may use the timer.
+	timer := NewTimer(time.Hour)
+	done := make(chan TimerState, 1)
+	timer.OnStop(func() { done <- timer.State() })
+	timer.StopAndDrain()
+	select {
+	case got := <-done:
+		if got != Stopped {
+			t.Errorf("wrong state in OnStop hook; got %v, want %v", got, Stopped)
+		}
+	default:
+		t.Fatalf("OnStop hook not called by StopAndDrain")
+	}
+}
+
+func TestStopWait(t *testing.T) {
+	started := make(chan struct{})
+	var finished atomic.Bool
+	timer := AfterFunc(0, func() {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		finished.Store(true)
+	})
+	<-started
+	if timer.StopWait() {
+		t.Errorf("stop fired timer: was active is true")
+	}
+	if !finished.Load() {
+		t.Errorf("StopWait returned while the callback was still running")
+	}
+}
+
+func TestStopWaitFromCallback(t *testing.T) {
+	done := make(chan bool)
+	var timer *Timer
+	timer = NewStoppedFunc(func() { done <- timer.StopWait() })
+	timer.Reset(0)
+	select {
+	case got := <-done:
+		if got {
+			t.Errorf("stop fired timer from callback: was active is true")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("StopWait from within the callback deadlocked")
+	}
+}
+
+// The calls record their goroutine only once StopWait has been called, and the calls started before
+// are still waited for.
+func TestStopWaitRecordsGoroutines(t *testing.T) {
+	s := NewScheduler()
+	started, release := make(chan struct{}), make(chan struct{})
+	timer := s.AfterFunc(0, func() {
+		started <- struct{}{}
+		<-release
+	})
+	running := func() []uint64 {
+		s.lock()
+		defer s.unlock()
+		return append([]uint64(nil), timer.running...)
+	}
+	<-started
+	if got := running(); len(got) != 1 || got[0] != 0 {
+		t.Errorf("goroutines recorded before StopWait = %v, want [0]", got)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		timer.StopWait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Errorf("StopWait returned while the callback was running")
+	case <-time.After(margin):
+	}
+	release <- struct{}{}
+	<-stopped
+	timer.Reset(0)
+	<-started
+	if got := running(); len(got) != 1 || got[0] == 0 {
+		t.Errorf("goroutines recorded after StopWait = %v, want one ID", got)
+	}
+	release <- struct{}{}
+	timer.StopWait()
+}
+
+func TestStopWaitPending(t *testing.T) {
+	called := make(chan struct{})
+	timer := AfterFunc(100*time.Millisecond, func() { close(called) })
+	if !timer.StopWait() {
+		t.Errorf("stop pending timer: was active is false")
+	}
+	select {
+	case <-called:
+		t.Errorf("callback called after StopWait")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestSwapFunc(t *testing.T) {
+	calls := make(chan int, 10)
+	timer := AfterFunc(0, func() { calls <- 1 })
+	select {
+	case got := <-calls:
+		if got != 1 {
+			t.Errorf("wrong callback called; got %v, want 1", got)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("callback was not called")
+	}
+	old := timer.SwapFunc(func() { calls <- 2 })
+	if old == nil {
+		t.Errorf("SwapFunc returned nil")
+	}
+	timer.Reset(0)
+	select {
+	case got := <-calls:
+		if got != 2 {
+			t.Errorf("wrong callback called after swap; got %v, want 2", got)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("callback was not called after Reset")
+	}
+	old()
+	if got := <-calls; got != 1 {
+		t.Errorf("SwapFunc returned wrong function; got %v, want 1", got)
+	}
+}
+
+func TestSwapFuncDuringCallback(t *testing.T) {
+	// A swap while the old callback is running affects future fires only.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	calls := make(chan int, 10)
+	timer := AfterFunc(0, func() {
+		close(started)
+		<-release
+		calls <- 1
+	})
+	<-started
+	timer.SwapFunc(func() { calls <- 2 })
+	timer.Reset(0)
+	if got := <-calls; got != 2 {
+		t.Errorf("wrong callback for re-armed timer; got %v, want 2", got)
+	}
+	close(release)
+	if got := <-calls; got != 1 {
+		t.Errorf("running callback was affected by swap; got %v, want 1", got)
+	}
+}
+
+func TestSwapFuncPanic(t *testing.T) {
+	defer func() {
+		r := recover()
+		if r == nil || r.(string) != "timer: SwapFunc called on Timer not created by AfterFunc" {
+			t.Errorf("swap func: invalid panic %v", r)
+		}
+	}()
+
+	timer := NewStoppedTimer()
+	timer.SwapFunc(func() {})
+}
+
+func TestRemaining(t *testing.T) {
+	const d = time.Hour
+	timer := NewTimer(d)
+	if got := timer.Remaining(); got <= d-margin || got > d {
+		t.Errorf("wrong remaining time; got %v, want ~%v", got, d)
+	}
+	if timer.Expired() {
+		t.Errorf("pending timer reported as expired")
+	}
+	timer.Stop()
+	if got := timer.Remaining(); got != 0 {
+		t.Errorf("wrong remaining time for stopped timer; got %v, want 0", got)
+	}
+	if timer.Expired() {
+		t.Errorf("stopped timer reported as expired")
+	}
+	timer.Reset(0)
+	select {
+	case <-timer.C:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timer did not fire")
+	}
+	if got := timer.Remaining(); got != 0 {
+		t.Errorf("wrong remaining time for fired timer; got %v, want 0", got)
+	}
+	if !timer.Expired() {
+		t.Errorf("fired timer not reported as expired")
+	}
+	timer.Reset(d)
+	t.Cleanup(func() { timer.Stop() })
+	if timer.Expired() {
+		t.Errorf("re-armed timer reported as expired")
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	timer := NewStoppedTimer()
+	if got, ok := timer.Deadline(); ok || !got.IsZero() {
+		t.Errorf("wrong deadline for unstarted timer; got (%v, %v), want zero time and false", got, ok)
+	}
+	want := time.Now().Add(time.Hour)
+	timer.ResetAt(want)
+	t.Cleanup(func() { timer.Stop() })
+	if got, ok := timer.Deadline(); !ok || !got.Equal(want) {
+		t.Errorf("wrong deadline; got (%v, %v), want (%v, true)", got, ok, want)
+	}
+	want = want.Add(time.Hour)
+	timer.ResetAt(want)
+	if got, ok := timer.Deadline(); !ok || !got.Equal(want) {
+		t.Errorf("wrong deadline after reset; got (%v, %v), want (%v, true)", got, ok, want)
+	}
+	timer.ResetAt(time.Now())
+	select {
+	case <-timer.C:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timer did not fire")
+	}
+	if got, ok := timer.Deadline(); ok || !got.IsZero() {
+		t.Errorf("wrong deadline for fired timer; got (%v, %v), want zero time and false", got, ok)
+	}
+}
+
+func TestExtend(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		initial time.Duration
+		extend  time.Duration
+	}{
+		{"later", 100 * time.Millisecond, 100 * time.Millisecond},
+		{"earlier", time.Hour, -time.Hour + 100*time.Millisecond},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			t.Cleanup(cancel)
+			want := tc.initial + tc.extend
+			start := time.Now()
+			timer := NewTimer(tc.initial)
+			t.Cleanup(func() { timer.Stop() })
+			if !timer.Extend(tc.extend) {
+				t.Fatalf("extend timer: ok is false")
+			}
+			select {
+			case <-ctx.Done():
+				t.Fatal(ctx.Err())
+			case <-timer.C:
+				if got := time.Since(start); got < want || got >= want+margin {
+					t.Errorf("timer fired at wrong time; got duration %v, want %v", got, want)
+				}
+			}
+			if timer.Extend(time.Second) {
+				t.Errorf("extend fired timer: ok is true")
+			}
+			select {
+			case <-timer.C:
+				t.Errorf("extend re-armed fired timer")
+			case <-time.After(tc.extend + margin):
+			}
+		})
+	}
+}
+
+func TestExtendHeapOrder(t *testing.T) {
+	// Extending timers in a crowded heap must keep the heap ordered so that every timer fires in
+	// deadline order.
+	clk := NewScheduler()
+	const n = 100
+	start := time.Now()
+	timers := make([]*Timer, n)
+	for i := range timers {
+		timers[i] = clk.NewTimer(time.Hour)
+	}
+	for i, timer := range timers {
+		// Reverse the order: the last timer fires first.
+		clk.extendTimer(timer, -time.Hour+time.Duration(n-i)*time.Millisecond)
+	}
+	for i := n - 1; i >= 0; i-- {
+		select {
+		case got := <-timers[i].C:
+			if want := start.Add(time.Duration(n-i) * time.Millisecond); got.Before(want) {
+				t.Errorf("timer %v fired early; got %v, want >= %v", i, got, want)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timer %v did not fire", i)
+		}
+	}
+}
+
+func TestElapsedProgress(t *testing.T) {
+	const d = 200 * time.Millisecond
+	timer := NewTimer(d)
+	if got := timer.Progress(); got < 0 || got > 0.5 {
+		t.Errorf("wrong progress of new timer; got %v, want ~0", got)
+	}
+	time.Sleep(d / 2)
+	if got := timer.Elapsed(); got < d/2 || got >= d/2+margin {
+		t.Errorf("wrong elapsed time; got %v, want ~%v", got, d/2)
+	}
+	if got := timer.Progress(); got < 0.5 || got > 1 {
+		t.Errorf("wrong progress; got %v, want ~0.5", got)
+	}
+	select {
+	case <-timer.C:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timer did not fire")
+	}
+	time.Sleep(d / 2)
+	// The values must not keep growing after the timer fired.
+	if got := timer.Elapsed(); got > d || got < d-margin {
+		t.Errorf("wrong elapsed time of fired timer; got %v, want ~%v", got, d)
+	}
+	if got := timer.Progress(); got != 1 {
+		t.Errorf("wrong progress of fired timer; got %v, want 1", got)
+	}
+	timer.Reset(time.Hour)
+	timer.Stop()
+	if got := timer.Elapsed(); got != 0 {
+		t.Errorf("wrong elapsed time of stopped timer; got %v, want 0", got)
+	}
+	if got := timer.Progress(); got != 0 {
+		t.Errorf("wrong progress of stopped timer; got %v, want 0", got)
+	}
+}
+
+func TestName(t *testing.T) {
+	clk := NewScheduler()
+	when := time.Date(2124, 5, 3, 10, 0, 0, 0, time.UTC)
+	timer := clk.NewTimerAt(when, WithName("lease-renew"))
+	t.Cleanup(func() { timer.Stop() })
+	if got, want := timer.Name(), "lease-renew"; got != want {
+		t.Errorf("wrong name; got %q, want %q", got, want)
+	}
+	if got, want := timer.String(), `kairos.Timer{name="lease-renew", state=Scheduled, when=2124-05-03T10:00:00Z, index=0}`; got != want {
+		t.Errorf("wrong string;\n got %v\nwant %v", got, want)
+	}
+	timer.SetName("other")
+	timer.Stop()
+	if got, want := timer.String(), `kairos.Timer{name="other", state=Stopped, when=2124-05-03T10:00:00Z, index=-1}`; got != want {
+		t.Errorf("wrong string;\n got %v\nwant %v", got, want)
+	}
+	if got, want := (&Timer{}).String(), "kairos.Timer{uninitialized}"; got != want {
+		t.Errorf("wrong string for uninitialized timer; got %v, want %v", got, want)
+	}
+	// Printing a timer concurrently with heap operations must not race.
+	var gr errgroup.Group
+	for i := 0; i < 100; i++ {
+		gr.Go(func() error {
+			other := clk.NewTimer(time.Hour)
+			_ = timer.String() + other.String()
+			other.Stop()
+			return nil
+		})
+	}
+	gr.Wait()
+}
+
+func TestState(t *testing.T) {
+	timer := NewStoppedTimer()
+	if got, want := timer.State(), Stopped; got != want {
+		t.Errorf("wrong state of new stopped timer; got %v, want %v", got, want)
+	}
+	timer.Reset(time.Hour)
+	if got, want := timer.State(), Scheduled; got != want {
+		t.Errorf("wrong state of started timer; got %v, want %v", got, want)
+	}
+	timer.Pause()
+	if got, want := timer.State(), Paused; got != want {
+		t.Errorf("wrong state of paused timer; got %v, want %v", got, want)
+	}
+	timer.Resume()
+	timer.Stop()
+	if got, want := timer.State(), Stopped; got != want {
+		t.Errorf("wrong state of stopped timer; got %v, want %v", got, want)
+	}
+	timer.Reset(0)
+	time.Sleep(100 * time.Millisecond)
+	// The state must be Fired even though the value has not been received yet.
+	if got, want := timer.State(), Fired; got != want {
+		t.Errorf("wrong state of fired timer; got %v, want %v", got, want)
+	}
+	if got, want := TimerState(42).String(), "TimerState(42)"; got != want {
+		t.Errorf("wrong string for unknown state; got %q, want %q", got, want)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	const d = 200 * time.Millisecond
+	const pause = 200 * time.Millisecond
+	start := time.Now()
+	timer := NewTimer(d)
+	time.Sleep(d / 2)
+	left, ok := timer.Pause()
+	if !ok {
+		t.Fatalf("pause timer: ok is false")
+	}
+	if left <= 0 || left > d/2 {
+		t.Errorf("wrong remaining time; got %v, want (0, %v]", left, d/2)
+	}
+	if got, ok := timer.Pause(); ok || got != 0 {
+		t.Errorf("pause paused timer: got (%v, %v), want (0, false)", got, ok)
+	}
+	if got := timer.Remaining(); got != left {
+		t.Errorf("wrong remaining time for paused timer; got %v, want %v", got, left)
+	}
+	select {
+	case <-timer.C:
+		t.Fatalf("paused timer fired")
+	case <-time.After(pause):
+	}
+	if !timer.Resume() {
+		t.Fatalf("resume timer: ok is false")
+	}
+	if timer.Resume() {
+		t.Errorf("resume running timer: ok is true")
+	}
+	want := d + pause
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case <-timer.C:
+		if got := time.Since(start); got < want || got >= want+margin {
+			t.Errorf("timer fired at wrong time; got duration %v, want %v", got, want)
+		}
+	}
+	if got, ok := timer.Pause(); ok || got != 0 {
+		t.Errorf("pause fired timer: got (%v, %v), want (0, false)", got, ok)
+	}
+}
+
+func TestPauseStop(t *testing.T) {
+	timer := NewTimer(time.Hour)
+	if _, ok := timer.Pause(); !ok {
+		t.Fatalf("pause timer: ok is false")
+	}
+	if !timer.Stop() {
+		t.Errorf("stop paused timer: was active is false")
+	}
+	if timer.Resume() {
+		t.Errorf("resume stopped timer: ok is true")
+	}
+	if timer.Stop() {
+		t.Errorf("stop stopped timer: was active is true")
+	}
+}
+
+func TestResetReturning(t *testing.T) {
+	const d = time.Hour
+	timer := NewTimer(d)
+	t.Cleanup(func() { timer.Stop() })
+	remaining, wasActive := timer.ResetReturning(0)
+	if !wasActive {
+		t.Errorf("reset pending timer: was active is false")
+	}
+	if remaining <= d-margin || remaining > d {
+		t.Errorf("wrong remaining time; got %v, want ~%v", remaining, d)
+	}
+	<-timer.C
+	timer.Reset(0)
+	time.Sleep(100 * time.Millisecond)
+	remaining, wasActive = timer.ResetReturning(d)
+	if wasActive {
+		t.Errorf("reset fired timer: was active is true")
+	}
+	if remaining > -100*time.Millisecond || remaining <= -100*time.Millisecond-margin {
+		t.Errorf("wrong remaining time of fired timer; got %v, want ~%v", remaining, -100*time.Millisecond)
+	}
+	timer.Stop()
+	if remaining, _ := timer.ResetReturning(d); remaining != 0 {
+		t.Errorf("wrong remaining time of stopped timer; got %v, want 0", remaining)
+	}
+}
+
+func TestResetKeepPending(t *testing.T) {
+	timer := NewTimer(0)
+	time.Sleep(100 * time.Millisecond)
+	if len(timer.C) != 1 {
+		t.Fatalf("reset timer: channel should be filled")
+	}
+	start := time.Now()
+	if timer.ResetKeepPending(0) {
+		t.Errorf("reset timer: was active is true")
+	}
+	if len(timer.C) != 1 {
+		t.Errorf("reset timer: pending value was dropped")
+	}
+	// The second fire must not block the timer routine even though the channel is full.
+	other := NewTimer(100 * time.Millisecond)
+	select {
+	case <-other.C:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timer routine blocked by full channel")
+	}
+	if !timer.Expired() {
+		t.Errorf("second fire did not happen")
+	}
+	// The older value is kept, the newer one is dropped.
+	if got := <-timer.C; !got.Before(start) {
+		t.Errorf("wrong value kept; got %v, want value before %v", got, start)
+	}
+	if len(timer.C) != 0 {
+		t.Errorf("newer value was not dropped")
+	}
+}
+
 func TestResetPanic(t *testing.T) {
 	defer func() {
 		r := recover()
@@ -318,6 +1194,257 @@ func TestResetPanic(t *testing.T) {
 	timer.Reset(0)
 }
 
+func TestAfter(t *testing.T) {
+	const want = 100 * time.Millisecond
+	start := time.Now()
+	select {
+	case got := <-After(want):
+		if got := time.Since(start); got < want || got >= want+margin {
+			t.Errorf("timer fired at wrong time; got duration %v, want %v", got, want)
+		}
+		if got := got.Sub(start); got < want || got >= want+margin {
+			t.Errorf("reported time is wrong; got duration %v, want %v", got, want)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timer did not fire")
+	}
+}
+
+func TestAfterUnread(t *testing.T) {
+	// Use a private clock so that the heap is not shared with other tests.
+	clk := NewScheduler()
+	c := clk.After(0)
+	time.Sleep(100 * time.Millisecond)
+	clk.mutex.Lock()
+	n := clk.timers.Len()
+	clk.mutex.Unlock()
+	if n != 0 {
+		t.Errorf("fired timer was not removed from the heap; got %v timers, want 0", n)
+	}
+	if got, want := cap(c), 1; got != want {
+		t.Errorf("wrong channel capacity; got %v, want %v", got, want)
+	}
+	if got, want := len(c), 1; got != want {
+		t.Errorf("wrong number of values in channel; got %v, want %v", got, want)
+	}
+}
+
+func TestAfterFunc(t *testing.T) {
+	const want = 100 * time.Millisecond
+	done := make(chan time.Duration)
+	start := time.Now()
+	timer := AfterFunc(want, func() { done <- time.Since(start) })
+	if timer.C != nil {
+		t.Errorf("AfterFunc timer has non-nil C")
+	}
+	select {
+	case got := <-done:
+		if got < want || got >= want+margin {
+			t.Errorf("callback called at wrong time; got duration %v, want %v", got, want)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("callback was not called")
+	}
+	if timer.Stop() {
+		t.Errorf("stop timer: was active is true")
+	}
+}
+
+func TestAfterFuncStopRace(t *testing.T) {
+	// Either Stop reports that it prevented the call, or the callback runs.  Never both, never
+	// neither.
+	const n = 1000
+	var gr errgroup.Group
+	for i := 0; i < n; i++ {
+		d := time.Duration(i%10) * time.Microsecond
+		gr.Go(func() error {
+			called := make(chan struct{})
+			timer := AfterFunc(d, func() { close(called) })
+			if timer.Stop() {
+				select {
+				case <-called:
+					return fmt.Errorf("callback called after Stop returned true")
+				case <-time.After(10 * time.Millisecond):
+				}
+				return nil
+			}
+			select {
+			case <-called:
+				return nil
+			case <-time.After(10 * time.Second):
+				return fmt.Errorf("callback not called after Stop returned false")
+			}
+		})
+	}
+	if err := gr.Wait(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAfterFuncReset(t *testing.T) {
+	calls := make(chan struct{}, 2)
+	timer := AfterFunc(0, func() { calls <- struct{}{} })
+	select {
+	case <-calls:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("callback was not called")
+	}
+	const want = 100 * time.Millisecond
+	start := time.Now()
+	if timer.Reset(want) {
+		t.Errorf("reset timer: was active is true")
+	}
+	select {
+	case <-calls:
+		if got := time.Since(start); got < want || got >= want+margin {
+			t.Errorf("callback called at wrong time; got duration %v, want %v", got, want)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("callback was not called after Reset")
+	}
+}
+
+func TestResetFromCallback(t *testing.T) {
+	calls := make(chan struct{}, 3)
+	var n atomic.Int32
+	var timer *Timer
+	timer = NewStoppedFunc(func() {
+		calls <- struct{}{}
+		if n.Add(1) < 3 {
+			timer.Reset(time.Millisecond)
+		}
+	})
+	timer.Reset(0)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-calls:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Reset from within the callback deadlocked after %v calls", i)
+		}
+	}
+}
+
+func TestStopFromCallback(t *testing.T) {
+	done := make(chan bool)
+	other := NewTimer(time.Hour)
+	var timer *Timer
+	timer = NewStoppedFunc(func() {
+		timer.Stop()
+		// Timers can also be created and stopped from a callback.
+		NewTimer(time.Hour).Stop()
+		done <- other.Stop()
+	})
+	timer.Reset(0)
+	select {
+	case got := <-done:
+		if !got {
+			t.Errorf("stop pending timer from callback: was active is false")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Stop from within the callback deadlocked")
+	}
+}
+
+func TestSlowCallback(t *testing.T) {
+	// A callback that blocks must not hold up other timers or operations on them.
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	AfterFunc(0, func() {
+		close(started)
+		<-release
+	})
+	<-started
+	other := NewTimer(time.Hour)
+	if !other.Reset(time.Hour) {
+		t.Errorf("reset pending timer: was active is false")
+	}
+	if !other.Stop() {
+		t.Errorf("stop pending timer: was active is false")
+	}
+	const want = 10 * time.Millisecond
+	start := time.Now()
+	<-NewTimer(want).C
+	if got := time.Since(start); got < want || got >= want+margin {
+		t.Errorf("timer fired after %v during a slow callback, want %v", got, want)
+	}
+}
+
+func TestStopNewTimer(t *testing.T) {
+	s := NewScheduler()
+	<-s.NewTimer(0).C // Starts the timer routine.
+	for i := 0; i < 1000; i++ {
+		timer := s.NewTimer(time.Hour)
+		if !timer.Stop() {
+			t.Fatalf("stop new timer: was active is false")
+		}
+		if got := timer.State(); got != Stopped {
+			t.Fatalf("State() = %v after Stop, want %v", got, Stopped)
+		}
+	}
+	if n := s.PendingCount(); n != 0 {
+		t.Errorf("%v timers pending after Stop", n)
+	}
+}
+
+func TestNewTimerDuringShutdown(t *testing.T) {
+	s := NewScheduler()
+	<-s.NewTimer(0).C
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != "timer: Scheduler is shut down" {
+					t.Errorf("invalid panic %v", r)
+				}
+			}()
+			for {
+				s.NewTimer(time.Millisecond)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	s.Shutdown(ctx)
+	wg.Wait()
+	if n := s.PendingCount(); n != 0 {
+		t.Errorf("%v timers pending after Shutdown", n)
+	}
+}
+
+func TestWakeOnlyForEarlierDeadline(t *testing.T) {
+	// Without a timer routine, a wake-up request stays in rescheduleC.
+	s := &Scheduler{rescheduleC: make(chan struct{}, 1), timers: &timerHeap{}, started: true, base: time.Now()}
+	s.sleepUntil.Store(math.MaxInt64)
+	woken := func() bool {
+		select {
+		case <-s.rescheduleC:
+			return true
+		default:
+			return false
+		}
+	}
+	head := s.NewTimer(time.Hour)
+	if !woken() {
+		t.Errorf("first timer did not wake the timer routine")
+	}
+	// Pretend that the routine sleeps until the deadline of head.
+	when, _ := head.Deadline()
+	s.sleepUntil.Store(int64(when.Sub(s.base)))
+	head.Reset(2 * time.Hour)
+	s.NewTimer(3 * time.Hour)
+	if woken() {
+		t.Errorf("later deadlines woke the timer routine")
+	}
+	s.NewTimer(time.Minute)
+	if !woken() {
+		t.Errorf("earlier deadline did not wake the timer routine")
+	}
+}
+
 func prefillTimers(b *testing.B, n int) {
 	// Pre-fill a bunch of timers that will never fire (to stress heap management).
 	timers := make([]*Timer, 0, n)
@@ -357,3 +1484,41 @@ func BenchmarkTimerResetRapidFire(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkResetPending(b *testing.B) {
+	// Reset of pending timers, like idle timeouts that are pushed back on every read.
+	const n = 100000
+	s := NewScheduler()
+	timers := make([]*Timer, n)
+	for i := range timers {
+		timers[i] = s.NewTimer(time.Hour + time.Duration(i)*time.Millisecond)
+	}
+	b.Cleanup(func() {
+		for _, timer := range timers {
+			timer.Stop()
+		}
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		timers[(i*7919)%n].Reset(time.Hour + time.Duration(i%n)*time.Millisecond)
+	}
+}
+
+func BenchmarkAfterFuncThroughput(b *testing.B) {
+	// The goroutines of the calls are only looked up once StopWait has been called; the workers of
+	// the callback pool look theirs up once anyway.
+	for _, workers := range []int{0, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			s := NewScheduler()
+			defer s.Shutdown(context.Background())
+			s.SetCallbackWorkers(workers)
+			var wg sync.WaitGroup
+			wg.Add(b.N)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.AfterFunc(0, wg.Done)
+			}
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package kairos
+
+// WithTimerFD makes the timer routine of the Scheduler sleep on a timerfd on Linux, instead of a
+// runtime timer, and makes it available to poll (see TimerFD).  Its routine is then also woken up
+// as soon as the system clock is set, by a second timerfd with TFD_TIMER_CANCEL_ON_SET, instead of
+// up to a second later, for the wall-clock timers (see WithWallClock) to notice the step.  The
+// timers behave the same either way, except that the timerfd runs on the real time even in a
+// testing/synctest bubble, where the Scheduler must not be used.  On other systems, or if the
+// timerfds cannot be created, WithTimerFD has no effect.
+func WithTimerFD() SchedulerOption {
+	return func(clk *Scheduler) {
+		clk.useTimerFD = true
+	}
+}
+
+// TimerFD returns the timerfd that the timer routine of clk sleeps on, and true, if clk was created
+// with WithTimerFD on Linux, or -1 and false otherwise.  The timerfd exists while the timer routine
+// runs: from the time the first timer is started until Shutdown.  It becomes readable when the
+// routine is due to wake up, which makes it possible to wake up an event loop that polls it along
+// with sockets, but the routine reads it itself: watch it edge-triggered (with EPOLLET), and do not
+// read, arm, or close it.
+func (clk *Scheduler) TimerFD() (int, bool) {
+	clk.lock()
+	defer clk.unlock()
+	if clk.tfd == nil {
+		return -1, false
+	}
+	return clk.tfd.fd, true
+}
@@ -0,0 +1,124 @@
+package kairos
+
+import (
+	"errors"
+	"os"
+	"runtime/pprof"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// The constants of timerfd_create and timerfd_settime, which the syscall package does not define.
+const (
+	clockRealtime        = 0
+	clockMonotonic       = 1
+	tfdTimerAbstime      = 1 << 0
+	tfdTimerCancelOnSet  = 1 << 1
+	tfdFlags             = syscall.O_NONBLOCK | syscall.O_CLOEXEC
+	clockNotifyAfter     = 365 * 24 * time.Hour // How far away the clock timerfd is armed.
+	timerFDExpirationLen = 8                    // The size of the count that a read returns.
+)
+
+// The timerfds of the timer routine of a Scheduler created with WithTimerFD: one that it sleeps
+// on, and one that is canceled when the system clock is set.  Both are non-blocking, so that
+// reading them parks the reader in the network poller, and closing them wakes the reader up.
+type timerFD struct {
+	fd    int      // Of timer, for timerfd_settime; os.File.Fd would make it blocking.
+	timer *os.File // CLOCK_MONOTONIC.
+	clock *os.File // CLOCK_REALTIME, armed far away with TFD_TIMER_CANCEL_ON_SET.
+	clkfd int      // Of clock.
+	c     chan struct{}
+	wg    sync.WaitGroup // The goroutines that read timer and clock.
+}
+
+// Create the timerfds of a timer routine, or return nil if they cannot be created.
+func newTimerFD() *timerFD {
+	fd, err := timerfdCreate(clockMonotonic)
+	if err != nil {
+		return nil
+	}
+	clkfd, err := timerfdCreate(clockRealtime)
+	if err != nil {
+		syscall.Close(fd)
+		return nil
+	}
+	tfd := &timerFD{
+		fd:    fd,
+		timer: os.NewFile(uintptr(fd), "kairos-timerfd"),
+		clock: os.NewFile(uintptr(clkfd), "kairos-clockfd"),
+		clkfd: clkfd,
+		c:     make(chan struct{}, 1),
+	}
+	if tfd.armClock() != nil {
+		tfd.close()
+		return nil
+	}
+	tfd.wg.Add(2)
+	go tfd.read(tfd.timer, nil)
+	go tfd.read(tfd.clock, tfd.armClock)
+	return tfd
+}
+
+func timerfdCreate(clock int) (int, error) {
+	fd, _, e := syscall.Syscall(syscall.SYS_TIMERFD_CREATE, uintptr(clock), tfdFlags, 0)
+	if e != 0 {
+		return -1, e
+	}
+	return int(fd), nil
+}
+
+// Arm the timerfd fd to expire once at value, which is absolute if flags say so.
+func timerfdSettime(fd, flags int, value syscall.Timespec) error {
+	spec := struct{ interval, value syscall.Timespec }{value: value}
+	_, _, e := syscall.Syscall6(syscall.SYS_TIMERFD_SETTIME, uintptr(fd), uintptr(flags), uintptr(unsafe.Pointer(&spec)), 0, 0, 0)
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// Make the timerfd expire after d, replacing its previous arming.
+func (tfd *timerFD) sleep(d time.Duration) {
+	// A zero value would disarm it.
+	timerfdSettime(tfd.fd, 0, syscall.NsecToTimespec(int64(max(d, 1))))
+}
+
+// Disarm the timerfd.
+func (tfd *timerFD) stop() {
+	timerfdSettime(tfd.fd, 0, syscall.Timespec{})
+}
+
+// Arm the clock timerfd anew; it only expires if the system clock is set.
+func (tfd *timerFD) armClock() error {
+	at := time.Now().Add(clockNotifyAfter).UnixNano()
+	return timerfdSettime(tfd.clkfd, tfdTimerAbstime|tfdTimerCancelOnSet, syscall.NsecToTimespec(at))
+}
+
+// Wake up the timer routine whenever f expires, or, if rearm is not nil, whenever the system clock
+// is set, until f is closed.
+func (tfd *timerFD) read(f *os.File, rearm func() error) {
+	defer tfd.wg.Done()
+	pprof.SetGoroutineLabels(schedulerLabels)
+	var buf [timerFDExpirationLen]byte
+	for {
+		_, err := f.Read(buf[:])
+		if rearm != nil && errors.Is(err, syscall.ECANCELED) {
+			rearm()
+		} else if err != nil {
+			return
+		}
+		select {
+		case tfd.c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close the timerfds and wait for their readers to return.
+func (tfd *timerFD) close() {
+	tfd.timer.Close()
+	tfd.clock.Close()
+	tfd.wg.Wait()
+}
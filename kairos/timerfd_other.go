@@ -0,0 +1,19 @@
+//go:build !linux
+
+package kairos
+
+import "time"
+
+// timerfds only exist on Linux; the timer routine sleeps on a runtime timer elsewhere.
+type timerFD struct {
+	fd int
+	c  chan struct{}
+}
+
+func newTimerFD() *timerFD { return nil }
+
+func (tfd *timerFD) sleep(d time.Duration) {}
+
+func (tfd *timerFD) stop() {}
+
+func (tfd *timerFD) close() {}
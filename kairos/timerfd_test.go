@@ -0,0 +1,64 @@
+package kairos
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestTimerFD(t *testing.T) {
+	clk := NewScheduler(WithTimerFD())
+	if _, ok := clk.TimerFD(); ok {
+		t.Error("TimerFD reported a timerfd before the timer routine started")
+	}
+	var r fireRecorder
+	clk.RegisterObserver(&r)
+	start := time.Now()
+	late := clk.NewTimer(2 * margin)
+	early := clk.NewTimer(3 * margin)
+	early.Reset(margin)
+	stopped := clk.NewTimer(margin / 2)
+	stopped.Stop()
+	<-early.C
+	if got := time.Since(start); got < margin || got >= 2*margin {
+		t.Errorf("timer of %v fired after %v", margin, got)
+	}
+	fd, ok := clk.TimerFD()
+	if ok != (runtime.GOOS == "linux") {
+		t.Fatalf("TimerFD reported %v on %s", ok, runtime.GOOS)
+	}
+	if ok {
+		if link, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fd)); err == nil && link != "anon_inode:[timerfd]" {
+			t.Errorf("TimerFD returned %d, which is %s", fd, link)
+		}
+	}
+	<-late.C
+	if got := time.Since(start); got < 2*margin || got >= 3*margin {
+		t.Errorf("timer of %v fired after %v", 2*margin, got)
+	}
+	ticker := clk.NewTicker(margin / 5)
+	for i := 0; i < 5; i++ {
+		<-ticker.C
+	}
+	ticker.Stop()
+	r.mu.Lock()
+	if len(r.fired) < 7 || r.fired[0] != early || r.fired[1] != late {
+		t.Errorf("timers fired in the order %v", r.fired)
+	}
+	r.mu.Unlock()
+
+	if err := clk.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := clk.TimerFD(); ok {
+		t.Error("TimerFD reported a timerfd after Shutdown")
+	}
+	if ok {
+		if link, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fd)); err == nil && link == "anon_inode:[timerfd]" {
+			t.Error("timerfd was not closed by Shutdown")
+		}
+	}
+}
@@ -1,19 +1,112 @@
 package kairos
 
+import (
+	"time"
+)
+
+// A timerQueue holds the pending timers of a Scheduler.  It is implemented by timerHeap, the
+// default, and by timingWheel (see WithTimingWheel).  Its methods are called with the mutex held.
+type timerQueue interface {
+	// Peek returns the earliest timer, or some timer if the earliest is not known, or nil if the
+	// queue is empty.
+	Peek() *Timer
+	// Earliest returns the earliest timer, or nil if the queue is empty.  It may take longer than
+	// Peek.
+	Earliest() *Timer
+	Insert(t *Timer)
+	Remove(t *Timer) bool
+	// Fix restores the order of the queue after the expiration time of t, which must be in the
+	// queue, has changed.
+	Fix(t *Timer)
+	Len() int
+	// Next returns the time at which the timer routine must look at the queue again, which is no
+	// later than the earliest deadline (as rounded by the queue), and false if the queue is empty.
+	Next() (time.Time, bool)
+	// Due returns the earliest timer whose deadline is not after now, or nil if there is none.
+	Due(now time.Time) *Timer
+	// Latest returns the earliest time by which a timer is past the end of its slack (see
+	// WithSlack), which is the latest time the timer routine may look at the queue again.
+	Latest() time.Time
+	// Walk calls f for every timer in the queue, in no particular order.
+	Walk(f func(*Timer))
+	// Compact releases the memory that the queue holds for more timers than it has.
+	Compact()
+	// Reserve makes room for n timers, so that adding up to n does not reallocate.
+	Reserve(n int)
+	// Shrink releases memory if the queue holds far fewer timers than it has room for, but keeps
+	// room for at least floor timers.
+	Shrink(floor int)
+	// verify returns an error describing how the queue is corrupt, if it is.  See
+	// EnableInvariantChecks.
+	verify() error
+}
+
 // A timerHeap is a binary heap containing all running Timers, ordered by their expiration times.
 type timerHeap []*Timer
 
 func (h timerHeap) Peek() *Timer { return h.idx(0) }
+
+func (h timerHeap) Earliest() *Timer { return h.idx(0) }
+
+func (h timerHeap) Next() (time.Time, bool) {
+	if len(h) == 0 {
+		return time.Time{}, false
+	}
+	return h[0].when, true
+}
+
+func (h timerHeap) Due(now time.Time) *Timer {
+	if t := h.Peek(); t != nil && !t.when.After(now) {
+		return t
+	}
+	return nil
+}
+
+// Only the timers due before the latest time found so far can make it earlier, and the children of
+// a timer are due after it, so the subtrees of the timers due after it are skipped.
+func (h timerHeap) Latest() time.Time {
+	latest := time.Time{}
+	stack := []int{0}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if i >= len(h) || !latest.IsZero() && !h[i].when.Before(latest) {
+			continue
+		}
+		if end := h[i].when.Add(h[i].slack); latest.IsZero() || end.Before(latest) {
+			latest = end
+		}
+		stack = append(stack, 4*i+1, 4*i+2, 4*i+3, 4*i+4)
+	}
+	return latest
+}
+
+func (h timerHeap) Walk(f func(*Timer)) {
+	for _, t := range h {
+		f(t)
+	}
+}
+
 func (h *timerHeap) Insert(t *Timer) {
+	// Double the capacity (rather than let append grow it by less once it is large), so that a
+	// heap preallocated with Reserve keeps growing from that.
+	if c := cap(*h); len(*h) == c {
+		h.resize(max(2*c, minHeapCap))
+	}
 	t.i = h.Len()
 	*h = append(*h, t)
 	h.siftUp(t.i)
 }
 
-func (h *timerHeap) Remove(t *Timer) bool {
+// Contains reports whether t is in the heap.
+func (h timerHeap) Contains(t *Timer) bool {
 	// t may not be registered anymore and may have a bogus i (typically 0, if generated by Go).
-	// Verify it before proceeding.
-	if h.idx(t.i) != t {
+	// Verify it before trusting it.
+	return h.idx(t.i) == t
+}
+
+func (h *timerHeap) Remove(t *Timer) bool {
+	if !h.Contains(t) {
 		return false
 	}
 	i := t.i
@@ -32,6 +125,38 @@ func (h *timerHeap) Remove(t *Timer) bool {
 	return true
 }
 
+// The capacity below which a heap is never shrunk.
+const minHeapCap = 64
+
+func (h *timerHeap) Shrink(floor int) {
+	// Give back the memory of a spike, but not so eagerly that a heap whose size oscillates around
+	// a power of two reallocates all the time.
+	if c := cap(*h); c > max(minHeapCap, floor) && len(*h) < c/4 {
+		h.resize(max(c/2, floor))
+	}
+}
+
+func (h *timerHeap) Compact() { h.resize(len(*h)) }
+
+func (h *timerHeap) Reserve(n int) {
+	if n > cap(*h) {
+		h.resize(n)
+	}
+}
+
+// Move the heap to a new backing array of capacity c.  Every timer keeps its index.
+func (h *timerHeap) resize(c int) {
+	nh := make(timerHeap, len(*h), max(c, len(*h)))
+	copy(nh, *h)
+	*h = nh
+}
+
+// Fix restores the heap ordering after the expiration time of t has changed.  t must be in the heap.
+func (h timerHeap) Fix(t *Timer) {
+	h.siftUp(t.i)
+	h.siftDown(t.i)
+}
+
 func (h timerHeap) idx(i int) *Timer {
 	if i < 0 || i >= h.Len() {
 		return nil
@@ -44,14 +169,22 @@ func (h timerHeap) Len() int { return len(h) }
 // Heap maintenance algorithms.
 // Based on golang source /runtime/time.go
 
+// Report whether t is due before u: by deadline, and then in the order in which they were queued, so
+// that timers with the same deadline fire in a deterministic order.
+func (t *Timer) before(u *Timer) bool {
+	if c := t.when.Compare(u.when); c != 0 {
+		return c < 0
+	}
+	return t.order < u.order
+}
+
 func (h timerHeap) siftUp(i int) {
 	tmp := h[i]
-	when := tmp.when
 
 	var p int
 	for i > 0 {
 		p = (i - 1) / 4 // parent
-		if !when.Before(h[p].when) {
+		if !tmp.before(h[p]) {
 			break
 		}
 		h[i] = h[p]
@@ -64,7 +197,6 @@ func (h timerHeap) siftUp(i int) {
 
 func (h timerHeap) siftDown(i int) {
 	n := h.Len()
-	when := h[i].when
 	tmp := h[i]
 	for {
 		c := i*4 + 1 // left child
@@ -72,23 +204,23 @@ func (h timerHeap) siftDown(i int) {
 		if c >= n {
 			break
 		}
-		w := h[c].when
-		if c+1 < n && h[c+1].when.Before(w) {
-			w = h[c+1].when
+		w := h[c]
+		if c+1 < n && h[c+1].before(w) {
+			w = h[c+1]
 			c++
 		}
 		if c3 < n {
-			w3 := h[c3].when
-			if c3+1 < n && h[c3+1].when.Before(w3) {
-				w3 = h[c3+1].when
+			w3 := h[c3]
+			if c3+1 < n && h[c3+1].before(w3) {
+				w3 = h[c3+1]
 				c3++
 			}
-			if w3.Before(w) {
+			if w3.before(w) {
 				w = w3
 				c = c3
 			}
 		}
-		if !w.Before(when) {
+		if !w.before(tmp) {
 			break
 		}
 		h[i] = h[c]
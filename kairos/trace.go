@@ -0,0 +1,55 @@
+package kairos
+
+import (
+	"context"
+	"fmt"
+	"runtime/trace"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Set by EnableTracing.
+var tracing atomic.Bool
+
+// EnableTracing makes the timers log their life cycle in the execution trace
+// (see runtime/trace) while one is being recorded: an event is logged when a
+// timer is armed, reset, stopped, or fires, with the time left until the
+// deadline, or by how late the timer fired. The callbacks of timers run in a
+// region of type "kairos.callback", which starts with an event that tells how
+// late the callback started. The events have the categories kairos.arm,
+// kairos.reset, kairos.stop, kairos.fire, and kairos.run, and identify the
+// timer by its address and its name, if it has one (see SetName).
+//
+// Tracing is off by default, and then costs a single atomic load per event.
+// It applies to all Schedulers.
+func EnableTracing(on bool) {
+	tracing.Store(on)
+}
+
+// Report whether timer events must be logged.
+func traceOn() bool {
+	return tracing.Load() && trace.IsEnabled()
+}
+
+// Log event of t in the execution trace, if tracing is enabled.  The caller must hold the mutex,
+// or own t if it is new.
+func (t *Timer) traceLocked(event string) {
+	if traceOn() {
+		t.logLocked(event, t.clk.now())
+	}
+}
+
+// Log event of t at now in the execution trace, with its deadline relative to now.
+func (t *Timer) logLocked(event string, now time.Time) {
+	msg := fmt.Sprintf("%p", t)
+	if t.name != "" {
+		msg += " " + strconv.Quote(t.name)
+	}
+	if d := t.when.Sub(now); d >= 0 {
+		msg += fmt.Sprintf(" due in %v", d)
+	} else {
+		msg += fmt.Sprintf(" late %v", -d)
+	}
+	trace.Log(context.Background(), "kairos."+event, msg)
+}
@@ -0,0 +1,66 @@
+package kairos
+
+import (
+	"bytes"
+	"os"
+	"runtime/trace"
+	"testing"
+	"time"
+)
+
+func TestTracing(t *testing.T) {
+	if trace.IsEnabled() {
+		t.Skip("an execution trace is already being recorded")
+	}
+	s := NewScheduler()
+	record := func() []byte {
+		var buf bytes.Buffer
+		if err := trace.Start(&buf); err != nil {
+			t.Fatalf("trace.Start() = %v", err)
+		}
+		timer := s.NewTimer(time.Hour, WithName("traced"))
+		timer.Reset(time.Millisecond)
+		<-timer.C
+		timer.Reset(time.Hour)
+		timer.Stop()
+		done := make(chan struct{})
+		s.AfterFunc(0, func() { close(done) })
+		<-done
+		trace.Stop()
+		return buf.Bytes()
+	}
+	// The strings of the events are in the string table of the trace.
+	events := []string{"kairos.arm", "kairos.reset", "kairos.stop", "kairos.fire", "kairos.run", "kairos.callback", `"traced" late`}
+	tr := record()
+	for _, e := range events {
+		if bytes.Contains(tr, []byte(e)) {
+			t.Errorf("%s logged with tracing disabled", e)
+		}
+	}
+	EnableTracing(true)
+	defer EnableTracing(false)
+	tr = record()
+	for _, e := range events {
+		if !bytes.Contains(tr, []byte(e)) {
+			t.Errorf("%s not logged", e)
+		}
+	}
+}
+
+func ExampleEnableTracing() {
+	f, err := os.Create("trace.out")
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	if err := trace.Start(f); err != nil {
+		panic(err)
+	}
+	defer trace.Stop()
+	EnableTracing(true)
+
+	timer := NewTimer(10*time.Millisecond, WithName("request timeout"))
+	<-timer.C
+	// Open the trace with "go tool trace trace.out", and look for the events of the
+	// "request timeout" timer in the user-defined logs.
+}
@@ -0,0 +1,96 @@
+package kairos
+
+import (
+	"time"
+	"unsafe"
+)
+
+// A TimerEvent is sent on the channel of a ValueTimer when it expires.
+type TimerEvent[T any] struct {
+	Time  time.Time // The time at which the timer fired.
+	Value T         // The payload the timer was armed with.
+}
+
+// A ValueTimer is like a Timer, but the value sent on C when it expires carries a
+// payload of type T in addition to the current time. This avoids maintaining a
+// separate map from timers to whatever they are timing out.
+// A ValueTimer must be created with NewTimerWithValue.
+type ValueTimer[T any] struct {
+	C <-chan TimerEvent[T]
+	c chan TimerEvent[T] // Same channel as C.
+
+	t Timer
+	v T // Protected by the clock mutex.
+}
+
+func newValueTimer[T any](clk *Scheduler, v T) *ValueTimer[T] {
+	c := make(chan TimerEvent[T], 1)
+	vt := &ValueTimer[T]{C: c, c: c, v: v}
+	vt.t.clk = clk.shard(unsafe.Pointer(vt))
+	vt.t.i = -1
+	vt.t.send = vt.send
+	vt.t.drain = vt.drain
+	return vt
+}
+
+// NewTimerWithValue creates a new ValueTimer that will send the current time
+// and v on its channel after at least duration d.
+func NewTimerWithValue[T any](d time.Duration, v T) *ValueTimer[T] {
+	vt := newValueTimer(defaultScheduler, v)
+	vt.t.clk.resetTimer(&vt.t, vt.t.clk.now().Add(d))
+	return vt
+}
+
+// Stop prevents the ValueTimer from firing. It behaves like Timer.Stop.
+func (vt *ValueTimer[T]) Stop() bool {
+	if vt.c == nil {
+		panic("timer: Stop called on uninitialized ValueTimer")
+	}
+	return vt.t.clk.delTimer(&vt.t)
+}
+
+// Reset changes the timer to expire after duration d, keeping its current
+// payload. It behaves like Timer.Reset: the channel vt.C is cleared.
+func (vt *ValueTimer[T]) Reset(d time.Duration) bool {
+	if vt.c == nil {
+		panic("timer: Reset called on uninitialized ValueTimer")
+	}
+	return vt.t.clk.resetTimer(&vt.t, vt.t.clk.now().Add(d))
+}
+
+// ResetWithValue is like Reset, but also replaces the payload with v. The
+// payload and the deadline are updated atomically, so the timer can never
+// fire with the new deadline and the old payload or vice versa.
+func (vt *ValueTimer[T]) ResetWithValue(d time.Duration, v T) bool {
+	if vt.c == nil {
+		panic("timer: ResetWithValue called on uninitialized ValueTimer")
+	}
+	when := vt.t.clk.now().Add(d)
+	vt.t.clk.lock()
+	defer vt.t.clk.unlock()
+	vt.v = v
+	return vt.t.clk.resetTimerLocked(&vt.t, when)
+}
+
+// Value returns the current payload.
+func (vt *ValueTimer[T]) Value() T {
+	vt.t.clk.lock()
+	defer vt.t.clk.unlock()
+	return vt.v
+}
+
+// Called by the timer routine with the clock mutex held.
+func (vt *ValueTimer[T]) send(e expiry) {
+	select {
+	case vt.c <- TimerEvent[T]{Time: e.actual, Value: vt.v}:
+	default:
+	}
+}
+
+// Called with the clock mutex held.
+func (vt *ValueTimer[T]) drain() {
+	select {
+	case <-vt.c:
+	default:
+	}
+}
@@ -0,0 +1,69 @@
+package kairos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValueTimer(t *testing.T) {
+	const want = 100 * time.Millisecond
+	start := time.Now()
+	vt := NewTimerWithValue(want, "job-1")
+	select {
+	case ev := <-vt.C:
+		if got := time.Since(start); got < want || got >= want+margin {
+			t.Errorf("timer fired at wrong time; got duration %v, want %v", got, want)
+		}
+		if got := ev.Time.Sub(start); got < want || got >= want+margin {
+			t.Errorf("reported time is wrong; got duration %v, want %v", got, want)
+		}
+		if ev.Value != "job-1" {
+			t.Errorf("wrong payload; got %q, want %q", ev.Value, "job-1")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timer did not fire")
+	}
+	if vt.Stop() {
+		t.Errorf("stop timer: was active is true")
+	}
+}
+
+func TestValueTimerReset(t *testing.T) {
+	vt := NewTimerWithValue(0, 1)
+	time.Sleep(100 * time.Millisecond)
+	if len(vt.C) != 1 {
+		t.Fatalf("reset timer: channel should be filled")
+	}
+	if vt.ResetWithValue(time.Hour, 2) {
+		t.Errorf("reset timer: was active is true")
+	}
+	if len(vt.C) != 0 {
+		t.Errorf("reset timer: channel should be empty")
+	}
+	if got := vt.Value(); got != 2 {
+		t.Errorf("wrong payload; got %v, want 2", got)
+	}
+	if !vt.Reset(0) {
+		t.Errorf("reset timer: was active is false")
+	}
+	select {
+	case ev := <-vt.C:
+		if ev.Value != 2 {
+			t.Errorf("wrong payload; got %v, want 2", ev.Value)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timer did not fire")
+	}
+}
+
+func TestValueTimerStop(t *testing.T) {
+	vt := NewTimerWithValue(100*time.Millisecond, struct{}{})
+	if !vt.Stop() {
+		t.Errorf("stop timer: was active is false")
+	}
+	select {
+	case <-vt.C:
+		t.Errorf("failed to stop timer")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
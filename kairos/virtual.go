@@ -0,0 +1,50 @@
+package kairos
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WithVirtualTime makes the Scheduler run on a virtual time, which starts at the current time when
+// the Scheduler is created, and which its timer routine moves straight to the next deadline
+// instead of sleeping until it: the code that uses the Scheduler arms its timers with ordinary
+// durations, but a day's worth of them fires in as long as it takes to run their callbacks.  Now
+// returns the virtual time, which is also the time of every expiry.  The timer routine fires the
+// timers one at a time, in deadline order (with ties broken by the order in which they were
+// started), and waits for the callback of each timer to return before it moves on, so that the
+// callbacks run one after the other and the timers they start are fired in turn.  The receivers
+// of the channels of timers are not waited for: the virtual time may have moved on by the time
+// they receive a value.  WithVirtualTime cannot be combined with WithShards.
+func WithVirtualTime() SchedulerOption {
+	return func(clk *Scheduler) {
+		WithVirtualTimeAt(now())(clk)
+	}
+}
+
+// WithVirtualTimeAt is like WithVirtualTime, but the virtual time starts at start, for example to
+// simulate the timers of a given day without making every Scheduler of the process tell the time by
+// another function (see SetNowFunc).
+func WithVirtualTimeAt(start time.Time) SchedulerOption {
+	return func(clk *Scheduler) {
+		v := &virtualClock{start: start}
+		clk.virtual = v
+		clk.nowFunc = v.now
+	}
+}
+
+// The virtual time of a Scheduler created with WithVirtualTime.
+type virtualClock struct {
+	start   time.Time
+	elapsed atomic.Int64 // Since start; only the timer routine moves it.
+}
+
+func (v *virtualClock) now() time.Time {
+	return v.start.Add(time.Duration(v.elapsed.Load()))
+}
+
+// Move the virtual time to t, unless it is already past it.
+func (v *virtualClock) advance(t time.Time) {
+	if d := t.Sub(v.start); d > time.Duration(v.elapsed.Load()) {
+		v.elapsed.Store(int64(d))
+	}
+}
@@ -0,0 +1,107 @@
+package kairos
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestVirtualTime(t *testing.T) {
+	clk := NewScheduler(WithVirtualTime())
+	defer clk.Shutdown(context.Background())
+	start := clk.Now()
+	real := time.Now()
+
+	var running atomic.Int32
+	var mu sync.Mutex
+	var times []time.Time
+	var record func(d time.Duration) func()
+	record = func(d time.Duration) func() {
+		return func() {
+			if running.Add(1) != 1 {
+				t.Error("callbacks ran concurrently")
+			}
+			// Give another callback a chance to run concurrently, if it could.
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			times = append(times, clk.Now())
+			mu.Unlock()
+			if d < 24*time.Hour {
+				clk.AfterFunc(time.Hour, record(d+time.Hour))
+			}
+			running.Add(-1)
+		}
+	}
+	clk.AfterFunc(time.Hour, record(time.Hour))
+	clk.AfterFunc(time.Hour, record(24*time.Hour))
+	clk.AfterFunc(90*time.Minute, record(24*time.Hour))
+
+	// A goroutine that sleeps on the virtual time is not held up either.
+	clk.Sleep(25 * time.Hour)
+	if got := time.Since(real); got >= 5*time.Second {
+		t.Errorf("a day of virtual time took %v", got)
+	}
+	if got := clk.Now().Sub(start); got < 25*time.Hour {
+		t.Errorf("virtual time moved by %v during a sleep of 25h", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(times) != 26 {
+		t.Fatalf("%d callbacks ran, want 26", len(times))
+	}
+	want := []time.Duration{time.Hour, time.Hour, 90 * time.Minute}
+	for d := 2 * time.Hour; d <= 24*time.Hour; d += time.Hour {
+		want = append(want, d)
+	}
+	for i, got := range times {
+		if got.Sub(start) != want[i] {
+			t.Errorf("callback %d ran at %v, want %v", i, got.Sub(start), want[i])
+		}
+	}
+}
+
+func TestVirtualTimeIdle(t *testing.T) {
+	clk := NewScheduler(WithVirtualTime())
+	defer clk.Shutdown(context.Background())
+	start := clk.Now()
+	time.Sleep(margin)
+	if now := clk.Now(); !now.Equal(start) {
+		t.Errorf("virtual time moved by %v without timers", now.Sub(start))
+	}
+	ticker := clk.NewTicker(time.Minute)
+	for i := 1; i <= 3; i++ {
+		got := <-ticker.C
+		if got.Sub(start) < time.Duration(i)*time.Minute {
+			t.Errorf("tick %d at %v", i, got.Sub(start))
+		}
+	}
+	ticker.Stop()
+	stopped := clk.Now()
+	time.Sleep(margin)
+	if now := clk.Now(); !now.Equal(stopped) {
+		t.Errorf("virtual time moved by %v after the ticker was stopped", now.Sub(stopped))
+	}
+}
+
+func TestVirtualTimeAt(t *testing.T) {
+	start := time.Date(2026, time.March, 8, 0, 0, 0, 0, time.UTC)
+	clk := NewScheduler(WithVirtualTimeAt(start))
+	defer clk.Shutdown(context.Background())
+	if now := clk.Now(); !now.Equal(start) {
+		t.Errorf("Now returned %v, want %v", now, start)
+	}
+	if got := <-clk.NewTimer(time.Hour).C; !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("timer fired at %v, want %v", got, start.Add(time.Hour))
+	}
+}
+
+func TestVirtualTimeShards(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewScheduler did not panic")
+		}
+	}()
+	NewScheduler(WithVirtualTime(), WithShards(2))
+}
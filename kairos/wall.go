@@ -0,0 +1,93 @@
+package kairos
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// A refClock is the clock that the deadline of a timer is an instant of.  The heap only compares
+// deadlines by the monotonic clock (when there is one); a timer that follows another clock has its
+// deadline moved whenever that clock is found to have moved relative to the monotonic one.
+type refClock uint8
+
+const (
+	monotonicClock refClock = iota
+	wallClock               // See WithWallClock.
+	bootClock               // See WithIncludeSuspend.
+	numRefClocks
+)
+
+// How often the timer routine of a Scheduler with timers that follow another clock than the
+// monotonic one checks that clock at least, and the smallest change of that clock relative to the
+// monotonic one that counts as a step.
+const (
+	refCheckInterval = time.Second
+	refTolerance     = time.Millisecond
+)
+
+// Added to the readings of the wall clock, by tests that simulate steps of the system clock.
+var wallSkew atomic.Int64
+
+// Return the reading of c, which is not the monotonic clock, at now.
+func (c refClock) read(now time.Time) time.Duration {
+	if c == bootClock {
+		return bootTime(now)
+	}
+	return wallTime(now)
+}
+
+// Return the reading of the wall clock at now, in nanoseconds since the Unix epoch.
+func wallTime(now time.Time) time.Duration {
+	return time.Duration(now.Round(0).UnixNano() + wallSkew.Load())
+}
+
+// Record the deadline of t by the clock it follows, if it is not the monotonic one, once it has
+// been set.  The caller must hold the mutex.
+func (clk *Scheduler) refLocked(t *Timer) {
+	if t.ref == monotonicClock {
+		return
+	}
+	now := clk.now()
+	r := t.ref.read(now)
+	t.refWhen = r + t.when.Sub(now)
+	if bit := uint8(1) << t.ref; clk.refClocks&bit == 0 {
+		clk.refClocks |= bit
+		clk.refOff[t.ref] = r - now.Sub(clk.base)
+	}
+}
+
+// Move the deadlines of the timers of clk that follow another clock than the monotonic one by as
+// much as that clock has moved relative to the monotonic one, if it has by now (because the system
+// clock was stepped, or the system was suspended), so that they are still due at the same instant
+// of their clock.  The caller must hold the mutex.
+func (clk *Scheduler) checkRefLocked(now time.Time) {
+	var moved uint8
+	var reads [numRefClocks]time.Duration
+	for c := wallClock; c < numRefClocks; c++ {
+		if clk.refClocks&(1<<c) == 0 {
+			continue
+		}
+		reads[c] = c.read(now)
+		off := reads[c] - now.Sub(clk.base)
+		if d := off - clk.refOff[c]; d <= -refTolerance || d >= refTolerance {
+			clk.refOff[c] = off
+			moved |= 1 << c
+		}
+	}
+	if moved == 0 {
+		return
+	}
+	var ts []*Timer
+	clk.timers.Walk(func(t *Timer) {
+		if moved&(1<<t.ref) != 0 && t.ref != monotonicClock {
+			ts = append(ts, t)
+		}
+	})
+	for _, t := range ts {
+		when := now.Add(t.refWhen - reads[t.ref])
+		t.nominal = t.nominal.Add(when.Sub(t.when))
+		t.when = when
+		clk.timers.Fix(t)
+	}
+	clk.checkLocked("clock step")
+}
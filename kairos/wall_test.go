@@ -0,0 +1,44 @@
+package kairos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWallClock(t *testing.T) {
+	defer wallSkew.Store(0)
+	clk := NewScheduler()
+	wall := clk.NewTimer(time.Hour, WithWallClock())
+	monotonic := clk.NewTimer(time.Hour)
+	defer monotonic.Stop()
+
+	// Take the timers into the heap before the clock moves.
+	clk.PendingCount()
+	// Step the system clock forward past the deadline of the wall-clock timer.
+	wallSkew.Store(int64(time.Hour))
+	start := time.Now()
+	select {
+	case <-wall.C:
+	case <-time.After(refCheckInterval + margin):
+		t.Fatal("wall-clock timer did not fire after the clock was stepped past its deadline")
+	}
+	if got := time.Since(start); got >= refCheckInterval+margin {
+		t.Errorf("wall-clock timer fired %v after the step", got)
+	}
+	if left := monotonic.Remaining(); left < time.Hour-2*refCheckInterval {
+		t.Errorf("monotonic timer has %v left after the step, want about 1h", left)
+	}
+
+	// Step it back while a wall-clock timer is pending.
+	wall.Reset(2 * margin)
+	wallSkew.Store(0)
+	select {
+	case <-wall.C:
+		t.Error("wall-clock timer fired at its old deadline after the clock was stepped back")
+	case <-time.After(3 * margin):
+	}
+	if left := wall.Remaining(); left < time.Hour-2*refCheckInterval || left > time.Hour {
+		t.Errorf("wall-clock timer has %v left after the clock was stepped back by 1h", left)
+	}
+	wall.Stop()
+}
@@ -0,0 +1,121 @@
+package kairos
+
+import (
+	"math"
+	"time"
+)
+
+// Healthy reports whether the timer routine of clk is keeping up with its timers, that is, whether
+// it has been no more than maxLag late to process the expiries it was due to.  A routine that is
+// idle because no timer is pending is healthy.
+func (clk *Scheduler) Healthy(maxLag time.Duration) bool {
+	return clk.lag(clk.now()) <= maxLag
+}
+
+// SetStallHandler makes clk call h with the lag of its timer routine whenever the routine stalls
+// for more than maxLag.  Passing a nil h removes the handler.
+func (clk *Scheduler) SetStallHandler(maxLag time.Duration, h func(lag time.Duration)) {
+	clk.lock()
+	clk.onStall, clk.stallLag = h, maxLag
+	w := clk.takeWatchdogLocked()
+	if !clk.stopped {
+		clk.startWatchdogLocked()
+	}
+	clk.unlock()
+	w.stop()
+}
+
+// Return how long the timer routine of clk (or that of its most stalled shard) has gone without
+// waking up past the deadline it was sleeping until.
+func (clk *Scheduler) lag(now time.Time) time.Duration {
+	if len(clk.shards) > 0 {
+		var lag time.Duration
+		for _, s := range clk.shards {
+			lag = max(lag, s.lag(now))
+		}
+		return lag
+	}
+	until := clk.sleepUntil.Load()
+	if until == math.MaxInt64 || clk.frozen.Load() {
+		return 0
+	}
+	// Once awake, the routine beats every time it goes through its loop, even in a long burst of
+	// expiries; a routine that is slow to get the mutex or the CPU afterwards is stalled too.
+	since := max(until, clk.beat.Load())
+	return max(0, time.Duration(int64(now.Sub(clk.base))-since))
+}
+
+// A watchdog is a goroutine that calls the stall handler of a Scheduler.
+type watchdog struct {
+	quit chan struct{} // Closed to terminate the goroutine...
+	done chan struct{} // ...which closes this channel when it returns.
+}
+
+// Start the watchdog of clk if it has a stall handler.  The caller must hold the mutex.
+func (clk *Scheduler) startWatchdogLocked() {
+	if clk.onStall == nil || clk.watchdog != nil {
+		return
+	}
+	w := &watchdog{quit: make(chan struct{}), done: make(chan struct{})}
+	clk.watchdog = w
+	go clk.watch(w, clk.stallLag, clk.onStall)
+}
+
+// Detach the watchdog of clk, if any, for the caller to stop once it has released the mutex (the
+// stall handler may need it).  The caller must hold the mutex.
+func (clk *Scheduler) takeWatchdogLocked() *watchdog {
+	w := clk.watchdog
+	clk.watchdog = nil
+	return w
+}
+
+// Terminate the goroutine of w, if w is not nil, and wait for it.
+func (w *watchdog) stop() {
+	if w == nil {
+		return
+	}
+	close(w.quit)
+	<-w.done
+}
+
+func (clk *Scheduler) watch(w *watchdog, maxLag time.Duration, h func(lag time.Duration)) {
+	defer close(w.done)
+	// A timer of the standard library, since the timers of clk are what may be stalled.
+	tk := time.NewTicker(max(maxLag/2, time.Millisecond))
+	defer tk.Stop()
+	stalled := false
+	for {
+		select {
+		case <-w.quit:
+			return
+		case now := <-tk.C:
+			lag := clk.lag(now)
+			// Report a stall once, not on every check until the routine recovers.
+			if lag > maxLag && !stalled {
+				h(lag)
+			}
+			stalled = lag > maxLag
+		}
+	}
+}
+
+// Healthy reports whether the timer routine of the default Scheduler is
+// keeping up with its timers: it returns false if an expiry the routine was
+// due to process is more than maxLag overdue, because the routine is blocked
+// or starved of CPU. It returns true if no timer is pending, however long ago
+// the routine last had to do anything. A service can expose it in its health
+// check, since all of its timeouts silently stop firing when it is false.
+func Healthy(maxLag time.Duration) bool {
+	return defaultScheduler.Healthy(maxLag)
+}
+
+// SetStallHandler makes the default Scheduler watch its timer routine and call
+// h with the lag when the routine falls more than maxLag behind, as reported
+// by Healthy. The routine is checked every maxLag/2 by a goroutine of its
+// own, and h is called in that goroutine, once per stall: it is called again
+// only after the routine has recovered and stalled again. Passing a nil h
+// removes the handler and stops the goroutine, which Shutdown also stops (and
+// Start restarts).
+func SetStallHandler(maxLag time.Duration, h func(lag time.Duration)) {
+	defaultScheduler.SetStallHandler(maxLag, h)
+}
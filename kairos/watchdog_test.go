@@ -0,0 +1,64 @@
+package kairos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthy(t *testing.T) {
+	for _, shards := range []int{1, 4} {
+		s := NewScheduler(WithShards(shards))
+		const maxLag = 10 * time.Millisecond
+		stalls := make(chan time.Duration, 10)
+		s.SetStallHandler(maxLag, func(lag time.Duration) { stalls <- lag })
+		if !s.Healthy(maxLag) {
+			t.Errorf("unused Scheduler is not healthy")
+		}
+		<-s.NewTimer(0).C
+		long := s.NewTimer(time.Hour)
+		time.Sleep(5 * maxLag)
+		if !s.Healthy(maxLag) {
+			t.Errorf("idle Scheduler is not healthy")
+		}
+		select {
+		case lag := <-stalls:
+			t.Errorf("stall of %v reported for an idle Scheduler", lag)
+		default:
+		}
+
+		// Block the timer routine in the send hook of a timer, with the mutex held.
+		unblock, blocked := make(chan struct{}), make(chan struct{})
+		stuck := s.NewStoppedTimer()
+		stuck.send = func(expiry) {
+			close(blocked)
+			<-unblock
+		}
+		stuck.Reset(0)
+		<-blocked
+		start := time.Now()
+		select {
+		case lag := <-stalls:
+			if got := time.Since(start); lag <= maxLag || got >= maxLag+margin {
+				t.Errorf("stall of %v reported after %v, want more than %v", lag, got, maxLag)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("stall not reported")
+		}
+		if s.Healthy(maxLag) {
+			t.Errorf("stalled Scheduler is healthy")
+		}
+		time.Sleep(5 * maxLag)
+		select {
+		case lag := <-stalls:
+			t.Errorf("stall reported twice, the second time with a lag of %v", lag)
+		default:
+		}
+		close(unblock)
+		long.Stop()
+		time.Sleep(maxLag)
+		if !s.Healthy(maxLag) {
+			t.Errorf("Scheduler is not healthy after the stall")
+		}
+		s.SetStallHandler(0, nil)
+	}
+}
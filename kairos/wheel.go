@@ -0,0 +1,287 @@
+package kairos
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// A timingWheel is a hierarchical timing wheel: a timerQueue that inserts and removes timers in
+// constant time, at the price of rounding their deadlines up to a multiple of its tick.  Level l has
+// size slots, each of which holds the timers whose deadline, in ticks since base, has the same
+// value when shifted right by l*shift bits.  The lowest level that can tell a timer apart from the
+// current tick without wrapping around holds it; as the current tick advances, the timers of the
+// higher levels cascade down, until they are due and move to the ready heap, which orders them by
+// their exact deadline.  Enough levels are kept to cover every int64 number of ticks.
+type timingWheel struct {
+	tick   time.Duration
+	base   time.Time
+	shift  uint  // log2 of the number of slots per level.
+	mask   int64 // Number of slots per level, minus 1.
+	cur    int64 // The tick (since base) up to which the timers have been moved to ready.
+	levels [][]wheelSlot
+	used   [][]uint64 // Bitmaps of the non-empty slots of each level.
+	ready  timerHeap  // The timers that are due as of cur.
+	n      int        // Number of timers, including ready ones.
+}
+
+// A wheelSlot holds a doubly-linked list of timers, linked by their wnext and wprev fields.
+type wheelSlot struct {
+	head  *Timer
+	level int
+	pos   int64
+}
+
+// Create a timing wheel with ticks of tick and at least size slots per level, starting at base.
+func newTimingWheel(tick time.Duration, size int, base time.Time) *timingWheel {
+	shift := uint(bits.Len(uint(size - 1)))
+	if shift < 6 {
+		// The bitmaps need at least one full word per level.
+		shift = 6
+	}
+	w := &timingWheel{tick: tick, base: base, shift: shift, mask: 1<<shift - 1}
+	nlevels := (63 + int(shift) - 1) / int(shift)
+	w.levels = make([][]wheelSlot, nlevels)
+	w.used = make([][]uint64, nlevels)
+	for l := range w.levels {
+		w.levels[l] = make([]wheelSlot, 1<<shift)
+		for pos := range w.levels[l] {
+			w.levels[l][pos] = wheelSlot{level: l, pos: int64(pos)}
+		}
+		w.used[l] = make([]uint64, (1<<shift)/64)
+	}
+	return w
+}
+
+// Return the deadline of t rounded up to a tick since base.
+func (w *timingWheel) tickOf(t *Timer) int64 {
+	d := t.when.Sub(w.base)
+	if d <= 0 {
+		return 0
+	}
+	k := int64(d / w.tick)
+	if d%w.tick != 0 {
+		k++
+	}
+	return k
+}
+
+func (w *timingWheel) Len() int { return w.n }
+
+func (w *timingWheel) Insert(t *Timer) {
+	w.n++
+	w.place(t)
+}
+
+// Put t into the slot for its deadline, or into ready if it is due.
+func (w *timingWheel) place(t *Timer) {
+	k := w.tickOf(t)
+	if k <= w.cur {
+		w.ready.Insert(t)
+		return
+	}
+	l := 0
+	for (k>>(uint(l)*w.shift))-(w.cur>>(uint(l)*w.shift)) > w.mask {
+		l++
+	}
+	s := &w.levels[l][(k>>(uint(l)*w.shift))&w.mask]
+	t.wslot = s
+	t.wprev = nil
+	t.wnext = s.head
+	if s.head != nil {
+		s.head.wprev = t
+	}
+	s.head = t
+	w.used[l][s.pos/64] |= 1 << (s.pos % 64)
+}
+
+// Take t out of its slot.
+func (w *timingWheel) unlink(t *Timer) {
+	s := t.wslot
+	if t.wprev != nil {
+		t.wprev.wnext = t.wnext
+	} else {
+		s.head = t.wnext
+	}
+	if t.wnext != nil {
+		t.wnext.wprev = t.wprev
+	}
+	t.wslot, t.wnext, t.wprev = nil, nil, nil
+	if s.head == nil {
+		w.used[s.level][s.pos/64] &^= 1 << (s.pos % 64)
+	}
+}
+
+func (w *timingWheel) Remove(t *Timer) bool {
+	switch {
+	case t.wslot != nil:
+		w.unlink(t)
+	case !w.ready.Remove(t):
+		return false
+	}
+	w.n--
+	return true
+}
+
+func (w *timingWheel) Fix(t *Timer) {
+	if t.wslot != nil {
+		w.unlink(t)
+	} else {
+		w.ready.Remove(t)
+	}
+	w.place(t)
+}
+
+// Advance the current tick to k, moving the timers that are due by then to ready and cascading the
+// others down to the level that is right for them.
+func (w *timingWheel) advance(k int64) {
+	old := w.cur
+	w.cur = k
+	// The higher levels go first, so that the timers that cascade down to a lower level are moved
+	// along with those that are already there.
+	for l := len(w.levels) - 1; l >= 0; l-- {
+		shift := uint(l) * w.shift
+		from, to := old>>shift, k>>shift
+		if from == to {
+			continue
+		}
+		// Look at the slots from the one after the old current one to the new current one, but
+		// at each slot only once.
+		if to-from > w.mask {
+			from = to - w.mask - 1
+		}
+		for j := from + 1; j <= to; j++ {
+			s := &w.levels[l][j&w.mask]
+			t := s.head
+			if t == nil {
+				continue
+			}
+			s.head = nil
+			w.used[l][s.pos/64] &^= 1 << (s.pos % 64)
+			for t != nil {
+				next := t.wnext
+				t.wslot, t.wnext, t.wprev = nil, nil, nil
+				w.place(t)
+				t = next
+			}
+		}
+	}
+}
+
+func (w *timingWheel) Due(now time.Time) *Timer {
+	if k := int64(now.Sub(w.base) / w.tick); k > w.cur {
+		w.advance(k)
+	}
+	// Only the deadlines that are too far after base for a time.Duration can be after now.
+	return w.ready.Due(now)
+}
+
+// Return the position of the first non-empty slot of level l after the current one, as the (not
+// wrapped) slot number, and false if the level is empty.
+func (w *timingWheel) nextSlot(l int) (int64, bool) {
+	cur := w.cur >> (uint(l) * w.shift)
+	size := w.mask + 1
+	for d := int64(1); d < size; {
+		pos := (cur + d) & w.mask
+		word := w.used[l][pos/64] >> (pos % 64)
+		if word == 0 {
+			// Skip to the next word.
+			d += 64 - pos%64
+			continue
+		}
+		d += int64(bits.TrailingZeros64(word))
+		if d >= size {
+			break
+		}
+		return cur + d, true
+	}
+	return 0, false
+}
+
+func (w *timingWheel) Next() (time.Time, bool) {
+	if t := w.ready.Peek(); t != nil {
+		return t.when, true
+	}
+	if w.n == 0 {
+		return time.Time{}, false
+	}
+	// The start of the earliest non-empty slot: its timers are due then if it is on the lowest
+	// level, and cascade down otherwise.
+	first := int64(math.MaxInt64)
+	for l := range w.levels {
+		if j, ok := w.nextSlot(l); ok && j<<(uint(l)*w.shift) < first {
+			first = j << (uint(l) * w.shift)
+		}
+	}
+	if first > int64(math.MaxInt64/w.tick) {
+		return w.base.Add(math.MaxInt64), true
+	}
+	return w.base.Add(time.Duration(first) * w.tick), true
+}
+
+func (w *timingWheel) Peek() *Timer {
+	if t := w.ready.Peek(); t != nil {
+		return t
+	}
+	for l := range w.levels {
+		if j, ok := w.nextSlot(l); ok {
+			return w.levels[l][j&w.mask].head
+		}
+	}
+	return nil
+}
+
+// The ready timers are due before those in the slots.  Otherwise, the earliest non-empty slot of
+// each level holds the earliest timer of the level, but not in order, and a slot of a higher level
+// may start before the earliest timer of a lower one.
+func (w *timingWheel) Earliest() *Timer {
+	if t := w.ready.Peek(); t != nil {
+		return t
+	}
+	var first *Timer
+	for l := range w.levels {
+		if j, ok := w.nextSlot(l); ok {
+			for t := w.levels[l][j&w.mask].head; t != nil; t = t.wnext {
+				if first == nil || t.before(first) {
+					first = t
+				}
+			}
+		}
+	}
+	return first
+}
+
+func (w *timingWheel) Compact() { w.ready.Compact() }
+
+// The slots of a wheel are preallocated and its timers are linked into them, so only the heap of
+// ready timers ever needs memory, and that is bounded by the number of timers due in one tick.
+func (w *timingWheel) Reserve(n int) {}
+
+func (w *timingWheel) Shrink(floor int) { w.ready.Shrink(0) }
+
+// The slots are not ordered by their exact deadlines, so every timer is looked at.  A timer is not
+// due before its deadline is rounded up to a tick, even if its slack ends before then.
+func (w *timingWheel) Latest() time.Time {
+	var latest time.Time
+	w.Walk(func(t *Timer) {
+		end := t.when.Add(t.slack)
+		if due := w.base.Add(time.Duration(w.tickOf(t)) * w.tick); due.After(end) {
+			end = due
+		}
+		if latest.IsZero() || end.Before(latest) {
+			latest = end
+		}
+	})
+	return latest
+}
+
+func (w *timingWheel) Walk(f func(*Timer)) {
+	w.ready.Walk(f)
+	for l := range w.levels {
+		for pos := range w.levels[l] {
+			for t := w.levels[l][pos].head; t != nil; t = t.wnext {
+				f(t)
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package kairos
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestTimingWheelModel(t *testing.T) {
+	// Compare the wheel with what it should do, on random operations.
+	rnd := rand.New(rand.NewSource(1))
+	base := time.Now()
+	const tick = time.Millisecond
+	w := newTimingWheel(tick, 64, base)
+	pending := make(map[*Timer]bool)
+	randomWhen := func(now time.Time) time.Time {
+		switch rnd.Intn(4) {
+		case 0:
+			return now.Add(time.Duration(rnd.Int63n(int64(100 * tick))))
+		case 1:
+			return now.Add(time.Duration(rnd.Int63n(int64(time.Hour))))
+		case 2:
+			return now.Add(time.Duration(rnd.Int63n(math.MaxInt64 / 4)))
+		default:
+			return now.Add(-time.Duration(rnd.Int63n(int64(time.Second))))
+		}
+	}
+	now := base
+	for step := 0; step < 20000; step++ {
+		switch rnd.Intn(4) {
+		case 0:
+			tm := &Timer{i: -1, when: randomWhen(now)}
+			w.Insert(tm)
+			pending[tm] = true
+		case 1:
+			for tm := range pending {
+				if !w.Remove(tm) {
+					t.Fatalf("Remove of a pending timer returned false")
+				}
+				if w.Remove(tm) {
+					t.Fatalf("second Remove returned true")
+				}
+				delete(pending, tm)
+				break
+			}
+		case 2:
+			for tm := range pending {
+				tm.when = randomWhen(now)
+				w.Fix(tm)
+				break
+			}
+		case 3:
+			if next, ok := w.Next(); ok && rnd.Intn(2) == 0 && next.Sub(now) < time.Hour {
+				now = next
+			} else {
+				now = now.Add(time.Duration(rnd.Int63n(int64(10 * time.Second))))
+			}
+			var prev time.Time
+			for {
+				tm := w.Due(now)
+				if tm == nil {
+					break
+				}
+				if tm.when.After(now) || tm.when.Before(prev) || !pending[tm] {
+					t.Fatalf("Due(%v) returned a timer due at %v after one due at %v", now, tm.when, prev)
+				}
+				prev = tm.when
+				w.Remove(tm)
+				delete(pending, tm)
+			}
+			// Everything that is due at the last tick must have been returned.
+			due := base.Add(now.Sub(base).Truncate(tick))
+			for tm := range pending {
+				if !tm.when.After(due) {
+					t.Fatalf("timer due at %v not returned at %v", tm.when, now)
+				}
+			}
+			if next, ok := w.Next(); ok != (len(pending) > 0) || ok && !next.After(now) {
+				t.Fatalf("Next() = %v, %v at %v with %v timers", next, ok, now, len(pending))
+			}
+			for tm := range pending {
+				// The wheel rounds deadlines up to a tick.
+				when := base.Add(time.Duration(w.tickOf(tm)) * tick)
+				if next, _ := w.Next(); when.Before(next) {
+					t.Fatalf("Next() = %v after a deadline of %v", next, tm.when)
+				}
+			}
+		}
+		if w.Len() != len(pending) {
+			t.Fatalf("Len() = %v, want %v", w.Len(), len(pending))
+		}
+	}
+	n := 0
+	w.Walk(func(tm *Timer) {
+		if !pending[tm] {
+			t.Errorf("Walk visited a timer that is not pending")
+		}
+		n++
+	})
+	if n != len(pending) {
+		t.Errorf("Walk visited %v timers, want %v", n, len(pending))
+	}
+}
+
+func TestTimingWheelScheduler(t *testing.T) {
+	s := NewScheduler(WithTimingWheel(time.Millisecond, 256))
+	const d = 50 * time.Millisecond
+	start := time.Now()
+	timer := s.NewTimer(d)
+	long := s.NewTimer(time.Hour)
+	stopped := s.NewTimer(d)
+	reset := s.NewTimer(time.Hour)
+	reset.Reset(2 * d)
+	if !stopped.Stop() {
+		t.Errorf("stop pending timer: was active is false")
+	}
+	<-timer.C
+	if got := time.Since(start); got < d || got >= d+margin {
+		t.Errorf("timer fired after %v, want %v", got, d)
+	}
+	<-reset.C
+	if got := time.Since(start); got < 2*d || got >= 2*d+margin {
+		t.Errorf("reset timer fired after %v, want %v", got, 2*d)
+	}
+	tk := s.NewTicker(10 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		<-tk.C
+	}
+	tk.Stop()
+	if n := s.PendingCount(); n != 1 {
+		t.Errorf("PendingCount() = %v, want 1", n)
+	}
+	long.Stop()
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() = %v", err)
+	}
+}
+
+func TestTimingWheelPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "timer: invalid tick or size for WithTimingWheel" {
+			t.Errorf("invalid panic %v", r)
+		}
+	}()
+	WithTimingWheel(0, 256)
+}
+
+// Compare the heap with the timing wheel with many pending timers.
+func BenchmarkTimerQueue(b *testing.B) {
+	queues := []struct {
+		name string
+		opt  SchedulerOption
+	}{
+		{"heap", func(*Scheduler) {}},
+		{"wheel", WithTimingWheel(time.Millisecond, 256)},
+	}
+	for _, n := range []int{1e4, 1e5, 1e6} {
+		for _, q := range queues {
+			prefill := func(b *testing.B) *Scheduler {
+				s := NewScheduler(q.opt)
+				timers := make([]*Timer, n)
+				for i := range timers {
+					timers[i] = s.NewTimer(time.Hour + time.Duration(i)*time.Microsecond)
+				}
+				b.Cleanup(func() {
+					for _, timer := range timers {
+						timer.Stop()
+					}
+				})
+				return s
+			}
+			b.Run(fmt.Sprintf("%v/%v/arm+cancel", q.name, n), func(b *testing.B) {
+				s := prefill(b)
+				timer := s.NewStoppedTimer()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					timer.Reset(time.Duration(i%1e6) * time.Millisecond)
+					timer.Stop()
+				}
+			})
+			b.Run(fmt.Sprintf("%v/%v/fire", q.name, n), func(b *testing.B) {
+				// Throughput rather than latency: b.N timers that are due at once.
+				s := prefill(b)
+				timers := make([]*Timer, b.N)
+				for i := range timers {
+					timers[i] = s.NewStoppedTimer()
+				}
+				b.ResetTimer()
+				now := time.Now()
+				for _, timer := range timers {
+					timer.ResetAt(now)
+				}
+				for _, timer := range timers {
+					<-timer.C
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Package kairosprom exports the statistics of kairos Schedulers as Prometheus metrics.
+//
+// It is a separate package so that the kairos package does not depend on the Prometheus client.
+package kairosprom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rhansen/go-kairos/kairos"
+)
+
+// A Collector is a prometheus.Collector that reports the statistics of a Scheduler when it is
+// scraped:
+//
+//	kairos_pending_timers               gauge      timers armed and not yet expired
+//	kairos_fired_total                  counter    expiries delivered
+//	kairos_stopped_total                counter    timers stopped before they fired
+//	kairos_reschedule_wakeups_total     counter    times the timer goroutine was woken up early
+//	kairos_fire_lateness_seconds        histogram  how late the expiries were processed
+//
+// The buckets of the histogram are those of the lateness histogram of the Scheduler (see
+// kairos.SetLatencyBuckets), except that Prometheus counts an expiry that is late by exactly the
+// upper bound of a bucket in that bucket, and kairos in the next one.
+type Collector struct {
+	stats   func() kairos.SchedulerStats
+	latency func() kairos.LatencySnapshot
+
+	pending  *prometheus.Desc
+	fired    *prometheus.Desc
+	stopped  *prometheus.Desc
+	wakeups  *prometheus.Desc
+	lateness *prometheus.Desc
+}
+
+// NewCollector returns a Collector for s, or for the default Scheduler if s is nil.  constLabels
+// are added to every metric; they tell the Schedulers apart when the collectors of several are
+// registered, as in prometheus.Labels{"scheduler": "rpc"}.
+func NewCollector(s *kairos.Scheduler, constLabels prometheus.Labels) *Collector {
+	c := &Collector{stats: kairos.Stats, latency: kairos.LatencyHistogram}
+	if s != nil {
+		c.stats, c.latency = s.Stats, s.LatencyHistogram
+	}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(name, help, nil, constLabels)
+	}
+	c.pending = desc("kairos_pending_timers", "Number of timers that are armed and have not expired yet.")
+	c.fired = desc("kairos_fired_total", "Number of timer expiries delivered, counting every tick.")
+	c.stopped = desc("kairos_stopped_total", "Number of timers stopped before they fired.")
+	c.wakeups = desc("kairos_reschedule_wakeups_total", "Number of times the timer goroutine was woken up to look at an earlier deadline.")
+	c.lateness = desc("kairos_fire_lateness_seconds", "How long after their deadline timer expiries were processed.")
+	return c
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.pending
+	ch <- c.fired
+	ch <- c.stopped
+	ch <- c.wakeups
+	ch <- c.lateness
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	st := c.stats()
+	ch <- prometheus.MustNewConstMetric(c.pending, prometheus.GaugeValue, float64(st.PendingTimers))
+	ch <- prometheus.MustNewConstMetric(c.fired, prometheus.CounterValue, float64(st.TotalFired))
+	ch <- prometheus.MustNewConstMetric(c.stopped, prometheus.CounterValue, float64(st.TotalStopped))
+	ch <- prometheus.MustNewConstMetric(c.wakeups, prometheus.CounterValue, float64(st.TotalWakeups))
+
+	h := c.latency()
+	buckets := make(map[float64]uint64, len(h.Bounds))
+	var n uint64
+	for i, count := range h.Counts {
+		n += count
+		if i < len(h.Bounds) {
+			// Prometheus buckets are cumulative.
+			buckets[h.Bounds[i].Seconds()] = n
+		}
+	}
+	ch <- prometheus.MustNewConstHistogram(c.lateness, n, h.Sum.Seconds(), buckets)
+}
@@ -0,0 +1,74 @@
+package kairosprom
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rhansen/go-kairos/kairos"
+)
+
+func TestCollector(t *testing.T) {
+	rpc, batch := kairos.NewScheduler(), kairos.NewScheduler()
+	batch.SetLatencyBuckets(time.Hour)
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewCollector(rpc, prometheus.Labels{"scheduler": "rpc"}))
+	reg.MustRegister(NewCollector(batch, prometheus.Labels{"scheduler": "batch"}))
+
+	pending := rpc.NewTimer(time.Hour)
+	defer pending.Stop()
+	rpc.NewTimer(time.Hour).Stop()
+	<-rpc.NewTimer(0).C
+	<-batch.NewTimer(0).C
+
+	want := `
+# HELP kairos_fired_total Number of timer expiries delivered, counting every tick.
+# TYPE kairos_fired_total counter
+kairos_fired_total{scheduler="batch"} 1
+kairos_fired_total{scheduler="rpc"} 1
+# HELP kairos_pending_timers Number of timers that are armed and have not expired yet.
+# TYPE kairos_pending_timers gauge
+kairos_pending_timers{scheduler="batch"} 0
+kairos_pending_timers{scheduler="rpc"} 1
+# HELP kairos_stopped_total Number of timers stopped before they fired.
+# TYPE kairos_stopped_total counter
+kairos_stopped_total{scheduler="batch"} 0
+kairos_stopped_total{scheduler="rpc"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "kairos_fired_total", "kairos_pending_timers", "kairos_stopped_total"); err != nil {
+		t.Error(err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		switch f.GetName() {
+		case "kairos_reschedule_wakeups_total":
+			for _, m := range f.GetMetric() {
+				if m.GetCounter().GetValue() < 1 {
+					t.Errorf("no wakeups counted: %v", m)
+				}
+			}
+		case "kairos_fire_lateness_seconds":
+			for _, m := range f.GetMetric() {
+				h := m.GetHistogram()
+				var b []float64
+				for _, bucket := range h.GetBucket() {
+					b = append(b, bucket.GetUpperBound())
+				}
+				want := []float64{0.001, 0.01, 0.1, 1}
+				if m.GetLabel()[0].GetValue() == "batch" {
+					want = []float64{3600}
+				}
+				if h.GetSampleCount() != 1 || !slices.Equal(b, want) {
+					t.Errorf("lateness histogram %v", m)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Package kairostest checks that tests do not leave kairos timers behind, like goleak does for
+// goroutines.  A test that stops every timer it starts ends with
+//
+//	defer kairostest.VerifyNone(t)
+//
+// and a test suite whose tests share the default Scheduler can keep a leaky test from making the
+// following ones fail too by resetting it after each of them:
+//
+//	defer kairostest.Reset()
+//	defer kairostest.VerifyNone(t)
+package kairostest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rhansen/go-kairos/kairos"
+)
+
+// An Option configures VerifyNone or Reset.
+type Option func(*options)
+
+type options struct {
+	clk   *kairos.Scheduler
+	grace time.Duration
+}
+
+// WithScheduler makes VerifyNone or Reset look at the timers of clk instead of those of the
+// default Scheduler.
+func WithScheduler(clk *kairos.Scheduler) Option {
+	return func(o *options) {
+		o.clk = clk
+	}
+}
+
+// IgnoreDueWithin makes VerifyNone ignore the timers that are due within d (including the overdue
+// ones), which are about to fire anyway, such as the timeout of a request that completed just
+// before the end of the test.
+func IgnoreDueWithin(d time.Duration) Option {
+	return func(o *options) {
+		o.grace = d
+	}
+}
+
+func buildOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// The timers of o: those of its Scheduler, or of the default one.
+func (o *options) timers() []kairos.TimerInfo {
+	if o.clk != nil {
+		return o.clk.DumpTimers()
+	}
+	return kairos.DumpTimers()
+}
+
+// VerifyNone fails t if timers are pending, listing their names, deadlines, and the places where
+// they were created, if kairos.EnableCallSites was on then.  Paused timers do not count.
+func VerifyNone(t testing.TB, opts ...Option) {
+	t.Helper()
+	o := buildOptions(opts)
+	var leaked []kairos.TimerInfo
+	for _, info := range o.timers() {
+		if info.Remaining > o.grace {
+			leaked = append(leaked, info)
+		}
+	}
+	if len(leaked) == 0 {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "kairostest: %d timers left pending:", len(leaked))
+	for _, info := range leaked {
+		name := info.Name
+		if name == "" {
+			name = "(unnamed)"
+		}
+		fmt.Fprintf(&b, "\n\t%s due at %v (in %v)", name, info.Deadline, info.Remaining)
+		if info.CreatedAt != "" {
+			fmt.Fprintf(&b, ", created by %s", info.CreatedAt)
+		}
+	}
+	t.Error(b.String())
+}
+
+// Reset stops every pending timer (and ticker) and returns their number, so that the timers leaked
+// by a test do not fire during the next ones, or make them fail VerifyNone.
+func Reset(opts ...Option) int {
+	o := buildOptions(opts)
+	if o.clk != nil {
+		return o.clk.StopAll()
+	}
+	return kairos.StopAll()
+}
@@ -0,0 +1,77 @@
+package kairostest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rhansen/go-kairos/kairos"
+)
+
+// A testing.TB that records the failures instead of reporting them.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Error(args ...any) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func TestVerifyNone(t *testing.T) {
+	clk := kairos.NewScheduler()
+	r := &recorder{TB: t}
+	VerifyNone(r, WithScheduler(clk))
+	if len(r.errors) != 0 {
+		t.Errorf("VerifyNone failed without timers: %q", r.errors)
+	}
+
+	kairos.EnableCallSites(true)
+	leaked := clk.NewTimer(time.Hour, kairos.WithName("leaked"))
+	kairos.EnableCallSites(false)
+	clk.NewTimer(time.Minute)
+	soon := clk.NewTimer(time.Second, kairos.WithName("soon"))
+	paused := clk.NewTimer(time.Hour, kairos.WithName("paused"))
+	paused.Pause()
+	VerifyNone(r, WithScheduler(clk), IgnoreDueWithin(10*time.Second))
+	if len(r.errors) != 1 {
+		t.Fatalf("VerifyNone reported %d failures, want 1", len(r.errors))
+	}
+	msg := r.errors[0]
+	deadline, _ := leaked.Deadline()
+	for _, want := range []string{"2 timers left pending", "leaked due at " + deadline.String(), "(unnamed)", "created by", "TestVerifyNone"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("failure %q does not contain %q", msg, want)
+		}
+	}
+	for _, unwanted := range []string{"soon", "paused"} {
+		if strings.Contains(msg, unwanted) {
+			t.Errorf("failure %q reports the %s timer", msg, unwanted)
+		}
+	}
+
+	if n := Reset(WithScheduler(clk)); n != 3 {
+		t.Errorf("Reset stopped %d timers, want 3", n)
+	}
+	if soon.Stop() {
+		t.Error("timer still pending after Reset")
+	}
+	r.errors = nil
+	VerifyNone(r, WithScheduler(clk))
+	if len(r.errors) != 0 {
+		t.Errorf("VerifyNone failed after Reset: %q", r.errors)
+	}
+}
+
+func TestVerifyNoneDefault(t *testing.T) {
+	defer Reset()
+	kairos.NewTimer(time.Hour)
+	r := &recorder{TB: t}
+	VerifyNone(r)
+	if len(r.errors) != 1 {
+		t.Errorf("VerifyNone reported %d failures for a timer of the default Scheduler, want 1", len(r.errors))
+	}
+}